@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +25,7 @@ type Account struct {
 	Email                      string       `json:"email"`
 	PhoneNumber                string       `json:"phone_number"`
 	IsBusiness                 bool         `json:"is_business"`
+	AccountType                int          `json:"account_type"`
 	Gender                     int          `json:"gender"`
 	ProfilePicID               string       `json:"profile_pic_id"`
 	CanSeeOrganicInsights      bool         `json:"can_see_organic_insights"`
@@ -193,9 +193,8 @@ func (account *Account) ChangeProfilePic(photo io.Reader) error {
 		buf:   buf,
 	}
 
-	t := http.DetectContentType(buf.Bytes())
-	if t != "image/jpeg" {
-		return ErrInvalidImage
+	if err := o.convertImage(); err != nil {
+		return fmt.Errorf("ChangeProfilePic convertImage: %w", err)
 	}
 
 	if err = o.uploadPhoto(); err != nil {
@@ -567,6 +566,16 @@ func (account *Account) FriendhipsShowMany(userIds []string) (map[string]Friends
 	return result.Friendships, nil
 }
 
+// StoryArchive returns your archived stories, grouped in a Reel per day. The
+// items of a day can be fetched with Reel.Sync.
+//
+// For pagination use StoryArchive.Next()
+func (account *Account) StoryArchive() *StoryArchive {
+	return &StoryArchive{
+		insta: account.insta,
+	}
+}
+
 // Archived returns current account archive feed
 //
 // For pagination use FeedMedia.Next()
@@ -0,0 +1,496 @@
+package goinsta
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	archiveStateName   = ".goinsta_archive.json"
+	archiveNameTmpl    = "{taken_at}_{pk}"
+	archiveProfileName = "profile.json"
+)
+
+// Archive sections, used as directory names inside the archive.
+const (
+	ArchivePosts      = "posts"
+	ArchiveStories    = "stories"
+	ArchiveHighlights = "highlights"
+	ArchiveIGTV       = "igtv"
+	ArchiveTagged     = "tagged"
+)
+
+// ArchiveOptions can be used to configure Instagram.Archive. A nil value
+// archives every section with the default settings.
+type ArchiveOptions struct {
+	// Workers is the number of concurrent downloads per section.
+	Workers int
+
+	SkipPosts      bool
+	SkipStories    bool
+	SkipHighlights bool
+	SkipIGTV       bool
+	SkipTagged     bool
+
+	// Comments will save all comments of each post in its sidecar. This takes
+	// one extra request per page of comments, for every post.
+	Comments bool
+	// MaxComments limits the amount of comments saved per post, 0 is no limit.
+	MaxComments int
+
+	// Full disables incremental updates, and walks through every feed
+	// till the end, refreshing the sidecars of all posts.
+	Full bool
+}
+
+// ArchiveReport summarizes a single Archive run.
+type ArchiveReport struct {
+	// Downloaded is the number of new items per section.
+	Downloaded map[string]int
+	// Skipped is the number of items per section that were already archived.
+	Skipped map[string]int
+	// Failed is the number of items per section that could not be archived.
+	Failed map[string]int
+}
+
+// ArchivedMedia is the JSON sidecar written next to every archived item.
+type ArchivedMedia struct {
+	ID           string            `json:"id"`
+	Code         string            `json:"code,omitempty"`
+	URL          string            `json:"url,omitempty"`
+	Username     string            `json:"username"`
+	TakenAt      int64             `json:"taken_at"`
+	Type         string            `json:"type"`
+	Caption      string            `json:"caption,omitempty"`
+	Location     *ArchivedLocation `json:"location,omitempty"`
+	TaggedUsers  []string          `json:"tagged_users,omitempty"`
+	LikeCount    int               `json:"like_count"`
+	CommentCount int               `json:"comment_count"`
+	ViewCount    float64           `json:"view_count,omitempty"`
+	Files        []string          `json:"files"`
+	Comments     []ArchivedComment `json:"comments,omitempty"`
+	ArchivedAt   int64             `json:"archived_at"`
+}
+
+// ArchivedLocation is the location a post was tagged at.
+type ArchivedLocation struct {
+	ID   int64   `json:"id"`
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lng  float64 `json:"lng"`
+}
+
+// ArchivedComment is a single comment saved in an ArchivedMedia sidecar.
+type ArchivedComment struct {
+	ID        string `json:"id"`
+	Username  string `json:"username"`
+	Text      string `json:"text"`
+	CreatedAt int64  `json:"created_at"`
+	LikeCount int    `json:"like_count"`
+}
+
+// archiveState keeps track of the newest archived post per section, to
+// allow incremental updates.
+type archiveState struct {
+	Newest  map[string]int64 `json:"newest"`
+	Updated int64            `json:"updated_at"`
+}
+
+type archiver struct {
+	insta  *Instagram
+	user   *User
+	dir    string
+	opts   *ArchiveOptions
+	state  *archiveState
+	report *ArchiveReport
+	mu     *sync.Mutex
+}
+
+// Archive saves a complete copy of a user's profile to dir. This includes the
+// profile info, and the media of all posts, stories, highlights, IGTV posts
+// and posts the user is tagged in. Every item gets a JSON sidecar with its
+// caption, location, tagged users, like and comment counts, and optionally
+// all comments.
+//
+// The archive is laid out as:
+//
+//	dir/profile.json
+//	dir/posts/<taken_at>_<pk>.jpg + .json
+//	dir/stories/...
+//	dir/highlights/<highlight id>/...
+//	dir/igtv/...
+//	dir/tagged/...
+//
+// Running Archive again on the same directory only fetches the posts that are
+// newer than the newest archived post of each section. Pinned posts are
+// ignored for this, as they are shown at the top of the feed regardless of
+// their age. Set ArchiveOptions.Full to walk through all posts again.
+func (insta *Instagram) Archive(user *User, dir string, opts *ArchiveOptions) (*ArchiveReport, error) {
+	if opts == nil {
+		opts = &ArchiveOptions{}
+	}
+	if user.insta == nil {
+		user.insta = insta
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+
+	a := &archiver{
+		insta: insta,
+		user:  user,
+		dir:   dir,
+		opts:  opts,
+		report: &ArchiveReport{
+			Downloaded: make(map[string]int),
+			Skipped:    make(map[string]int),
+			Failed:     make(map[string]int),
+		},
+		mu: &sync.Mutex{},
+	}
+	if err := a.loadState(); err != nil {
+		return nil, err
+	}
+
+	if err := a.profile(); err != nil {
+		return a.report, err
+	}
+
+	var errs []error
+	if !opts.SkipPosts {
+		errs = append(errs, a.posts())
+	}
+	if !opts.SkipStories {
+		errs = append(errs, a.stories())
+	}
+	if !opts.SkipHighlights {
+		errs = append(errs, a.highlights())
+	}
+	if !opts.SkipIGTV {
+		errs = append(errs, a.igtv())
+	}
+	if !opts.SkipTagged {
+		errs = append(errs, a.tagged())
+	}
+
+	if err := a.saveState(); err != nil {
+		errs = append(errs, err)
+	}
+	for _, err := range errs {
+		if err != nil {
+			return a.report, err
+		}
+	}
+	return a.report, nil
+}
+
+func (a *archiver) profile() error {
+	if err := a.user.Info(); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(a.dir, archiveProfileName), a.user); err != nil {
+		return err
+	}
+
+	url := a.user.HdProfilePicURLInfo.URL
+	if url == "" {
+		url = a.user.ProfilePicURL
+	}
+	if url == "" {
+		return nil
+	}
+	name, err := getDownloadName(url, "profile_pic")
+	if err != nil {
+		return err
+	}
+	return a.insta.downloadFile(url, filepath.Join(a.dir, name))
+}
+
+func (a *archiver) posts() error {
+	feed := a.user.Feed()
+	return a.feed(ArchivePosts, func() bool { return feed.Next() }, func() []*Item { return feed.Items }, feed.Error)
+}
+
+func (a *archiver) tagged() error {
+	feed, err := a.user.Tags(nil)
+	if err != nil {
+		return err
+	}
+
+	// The first page has already been fetched
+	first := true
+	next := func() bool {
+		if first {
+			first = false
+			return true
+		}
+		return feed.Next()
+	}
+	return a.feed(ArchiveTagged, next, func() []*Item { return feed.Items }, feed.Error)
+}
+
+func (a *archiver) igtv() error {
+	channel, err := a.user.IGTV()
+	if err != nil && err != ErrNoMore {
+		return err
+	}
+
+	first := true
+	next := func() bool {
+		if first {
+			first = false
+			return true
+		}
+		return channel.Next()
+	}
+	return a.feed(ArchiveIGTV, next, func() []*Item { return channel.Items }, channel.Error)
+}
+
+func (a *archiver) stories() error {
+	stories, err := a.user.Stories()
+	if err != nil {
+		return err
+	}
+
+	// Stories expire, so there is no point in stopping early
+	d, err := a.downloader(ArchiveStories, filepath.Join(a.dir, ArchiveStories))
+	if err != nil {
+		return err
+	}
+	d.Add(stories.Reel.Items...)
+	return d.Wait()
+}
+
+func (a *archiver) highlights() error {
+	reels, err := a.user.Highlights()
+	if err != nil {
+		return err
+	}
+
+	for _, reel := range reels {
+		if len(reel.Items) == 0 {
+			if err := reel.Sync(); err != nil {
+				return err
+			}
+		}
+
+		dir := filepath.Join(a.dir, ArchiveHighlights, sanitizeFileName(toString(reel.ID)))
+		d, err := a.downloader(ArchiveHighlights, dir)
+		if err != nil {
+			return err
+		}
+
+		info := map[string]interface{}{
+			"id":          reel.ID,
+			"title":       reel.Title,
+			"media_count": reel.MediaCount,
+		}
+		if err := writeJSON(filepath.Join(dir, "highlight.json"), info); err != nil {
+			return err
+		}
+
+		d.Add(reel.Items...)
+		if err := d.Wait(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// feed walks through a paginated feed, and queues all new items for download.
+// Unless a full archive is requested, pagination will stop at the first
+// unpinned post that is not newer than the newest post of the previous run.
+func (a *archiver) feed(section string, next func() bool, items func() []*Item, ferr func() error) error {
+	d, err := a.downloader(section, filepath.Join(a.dir, section))
+	if err != nil {
+		return err
+	}
+
+	newest := a.state.Newest[section]
+	latest := newest
+	n := 0
+	done := false
+	for !done && next() {
+		page := items()
+		if len(page) < n {
+			// Some feeds replace the items on every page
+			n = 0
+		}
+		for _, item := range page[n:] {
+			if !a.opts.Full && newest > 0 && item.TakenAt <= newest && !item.IsPinned() {
+				done = true
+				break
+			}
+			if item.TakenAt > latest {
+				latest = item.TakenAt
+			}
+			d.Add(item)
+		}
+		n = len(page)
+	}
+
+	err = d.Wait()
+	if ferr := ignoreNoMore(ferr()); ferr != nil && !done {
+		return ferr
+	}
+	if err != nil {
+		// Don't advance the state, so failed items will be retried next time
+		return err
+	}
+
+	a.mu.Lock()
+	a.state.Newest[section] = latest
+	a.mu.Unlock()
+	return nil
+}
+
+// downloader creates a Downloader for a section, that writes a sidecar for
+// every archived item.
+func (a *archiver) downloader(section, dir string) (*Downloader, error) {
+	d, err := a.insta.NewDownloader(dir, a.opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+	d.NameTemplate = archiveNameTmpl
+	d.OnDone = func(item *Item, files []string, skipped bool, err error) {
+		if err == nil {
+			err = a.sidecar(d, item, files, skipped)
+			if err != nil {
+				// Fail the section, so the item is retried on the next run
+				d.fail(fmt.Errorf("failed to write sidecar of %s: %w", item.GetID(), err))
+			}
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		switch {
+		case err != nil:
+			a.report.Failed[section]++
+			a.insta.warnHandler(fmt.Sprintf("Failed to archive %s: %v", item.GetID(), err))
+		case skipped:
+			a.report.Skipped[section]++
+		default:
+			a.report.Downloaded[section]++
+		}
+	}
+	return d, nil
+}
+
+// sidecar writes the metadata of an item next to its media files. Sidecars
+// of skipped items are only refreshed on a full archive.
+func (a *archiver) sidecar(d *Downloader, item *Item, files []string, skipped bool) error {
+	path := filepath.Join(d.Dir, d.fileName(item, item, 0)+".json")
+	if skipped && !a.opts.Full {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+	}
+
+	m := ArchivedMedia{
+		ID:           item.GetID(),
+		Code:         item.Code,
+		URL:          item.URL(),
+		Username:     item.User.Username,
+		TakenAt:      item.TakenAt,
+		Type:         item.MediaToString(),
+		Caption:      item.Caption.Text,
+		LikeCount:    item.Likes,
+		CommentCount: item.CommentCount,
+		ViewCount:    item.ViewCount,
+		Files:        files,
+		ArchivedAt:   time.Now().Unix(),
+	}
+	if item.Location.ID != 0 || item.Location.Name != "" {
+		m.Location = &ArchivedLocation{
+			ID:   item.Location.ID,
+			Name: item.Location.Name,
+			Lat:  item.Location.Lat,
+			Lng:  item.Location.Lng,
+		}
+	}
+	m.TaggedUsers = item.taggedUsernames()
+
+	if a.opts.Comments && item.Comments != nil {
+		comments, err := a.comments(item)
+		if err != nil {
+			return err
+		}
+		m.Comments = comments
+		if len(comments) > m.CommentCount {
+			m.CommentCount = len(comments)
+		}
+	}
+	return writeJSON(path, m)
+}
+
+func (a *archiver) comments(item *Item) ([]ArchivedComment, error) {
+	var comments []ArchivedComment
+	item.Comments.Sync()
+	for item.Comments.Next() {
+		for _, c := range item.Comments.Items {
+			comments = append(comments, ArchivedComment{
+				ID:        toString(c.ID),
+				Username:  c.User.Username,
+				Text:      c.Text,
+				CreatedAt: c.CreatedAt,
+				LikeCount: c.CommentLikeCount,
+			})
+			if a.opts.MaxComments > 0 && len(comments) >= a.opts.MaxComments {
+				return comments, nil
+			}
+		}
+	}
+	return comments, ignoreNoMore(item.Comments.Error())
+}
+
+func (a *archiver) loadState() error {
+	a.state = &archiveState{Newest: make(map[string]int64)}
+	b, err := os.ReadFile(filepath.Join(a.dir, archiveStateName))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, a.state); err != nil {
+		return err
+	}
+	if a.state.Newest == nil {
+		a.state.Newest = make(map[string]int64)
+	}
+	return nil
+}
+
+func (a *archiver) saveState() error {
+	a.state.Updated = time.Now().Unix()
+	return writeJSON(filepath.Join(a.dir, archiveStateName), a.state)
+}
+
+func (item *Item) taggedUsernames() []string {
+	var users []string
+	add := func(tags Tag) {
+		for _, t := range tags.In {
+			users = append(users, t.User.Username)
+		}
+	}
+	add(item.Tags)
+	for i := range item.CarouselMedia {
+		add(item.CarouselMedia[i].Tags)
+	}
+	return users
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".part"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
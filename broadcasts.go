@@ -308,12 +308,20 @@ func (br *Broadcast) GetLiveChaining() ([]*Broadcast, error) {
 	return resp.Broadcasts, nil
 }
 
-func (br *Broadcast) DownloadCoverFrame() ([]byte, error) {
-	if br.CoverFrameURL == "" {
+// DownloadCoverFrame downloads the cover frame of the broadcast. A
+// MediaSelector can be passed, but as Instagram only provides a single
+// version of the cover frame, it can only reject it.
+func (br *Broadcast) DownloadCoverFrame(sel ...MediaSelector) ([]byte, error) {
+	url := br.insta.selectMedia([]MediaVersion{{
+		Width:  br.Dimensions.Width,
+		Height: br.Dimensions.Height,
+		URL:    br.CoverFrameURL,
+	}}, sel)
+	if url == "" {
 		return nil, ErrNoMedia
 	}
 
-	b, err := br.insta.download(br.CoverFrameURL)
+	b, err := br.insta.download(url)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,208 @@
+package goinsta
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// ConformMode is the way uploaded images are made to fit within Instagram's
+// aspect ratio limits, see UploadOptions.Conform.
+type ConformMode int
+
+const (
+	// Upload media as is
+	ConformNone ConformMode = iota
+	// Crop images to the closest allowed aspect ratio
+	ConformCrop
+	// Pad images with the background color to the closest allowed aspect ratio
+	ConformPad
+)
+
+// Gravity is the part of an image that is kept when cropping, or the side
+// the image is placed at when padding. Top and bottom only apply to images
+// that are too tall, left and right to images that are too wide.
+type Gravity int
+
+const (
+	GravityCenter Gravity = iota
+	GravityTop
+	GravityBottom
+	GravityLeft
+	GravityRight
+)
+
+// Instagram's media limits
+const (
+	maxImageWidth = 1440
+
+	feedMinRatio   = 4.0 / 5.0
+	feedMaxRatio   = 1.91
+	storyRatio     = 9.0 / 16.0
+	igtvMaxRatio   = 16.0 / 9.0
+	ratioTolerance = 0.01
+)
+
+// ConstraintError is returned when media doesn't meet one of Instagram's
+// limits. Min or Max is 0 if there is no lower or upper limit.
+type ConstraintError struct {
+	Constraint string
+	Unit       string
+	Value      float64
+	Min        float64
+	Max        float64
+}
+
+func (e ConstraintError) Error() string {
+	f := func(v float64) string {
+		return fmt.Sprintf("%.4g%s", v, e.Unit)
+	}
+	switch {
+	case e.Min == e.Max:
+		return fmt.Sprintf("%s %s does not match the required %s", e.Constraint, f(e.Value), f(e.Min))
+	case e.Max == 0:
+		return fmt.Sprintf("%s %s is below the minimum of %s", e.Constraint, f(e.Value), f(e.Min))
+	case e.Min == 0:
+		return fmt.Sprintf("%s %s exceeds the maximum of %s", e.Constraint, f(e.Value), f(e.Max))
+	}
+	return fmt.Sprintf("%s %s is outside the allowed range of %s to %s", e.Constraint, f(e.Value), f(e.Min), f(e.Max))
+}
+
+// ratioLimits returns the minimum and maximum aspect ratio (width / height)
+// of the media being uploaded.
+func (o *UploadOptions) ratioLimits(video bool) (float64, float64) {
+	switch {
+	case o.IsStory:
+		return storyRatio, storyRatio
+	case !video || o.isSidecar || o.kind() == KindFeedVideo:
+		return feedMinRatio, feedMaxRatio
+	case o.kind() == KindIGTV:
+		return storyRatio, igtvMaxRatio
+	}
+	// Reels can be 9:16
+	return storyRatio, feedMaxRatio
+}
+
+// checkRatio returns a ConstraintError if the aspect ratio is not within the
+// limits, allowing for a small rounding difference.
+func checkRatio(width, height int, min, max float64) error {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+	ratio := float64(width) / float64(height)
+	if ratio < min*(1-ratioTolerance) || ratio > max*(1+ratioTolerance) {
+		return ConstraintError{Constraint: "aspect ratio", Value: ratio, Min: min, Max: max}
+	}
+	return nil
+}
+
+// conformVideo validates the video dimensions, as videos can't be cropped
+// or padded. Without Conform videos are uploaded as is. ValidateUpload runs
+// the same check.
+func (o *UploadOptions) conformVideo() error {
+	if o.Conform == ConformNone {
+		return nil
+	}
+	min, max := o.ratioLimits(true)
+	return checkRatio(o.width, o.height, min, max)
+}
+
+// conformImage crops or pads o.buf to the closest allowed aspect ratio, and
+// downscales it to the maximum width. o.buf needs to be a JPEG.
+func (o *UploadOptions) conformImage() error {
+	if o.Conform == ConformNone {
+		return nil
+	}
+	width, height, err := getImageSize(o.buf.Bytes())
+	if err != nil {
+		return err
+	}
+	min, max := o.ratioLimits(false)
+	if checkRatio(width, height, min, max) == nil && width <= maxImageWidth {
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(o.buf.Bytes()))
+	if err != nil {
+		return err
+	}
+	background := o.Background
+	if background == nil {
+		background = color.White
+	}
+	img = fitRatio(img, min, max, o.Conform, o.Gravity, background)
+
+	if b := img.Bounds(); b.Dx() > maxImageWidth {
+		h := int(math.Round(float64(b.Dy()) * maxImageWidth / float64(b.Dx())))
+		dst := image.NewRGBA(image.Rect(0, 0, maxImageWidth, h))
+		xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+		img = dst
+	}
+
+	quality := o.JPEGQuality
+	if quality <= 0 || quality > 100 {
+		quality = defaultJPEGQuality
+	}
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return err
+	}
+	o.buf = buf
+	return nil
+}
+
+// fitRatio crops or pads the image to the closest aspect ratio between min
+// and max.
+func fitRatio(img image.Image, min, max float64, mode ConformMode, gravity Gravity, background color.Color) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	ratio := float64(w) / float64(h)
+
+	// Size of the result
+	nw, nh := w, h
+	switch {
+	case ratio > max && mode == ConformCrop:
+		nw = int(math.Floor(float64(h) * max))
+	case ratio > max:
+		nh = int(math.Ceil(float64(w) / max))
+	case ratio < min && mode == ConformCrop:
+		nh = int(math.Floor(float64(w) / min))
+	case ratio < min:
+		nw = int(math.Ceil(float64(h) * min))
+	default:
+		return img
+	}
+
+	// Offset of the result in the image when cropping, or of the image in
+	// the result when padding
+	offset := func(size, target int, start, end Gravity) int {
+		d := size - target
+		if d < 0 {
+			d = -d
+		}
+		switch gravity {
+		case start:
+			return 0
+		case end:
+			return d
+		}
+		return d / 2
+	}
+	dx := offset(w, nw, GravityLeft, GravityRight)
+	dy := offset(h, nh, GravityTop, GravityBottom)
+
+	dst := image.NewRGBA(image.Rect(0, 0, nw, nh))
+	if mode == ConformCrop {
+		draw.Draw(dst, dst.Bounds(), img, b.Min.Add(image.Pt(dx, dy)), draw.Src)
+		return dst
+	}
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+	draw.Draw(dst, image.Rect(dx, dy, dx+w, dy+h), img, b.Min, draw.Over)
+	return dst
+}
@@ -9,6 +9,7 @@ const (
 	instaAPIUrlb   = "https://b.i.instagram.com/api/v1/"
 	instaAPIUrlv2  = "https://i.instagram.com/api/v2/"
 	instaAPIUrlv2b = "https://b.i.instagram.com/api/v2/"
+	instaWebURL    = "https://www.instagram.com/"
 
 	// header values
 	bloksVerID         = "927f06374b80864ae6a0b04757048065714dc50ff15d2b8b3de8d0b6de961649"
@@ -143,6 +144,9 @@ const (
 
 	// Users
 	urlUserArchived           = "feed/only_me_feed/"
+	urlStoryArchive           = "archive/reel/day_shells/"
+	urlAccountInsights        = "insights/account_organic_insights/"
+	urlMediaInsights          = "insights/media_organic_insights/%s/"
 	urlUserByName             = "users/%s/usernameinfo/"
 	urlUserByID               = "users/%s/info/"
 	urlUserBlock              = "friendships/block/%d/"
@@ -191,6 +195,9 @@ const (
 	urlMediaUnlike       = "media/%s/unlike/"
 	urlMediaSave         = "media/%s/save/"
 	urlMediaUnsave       = "media/%s/unsave/"
+	urlMediaEdit         = "media/%s/edit_media/"
+	urlMediaArchive      = "media/%s/only_me/"
+	urlMediaUnarchive    = "media/%s/undo_only_me/"
 	urlMediaSeen         = "media/seen/"
 	urlMediaLikers       = "media/%s/likers/"
 	urlMediaBlocked      = "media/blocked/"
@@ -229,25 +236,54 @@ const (
 	urlActivitySeen      = "news/inbox_seen/"
 
 	// Inbox
-	urlInbox             = "direct_v2/inbox/"
-	urlInboxPending      = "direct_v2/pending_inbox/"
-	urlInboxSend         = "direct_v2/threads/broadcast/text/"
-	urlInboxSendLike     = "direct_v2/threads/broadcast/like/"
-	urlReplyStory        = "direct_v2/threads/broadcast/reel_share/"
-	urlGetByParticipants = "direct_v2/threads/get_by_participants/"
-	urlInboxThread       = "direct_v2/threads/%s/"
-	urlInboxMute         = "direct_v2/threads/%s/mute/"
-	urlInboxUnmute       = "direct_v2/threads/%s/unmute/"
-	urlInboxGetItems     = "direct_v2/threads/%s/get_items/"
-	urlInboxMsgSeen      = "direct_v2/threads/%s/items/%s/seen/"
-	urlInboxApprove      = "direct_v2/threads/%s/approve/"
-	urlInboxHide         = "direct_v2/threads/%s/hide/"
+	urlInbox              = "direct_v2/inbox/"
+	urlInboxPending       = "direct_v2/pending_inbox/"
+	urlInboxSend          = "direct_v2/threads/broadcast/text/"
+	urlInboxSendLike      = "direct_v2/threads/broadcast/like/"
+	urlInboxSendLink      = "direct_v2/threads/broadcast/link/"
+	urlInboxSendPhoto     = "direct_v2/threads/broadcast/configure_photo/"
+	urlInboxSendVideo     = "direct_v2/threads/broadcast/configure_video/"
+	urlInboxSendVoice     = "direct_v2/threads/broadcast/share_voice/"
+	urlInboxShareMedia    = "direct_v2/threads/broadcast/media_share/"
+	urlInboxShareProfile  = "direct_v2/threads/broadcast/profile/"
+	urlInboxShareHashtag  = "direct_v2/threads/broadcast/hashtag/"
+	urlInboxShareLocation = "direct_v2/threads/broadcast/location/"
+	urlInboxReact         = "direct_v2/threads/broadcast/reaction/"
+	urlInboxForward       = "direct_v2/threads/broadcast/forward/"
+	urlInboxUnsend        = "direct_v2/threads/%s/items/%s/delete/"
+	urlInboxCreateGroup   = "direct_v2/create_group_thread/"
+	urlInboxAddUsers      = "direct_v2/threads/%s/add_user/"
+	urlInboxRemoveUsers   = "direct_v2/threads/%s/remove_users/"
+	urlInboxUpdateTitle   = "direct_v2/threads/%s/update_title/"
+	urlInboxLeave         = "direct_v2/threads/%s/leave/"
+	urlInboxAddAdmins     = "direct_v2/threads/%s/add_admins/"
+	urlInboxRemoveAdmins  = "direct_v2/threads/%s/remove_admins/"
+	urlInboxApprovalOn    = "direct_v2/threads/%s/approval_required_for_new_members/"
+	urlInboxApprovalOff   = "direct_v2/threads/%s/approval_not_required_for_new_members/"
+	urlInboxArchive       = "direct_v2/threads/%s/archive/"
+	urlInboxUnarchive     = "direct_v2/threads/%s/unarchive/"
+	urlInboxPin           = "direct_v2/threads/%s/pin/"
+	urlInboxUnpin         = "direct_v2/threads/%s/unpin/"
+	urlReplyStory         = "direct_v2/threads/broadcast/reel_share/"
+	urlGetByParticipants  = "direct_v2/threads/get_by_participants/"
+	urlInboxThread        = "direct_v2/threads/%s/"
+	urlInboxMute          = "direct_v2/threads/%s/mute/"
+	urlInboxUnmute        = "direct_v2/threads/%s/unmute/"
+	urlInboxGetItems      = "direct_v2/threads/%s/get_items/"
+	urlInboxMsgSeen       = "direct_v2/threads/%s/items/%s/seen/"
+	urlInboxApprove       = "direct_v2/threads/%s/approve/"
+	urlInboxHide          = "direct_v2/threads/%s/hide/"
 
 	// Tags
 	urlTagInfo    = "tags/%s/info/"
 	urlTagStories = "tags/%s/story/"
 	urlTagContent = "tags/%s/sections/"
 
+	// Music
+	urlMusicSearch   = "music/audio_global_search/"
+	urlMusicTrending = "music/trending/"
+	urlMusicReels    = "clips/music/"
+
 	// Upload
 	urlUploadPhoto      = "rupload_igphoto/%s"
 	urlUploadVideo      = "rupload_igvideo/%s"
@@ -285,12 +321,24 @@ var (
 	ErrInvalidCode = errors.New("the security code provided is incorrect")
 
 	// Upload Errors
-	ErrInvalidFormat      = errors.New("invalid file type, please use one of jpeg, jpg, mp4")
-	ErrInvalidImage       = errors.New("invalid file type, please use a jpeg or jpg image")
-	ErrCarouselType       = ErrInvalidImage
-	ErrCarouselMediaLimit = errors.New("carousel media limit of 10 exceeded")
-	ErrStoryBadMediaType  = errors.New("when uploading multiple items to your story at once, all have to be mp4")
-	ErrStoryMediaTooLong  = errors.New("story media must not exceed 15 seconds per item")
+	ErrInvalidFormat        = errors.New("invalid file type, please use one of jpeg, jpg, png, webp, gif, heic, mp4")
+	ErrInvalidImage         = errors.New("invalid file type, please use one of jpeg, jpg, png, webp, gif, heic")
+	ErrNoHEICDecoder        = errors.New("no HEIC decoder available, import github.com/Davincible/goinsta/v3/heic to register one")
+	ErrCarouselType         = ErrInvalidFormat
+	ErrCarouselMediaLimit   = errors.New("carousel media limit of 10 exceeded")
+	ErrEditAlbumIndex       = errors.New("more album edits provided than the carousel has items")
+	ErrStoryBadMediaType    = errors.New("when uploading multiple items to your story at once, all have to be mp4")
+	ErrStoryMediaTooLong    = errors.New("story videos must not exceed 60 seconds per item")
+	ErrUploadResumeMismatch = errors.New("upload state to resume does not match the file")
+	ErrNoVideoTrack         = errors.New("the file does not contain a video track")
+	ErrUnsupportedCodec     = errors.New("unsupported codec, videos need to be h264 or hevc with aac audio")
+	ErrStickerNotStory      = errors.New("stickers can only be added to stories")
+	ErrInvalidSticker       = errors.New("invalid story sticker")
+	ErrUploadKind           = errors.New("the media can't be uploaded as this kind")
+	ErrIGTVNoTitle          = errors.New("IGTV uploads need a title")
+	ErrInvalidPreviewCrop   = errors.New("preview crop needs to be within 0 and 1, with left < right and top < bottom")
+	ErrInvalidUserTag       = errors.New("user tags need a user with an ID")
+	ErrAudioNotReel         = errors.New("audio can only be added to reels, use a MusicSticker for stories")
 
 	// Search Errors
 	ErrSearchUserNotFound = errors.New("User not found in search result")
@@ -301,13 +349,24 @@ var (
 	)
 
 	// Feed Errors
-	ErrInvalidTab   = errors.New("invalid tab, please select top or recent")
-	ErrNoMore       = errors.New("no more posts availible, page end has been reached")
-	ErrNotHighlight = errors.New("unable to sync, Reel is not of type highlight")
-	ErrMediaDeleted = errors.New("sorry, this media has been deleted")
+	ErrInvalidTab     = errors.New("invalid tab, please select top or recent")
+	ErrNoMore         = errors.New("no more posts availible, page end has been reached")
+	ErrNotHighlight   = errors.New("unable to sync, Reel is not of type highlight or archive day")
+	ErrMediaDeleted   = errors.New("sorry, this media has been deleted")
+	ErrInvalidShortID = errors.New("invalid short id, unable to convert to a media id")
 
 	// Inbox
 	ErrConvNotPending = errors.New("unable to perform action, conversation is not pending")
+	ErrNoLink         = errors.New("the message does not contain a link")
+	ErrNoAudioTrack   = errors.New("the file does not contain an audio track")
+	ErrNotOwnItem     = errors.New("only your own messages can be unsent")
+	ErrGroupTooSmall  = errors.New("a group needs at least two other users")
+	ErrExportFormat   = errors.New("unknown export format")
+
+	// Realtime
+	ErrRealtimeRunning    = errors.New("the realtime client is already running, or has stopped")
+	ErrRealtimeNotCreated = errors.New("the realtime client must be created with Instagram.NewRealtime")
+	ErrRealtimeRefused    = errors.New("the realtime connection was refused, please log in again")
 
 	// Misc
 	ErrByteIndexNotFound = errors.New("failed to index byte slice, delim not found")
@@ -318,9 +377,32 @@ var (
 	ErrNoValidLogin    = errors.New("no valid login found")
 	ErrNoProfilePicURL = errors.New("no profile picture url was found. Please fetch the profile first")
 
+	// Insights
+	ErrNotProfessionalAccount = errors.New("insights are only available for business and creator accounts")
+
 	// Users
 	ErrNoPendingFriendship = errors.New("unable to approve or ignore friendship for user, as there is no pending friendship request")
 
+	// Downloads
+	ErrUnsupportedFeed       = errors.New("unable to download feed, feed type is not supported")
+	ErrDownloadStatus        = errors.New("failed to download media, unexpected status code")
+	ErrDownloaderClosed      = errors.New("unable to add items, the downloader has already been waited on")
+	ErrDownloadNameCollision = errors.New("the name template gives different media the same file name")
+
+	// DASH
+	ErrNoDashManifest       = errors.New("media has no dash manifest")
+	ErrDashNoRepresentation = errors.New("no dash representation selected to download")
+	ErrInvalidMP4           = errors.New("invalid or unsupported mp4 file")
+
+	// Links
+	ErrInvalidURL     = errors.New("invalid url, not an instagram link")
+	ErrUnsupportedURL = errors.New("unsupported instagram link")
+
+	// Scheduler
+	ErrScheduleNoMedia  = errors.New("a scheduled post needs at least one file")
+	ErrScheduleNotFound = errors.New("scheduled post not found")
+	ErrSchedulerRunning = errors.New("the scheduler is already running")
+
 	// Headless
 	ErrChromeNotFound = errors.New("to solve challenges a (headless) Chrome browser is used, but none was found. Please install Chromium or Google Chrome, and try again")
 )
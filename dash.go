@@ -0,0 +1,398 @@
+package goinsta
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DashManifest is a parsed MPEG-DASH manifest, as found in
+// Item.VideoDashManifest and Broadcast.DashManifest. The representations
+// are sorted by bandwidth, highest first.
+type DashManifest struct {
+	Duration time.Duration
+	Video    []*DashRepresentation
+	Audio    []*DashRepresentation
+}
+
+// DashRepresentation is a single quality of a video or audio stream.
+type DashRepresentation struct {
+	ID           string
+	Type         string // "video" or "audio"
+	MimeType     string
+	Codecs       string
+	Bandwidth    int
+	Width        int
+	Height       int
+	FrameRate    string
+	SampleRate   int
+	QualityLabel string
+
+	// Segments are the URLs that together form the fragmented MP4 stream.
+	// Usually this is a single URL, for live streams the first URL is the
+	// initialization segment.
+	Segments []string
+}
+
+type mpdManifest struct {
+	Duration string      `xml:"mediaPresentationDuration,attr"`
+	BaseURL  string      `xml:"BaseURL"`
+	Periods  []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	Duration string             `xml:"duration,attr"`
+	BaseURL  string             `xml:"BaseURL"`
+	Sets     []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	ContentType     string              `xml:"contentType,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+	Representations []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID              string              `xml:"id,attr"`
+	MimeType        string              `xml:"mimeType,attr"`
+	Codecs          string              `xml:"codecs,attr"`
+	Bandwidth       int                 `xml:"bandwidth,attr"`
+	Width           int                 `xml:"width,attr"`
+	Height          int                 `xml:"height,attr"`
+	FrameRate       string              `xml:"frameRate,attr"`
+	SampleRate      int                 `xml:"audioSamplingRate,attr"`
+	QualityLabel    string              `xml:"FBQualityLabel,attr"`
+	BaseURL         string              `xml:"BaseURL"`
+	SegmentTemplate *mpdSegmentTemplate `xml:"SegmentTemplate"`
+}
+
+type mpdSegmentTemplate struct {
+	Timescale      int64  `xml:"timescale,attr"`
+	Initialization string `xml:"initialization,attr"`
+	Media          string `xml:"media,attr"`
+	StartNumber    *int64 `xml:"startNumber,attr"`
+	Duration       int64  `xml:"duration,attr"`
+	Timeline       []struct {
+		T *int64 `xml:"t,attr"`
+		D int64  `xml:"d,attr"`
+		R int64  `xml:"r,attr"`
+	} `xml:"SegmentTimeline>S"`
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:([\d.]+)S)?)?$`)
+
+// ParseDashManifest parses an MPEG-DASH manifest.
+func ParseDashManifest(manifest string) (*DashManifest, error) {
+	return parseDashManifest(manifest, "")
+}
+
+// parseDashManifest parses a manifest, with relative URLs resolved against
+// base, which can be empty.
+func parseDashManifest(manifest, base string) (*DashManifest, error) {
+	if manifest == "" {
+		return nil, ErrNoDashManifest
+	}
+	mpd := mpdManifest{}
+	if err := xml.Unmarshal([]byte(manifest), &mpd); err != nil {
+		return nil, err
+	}
+
+	m := &DashManifest{
+		Duration: parseISODuration(mpd.Duration),
+	}
+	root := resolveURL(base, mpd.BaseURL)
+	for _, period := range mpd.Periods {
+		periodBase := resolveURL(root, period.BaseURL)
+		duration := parseISODuration(period.Duration)
+		if duration == 0 {
+			duration = m.Duration
+		}
+
+		for _, set := range period.Sets {
+			setBase := resolveURL(periodBase, set.BaseURL)
+			for _, r := range set.Representations {
+				rep := &DashRepresentation{
+					ID:           r.ID,
+					MimeType:     firstNonEmpty(r.MimeType, set.MimeType),
+					Codecs:       firstNonEmpty(r.Codecs, set.Codecs),
+					Bandwidth:    r.Bandwidth,
+					Width:        r.Width,
+					Height:       r.Height,
+					FrameRate:    r.FrameRate,
+					SampleRate:   r.SampleRate,
+					QualityLabel: r.QualityLabel,
+				}
+				rep.Type = set.ContentType
+				if rep.Type == "" {
+					rep.Type = strings.Split(rep.MimeType, "/")[0]
+				}
+
+				tmpl := r.SegmentTemplate
+				if tmpl == nil {
+					tmpl = set.SegmentTemplate
+				}
+				repBase := resolveURL(setBase, r.BaseURL)
+				if tmpl != nil {
+					rep.Segments = tmpl.segments(repBase, r, duration)
+				} else if repBase != "" {
+					rep.Segments = []string{repBase}
+				}
+
+				switch rep.Type {
+				case "video":
+					m.Video = append(m.Video, rep)
+				case "audio":
+					m.Audio = append(m.Audio, rep)
+				}
+			}
+		}
+	}
+
+	for _, reps := range [][]*DashRepresentation{m.Video, m.Audio} {
+		sort.SliceStable(reps, func(i, j int) bool {
+			return reps[i].Bandwidth > reps[j].Bandwidth
+		})
+	}
+	return m, nil
+}
+
+// segments lists the URLs of a segment template, starting with the
+// initialization segment.
+func (tmpl *mpdSegmentTemplate) segments(base string, r mpdRepresentation, duration time.Duration) []string {
+	fill := func(s string, number, t int64) string {
+		return strings.NewReplacer(
+			"$RepresentationID$", r.ID,
+			"$Bandwidth$", strconv.Itoa(r.Bandwidth),
+			"$Number$", strconv.FormatInt(number, 10),
+			"$Time$", strconv.FormatInt(t, 10),
+			"$$", "$",
+		).Replace(s)
+	}
+
+	var urls []string
+	if tmpl.Initialization != "" {
+		urls = append(urls, resolveURL(base, fill(tmpl.Initialization, 0, 0)))
+	}
+
+	timescale := tmpl.Timescale
+	if timescale == 0 {
+		timescale = 1
+	}
+	end := int64(duration.Seconds() * float64(timescale))
+	number := int64(1)
+	if tmpl.StartNumber != nil {
+		number = *tmpl.StartNumber
+	}
+
+	if len(tmpl.Timeline) > 0 {
+		t := int64(0)
+		for i, s := range tmpl.Timeline {
+			if s.T != nil {
+				t = *s.T
+			}
+			repeat := s.R
+			if repeat < 0 {
+				// Repeat until the next entry, or the end of the period
+				next := end
+				if i+1 < len(tmpl.Timeline) && tmpl.Timeline[i+1].T != nil {
+					next = *tmpl.Timeline[i+1].T
+				}
+				repeat = 0
+				if s.D > 0 && next > t {
+					repeat = (next-t+s.D-1)/s.D - 1
+				}
+			}
+			for j := int64(0); j <= repeat; j++ {
+				urls = append(urls, resolveURL(base, fill(tmpl.Media, number, t)))
+				number++
+				t += s.D
+			}
+		}
+	} else if tmpl.Duration > 0 {
+		for t := int64(0); t < end; t += tmpl.Duration {
+			urls = append(urls, resolveURL(base, fill(tmpl.Media, number, t)))
+			number++
+		}
+	}
+	return urls
+}
+
+// BestVideo returns the video representation with the highest bandwidth, not
+// exceeding maxBandwidth bits per second. If maxBandwidth is zero, the best
+// quality is returned. If all representations exceed the maximum, the one
+// with the lowest bandwidth is returned.
+func (m *DashManifest) BestVideo(maxBandwidth int) *DashRepresentation {
+	return bestRepresentation(m.Video, maxBandwidth)
+}
+
+// BestAudio returns the best audio representation, see BestVideo.
+func (m *DashManifest) BestAudio(maxBandwidth int) *DashRepresentation {
+	return bestRepresentation(m.Audio, maxBandwidth)
+}
+
+func bestRepresentation(reps []*DashRepresentation, maxBandwidth int) *DashRepresentation {
+	if len(reps) == 0 {
+		return nil
+	}
+	for _, r := range reps {
+		if maxBandwidth <= 0 || r.Bandwidth <= maxBandwidth {
+			return r
+		}
+	}
+	return reps[len(reps)-1]
+}
+
+// GetDashManifest parses the DASH manifest of a video item.
+func (item *Item) GetDashManifest() (*DashManifest, error) {
+	return ParseDashManifest(item.VideoDashManifest)
+}
+
+// DownloadDash downloads the video of the item from its DASH manifest, and
+// saves it as an MP4 file at dst. This allows getting a higher quality than
+// the one available through Item.Videos.
+//
+// The best video representation within maxBandwidth bits per second is used,
+// set maxBandwidth to zero for the best available quality. The best audio
+// track is always included.
+func (item *Item) DownloadDash(dst string, maxBandwidth int) error {
+	m, err := item.GetDashManifest()
+	if err != nil {
+		return err
+	}
+	return item.insta.DownloadDash(dst, m.BestVideo(maxBandwidth), m.BestAudio(0))
+}
+
+// GetDashManifest parses the DASH manifest of a broadcast.
+func (br *Broadcast) GetDashManifest() (*DashManifest, error) {
+	return parseDashManifest(br.DashManifest, br.DashPlaybackURL)
+}
+
+// DownloadDash fetches the given video and audio representations, and muxes
+// them into a single MP4 file at dst. Either representation can be nil, to
+// only save the video or audio stream.
+func (insta *Instagram) DownloadDash(dst string, video, audio *DashRepresentation) error {
+	if video == nil && audio == nil {
+		return ErrDashNoRepresentation
+	}
+
+	var streams [2][]byte
+	for i, rep := range []*DashRepresentation{video, audio} {
+		if rep == nil {
+			continue
+		}
+		b, err := insta.downloadSegments(rep)
+		if err != nil {
+			return errors.Wrapf(err, "failed to download %s representation %s", rep.Type, rep.ID)
+		}
+		streams[i] = b
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o777); err != nil {
+		return err
+	}
+	tmp := dst + ".part"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	err = muxFragmented(file, streams[0], streams[1])
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func (insta *Instagram) downloadSegments(rep *DashRepresentation) ([]byte, error) {
+	if len(rep.Segments) == 0 {
+		return nil, ErrNoMedia
+	}
+
+	var b []byte
+	for _, u := range rep.Segments {
+		resp, err := insta.c.Get(u)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return nil, errors.Wrapf(ErrDownloadStatus, "status code %d", resp.StatusCode)
+		}
+		segment, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, segment...)
+	}
+	return b, nil
+}
+
+// String returns a short description of the representation.
+func (r *DashRepresentation) String() string {
+	if r.Type == "audio" {
+		return fmt.Sprintf("audio %s %dHz %dkbps", r.Codecs, r.SampleRate, r.Bandwidth/1000)
+	}
+	return fmt.Sprintf("video %s %dx%d %dkbps", r.Codecs, r.Width, r.Height, r.Bandwidth/1000)
+}
+
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if base == "" {
+		return ref
+	}
+	if ref == "" {
+		return base
+	}
+	b, err := neturl.Parse(base)
+	if err != nil {
+		return ref
+	}
+	r, err := neturl.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return b.ResolveReference(r).String()
+}
+
+func parseISODuration(s string) time.Duration {
+	parts := isoDuration.FindStringSubmatch(s)
+	if parts == nil {
+		return 0
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if n, err := strconv.Atoi(parts[i+1]); err == nil {
+			d += time.Duration(n) * unit
+		}
+	}
+	if sec, err := strconv.ParseFloat(parts[4], 64); err == nil {
+		d += time.Duration(sec * float64(time.Second))
+	}
+	return d
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,359 @@
+package goinsta
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+)
+
+// Instagram draws a waveform of voice messages, sampled at this rate
+const voiceWaveformRate = 10
+
+var rxpLinks = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+|\bwww\.[^\s<>"]+`)
+
+// SendPhoto sends an image to the conversation. Images other than jpeg are
+// converted to jpeg before sending.
+func (c *Conversation) SendPhoto(photo io.Reader) (*InboxItem, error) {
+	o, err := c.newDirectUpload(photo)
+	if err != nil {
+		return nil, err
+	}
+	if !isImage(detectContentType(o.buf.Bytes())) {
+		return nil, ErrInvalidImage
+	}
+	if err := o.convertImage(); err != nil {
+		return nil, err
+	}
+	if err := o.uploadPhoto(); err != nil {
+		return nil, err
+	}
+
+	return c.sendItem(
+		urlInboxSendPhoto,
+		&InboxItem{Type: "media"},
+		map[string]string{
+			"upload_id":               o.uploadID,
+			"allow_full_aspect_ratio": "true",
+		},
+	)
+}
+
+// SendVideo sends an mp4 video of up to 60 seconds to the conversation.
+func (c *Conversation) SendVideo(video io.Reader) (*InboxItem, error) {
+	o, err := c.newDirectUpload(video)
+	if err != nil {
+		return nil, err
+	}
+	if detectContentType(o.buf.Bytes()) != "video/mp4" {
+		return nil, ErrInvalidFormat
+	}
+	if err := o.uploadVideo(); err != nil {
+		return nil, err
+	}
+
+	return c.sendItem(
+		urlInboxSendVideo,
+		&InboxItem{Type: "media"},
+		map[string]string{
+			"upload_id":    o.uploadID,
+			"video_result": "",
+			"sampled":      "true",
+		},
+	)
+}
+
+// SendVoice sends a voice message, which needs to be an mp4 (m4a) file with
+// an aac audio track. As the audio isn't decoded, the waveform shown is flat.
+func (c *Conversation) SendVoice(audio io.Reader) (*InboxItem, error) {
+	o, err := c.newDirectUpload(audio)
+	if err != nil {
+		return nil, err
+	}
+	info, err := inspectMP4(bytes.NewReader(o.buf.Bytes()))
+	if info == nil {
+		return nil, err
+	}
+	if !info.HasAudio {
+		return nil, ErrNoAudioTrack
+	}
+	if info.AudioCodec != "aac" {
+		return nil, ErrUnsupportedCodec
+	}
+	if err := o.uploadVoice(info.Duration); err != nil {
+		return nil, err
+	}
+
+	samples := int(info.Duration.Seconds()*voiceWaveformRate) + 1
+	waveform := make([]float64, samples)
+	for i := range waveform {
+		waveform[i] = 0.5
+	}
+	b, err := json.Marshal(waveform)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &InboxItem{Type: "voice_media", VoiceMedia: &VoiceMedia{}}
+	msg.VoiceMedia.Media.Audio.Duration = o.duration
+	msg.VoiceMedia.Media.Audio.WaveformData = waveform
+	msg.VoiceMedia.Media.Audio.WaveformSamplingFrequencyHz = voiceWaveformRate
+	return c.sendItem(
+		urlInboxSendVoice,
+		msg,
+		map[string]string{
+			"upload_id":                      o.uploadID,
+			"waveform":                       string(b),
+			"waveform_sampling_frequency_hz": toString(voiceWaveformRate),
+		},
+	)
+}
+
+// SendLink sends a message with a link preview. The text needs to contain at
+// least one link, of which Instagram shows a preview.
+func (c *Conversation) SendLink(text string) (*InboxItem, error) {
+	links := rxpLinks.FindAllString(text, -1)
+	if len(links) == 0 {
+		return nil, ErrNoLink
+	}
+	b, err := json.Marshal(links)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &InboxItem{Type: "link"}
+	msg.Link.Text = text
+	msg.Link.Context.URL = links[0]
+	return c.sendItem(
+		urlInboxSendLink,
+		msg,
+		map[string]string{
+			"link_text": text,
+			"link_urls": string(b),
+		},
+	)
+}
+
+// ShareMedia shares a post or reel in the conversation. To reply to a story,
+// use Item.Reply.
+func (c *Conversation) ShareMedia(item *Item) (*InboxItem, error) {
+	return c.sendItem(
+		fmt.Sprintf("%s?media_type=%s", urlInboxShareMedia, item.MediaToString()),
+		&InboxItem{Type: "media_share", MediaShare: item},
+		map[string]string{
+			"media_id": item.GetID(),
+		},
+	)
+}
+
+// ShareProfile shares the profile of a user in the conversation.
+func (c *Conversation) ShareProfile(user *User) (*InboxItem, error) {
+	return c.sendItem(
+		urlInboxShareProfile,
+		&InboxItem{Type: "profile", Profile: user},
+		map[string]string{
+			"profile_user_id": toString(user.ID),
+		},
+	)
+}
+
+// ShareHashtag shares a hashtag in the conversation.
+func (c *Conversation) ShareHashtag(hashtag *Hashtag) (*InboxItem, error) {
+	return c.sendItem(
+		urlInboxShareHashtag,
+		&InboxItem{Type: "hashtag", Hashtag: hashtag},
+		map[string]string{
+			"hashtag": hashtag.Name,
+			"text":    "",
+		},
+	)
+}
+
+// ShareLocation shares a location in the conversation.
+func (c *Conversation) ShareLocation(location *Location) (*InboxItem, error) {
+	return c.sendItem(
+		urlInboxShareLocation,
+		&InboxItem{Type: "location", Location: location},
+		map[string]string{
+			"venue_id": toString(location.ID),
+			"text":     "",
+		},
+	)
+}
+
+// SendLike sends a heart to the conversation.
+func (c *Conversation) SendLike() (*InboxItem, error) {
+	return c.sendItem(
+		urlInboxSendLike,
+		&InboxItem{Type: "like", Like: "❤️"},
+		map[string]string{},
+	)
+}
+
+// newDirectUpload reads the file to send into new upload options.
+func (c *Conversation) newDirectUpload(r io.Reader) (*UploadOptions, error) {
+	buf, err := readFile(r)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadOptions{
+		insta:     c.insta,
+		isDirect:  true,
+		startTime: toString(time.Now().Unix()),
+		buf:       buf,
+	}, nil
+}
+
+// uploadVoice uploads o.buf as the audio of a voice message.
+func (o *UploadOptions) uploadVoice(duration time.Duration) error {
+	o.mediaType = 11
+	o.newUploadID()
+	o.name = fmt.Sprintf("%s_0_%d", o.uploadID, random(1000000000, 9999999999))
+	o.waterfallID = generateUUID()
+	o.duration = int(duration / time.Millisecond)
+
+	err := o.createRUploadParams(map[string]string{
+		"is_direct_voice":          "1",
+		"upload_media_duration_ms": toString(o.duration),
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := o.postVideoGET(); err != nil {
+		return err
+	}
+	return o.postVideo()
+}
+
+// Reply sends a text message quoting msg.
+func (c *Conversation) Reply(msg *InboxItem, text string) (*InboxItem, error) {
+	return c.sendItem(
+		urlInboxSend,
+		&InboxItem{Type: "text", Text: text, RepliedTo: msg},
+		map[string]string{
+			"text":                      text,
+			"replied_to_item_id":        msg.ID,
+			"replied_to_client_context": msg.ClientContext,
+		},
+	)
+}
+
+// Forward sends a message of this conversation to another conversation.
+func (c *Conversation) Forward(msg *InboxItem, to *Conversation) (*InboxItem, error) {
+	fwd := *msg
+	fwd.UserID = 0
+	fwd.Reactions = nil
+	fwd.RepliedTo = nil
+	return to.sendItem(
+		urlInboxForward,
+		&fwd,
+		map[string]string{
+			"forwarded_from_thread_id":      c.ID,
+			"forwarded_from_thread_item_id": msg.ID,
+		},
+	)
+}
+
+// React reacts to a message with an emoji, which replaces your previous
+// reaction to it. An empty emoji sends a heart.
+func (c *Conversation) React(msg *InboxItem, emoji string) error {
+	if emoji == "" {
+		emoji = "❤️"
+	}
+	return c.react(msg, emoji)
+}
+
+// Unreact removes your reaction to a message.
+func (c *Conversation) Unreact(msg *InboxItem) error {
+	return c.react(msg, "")
+}
+
+func (c *Conversation) react(msg *InboxItem, emoji string) error {
+	insta := c.insta
+	query, err := c.broadcastQuery()
+	if err != nil {
+		return err
+	}
+	status := "created"
+	if emoji == "" {
+		status = "deleted"
+	}
+	query = MergeMapS(query, map[string]string{
+		"item_type":       "reaction",
+		"reaction_type":   "like",
+		"reaction_status": status,
+		"node_type":       "item",
+		"item_id":         msg.ID,
+		"emoji":           emoji,
+	})
+
+	_, _, err = insta.sendRequest(
+		&reqOptions{
+			Endpoint: urlInboxReact,
+			IsPost:   true,
+			Query:    query,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	if insta.Account != nil {
+		msg.setReaction(insta.Account.ID, emoji, query["client_context"])
+	}
+	return nil
+}
+
+// setReaction replaces the reaction of a user to the message, an empty emoji
+// removes it.
+func (msg *InboxItem) setReaction(userID int64, emoji, clientContext string) {
+	if msg.Reactions == nil {
+		msg.Reactions = &ItemReactions{}
+	}
+	emojis := msg.Reactions.Emojis[:0]
+	for _, r := range msg.Reactions.Emojis {
+		if r.SenderID != userID {
+			emojis = append(emojis, r)
+		}
+	}
+	if emoji != "" {
+		emojis = append(emojis, ItemReaction{
+			SenderID:      userID,
+			Timestamp:     time.Now().UnixMicro(),
+			ClientContext: clientContext,
+			Emoji:         emoji,
+		})
+	}
+	msg.Reactions.Emojis = emojis
+}
+
+// Unsend deletes one of your own messages from the conversation, for everyone.
+func (c *Conversation) Unsend(msg *InboxItem) error {
+	insta := c.insta
+	if insta.Account == nil || msg.UserID != insta.Account.ID {
+		return ErrNotOwnItem
+	}
+
+	_, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: fmt.Sprintf(urlInboxUnsend, c.ID, msg.ID),
+			IsPost:   true,
+			Query: map[string]string{
+				"_uuid": insta.uuid,
+			},
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	for i, m := range c.Items {
+		if m.ID == msg.ID {
+			c.Items = append(c.Items[:i], c.Items[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
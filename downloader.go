@@ -0,0 +1,517 @@
+package goinsta
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNameTemplate is the file naming template used by the Downloader
+	// if none has been set. See Downloader.NameTemplate for all placeholders.
+	DefaultNameTemplate = "{username}/{taken_at}_{pk}"
+
+	// DefaultManifestName is the name of the manifest file inside the
+	// download directory.
+	DefaultManifestName = ".goinsta_manifest.jsonl"
+
+	defaultDownloadWorkers = 4
+)
+
+// Downloader downloads media items concurrently, using a bounded pool of
+// workers. Items are deduplicated by media ID, and every completed item is
+// recorded in an on-disk manifest, so that re-runs skip media which has
+// already been downloaded.
+//
+// Items can be added one by one with Downloader.Add, or a whole feed can be
+// queued with Downloader.AddFeed. Call Downloader.Wait once all items have
+// been added.
+//
+//	d, err := insta.NewDownloader("./archive", 8)
+//	if err != nil {
+//		panic(err)
+//	}
+//	err = d.AddFeed(user.Feed())
+//	err = d.Wait()
+type Downloader struct {
+	insta *Instagram
+
+	// Dir is the root directory all media is saved to.
+	Dir string
+
+	// Workers is the number of concurrent downloads.
+	Workers int
+
+	// NameTemplate is used to create stable file names, relative to Dir.
+	// Directory separators are allowed. The file extension is added
+	// automatically. Available placeholders are:
+	//
+	//  {username}  username of the media owner
+	//  {user_id}   user ID of the media owner
+	//  {pk}        media pk
+	//  {id}        full media ID
+	//  {code}      shortcode, as used in post urls
+	//  {taken_at}  unix timestamp of when the media was posted
+	//  {date}      date the media was posted, formatted as 2006-01-02
+	//  {type}      media type, e.g. photo, video, carousel
+	//  {index}     position inside a carousel, starting at 1. 0 for other media
+	//
+	// If the template does not contain {index}, carousel items will have
+	// _{index} appended to their name. Items the template gives the name of
+	// a file that belongs to other media fail with ErrDownloadNameCollision.
+	NameTemplate string
+
+	// Selector picks the quality to download, if nil the selector set with
+	// Instagram.SetMediaSelector is used.
+	Selector MediaSelector
+
+	// OnDone is called after every item has been processed. Skipped is true
+	// if the item was already present in the manifest. Calls are made one at
+	// a time, so it doesn't need to be safe for concurrent use, but it does
+	// hold up the other workers. Can be nil.
+	OnDone func(item *Item, files []string, skipped bool, err error)
+
+	manifestPath string
+	manifest     map[string]*ManifestEntry
+	seen         map[string]bool
+	// names maps the files of this run and the manifest to their media ID
+	names  map[string]string
+	errs   []error
+	closed bool
+
+	queue  chan *Item
+	mu     *sync.Mutex
+	doneMu *sync.Mutex
+	wg     *sync.WaitGroup
+	once   *sync.Once
+}
+
+// ManifestEntry is a single line in the download manifest.
+type ManifestEntry struct {
+	ID        string   `json:"id"`
+	Code      string   `json:"code,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TakenAt   int64    `json:"taken_at"`
+	Files     []string `json:"files"`
+	Completed int64    `json:"completed_at"`
+}
+
+// NewDownloader creates a new Downloader that saves all media to dir. If the
+// directory contains a manifest from a previous run, it will be loaded.
+//
+// If workers is less than one, a default of 4 workers will be used.
+func (insta *Instagram) NewDownloader(dir string, workers int) (*Downloader, error) {
+	if workers < 1 {
+		workers = defaultDownloadWorkers
+	}
+	if err := os.MkdirAll(dir, 0o777); err != nil {
+		return nil, err
+	}
+
+	d := &Downloader{
+		insta:        insta,
+		Dir:          dir,
+		Workers:      workers,
+		NameTemplate: DefaultNameTemplate,
+		manifestPath: filepath.Join(dir, DefaultManifestName),
+		manifest:     make(map[string]*ManifestEntry),
+		seen:         make(map[string]bool),
+		names:        make(map[string]string),
+		mu:           &sync.Mutex{},
+		doneMu:       &sync.Mutex{},
+		wg:           &sync.WaitGroup{},
+		once:         &sync.Once{},
+	}
+
+	if err := d.loadManifest(); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Add queues media items for download. Items that have already been queued,
+// or that are present in the manifest, will be skipped. ErrDownloaderClosed
+// is returned if Wait has already been called.
+//
+// Add blocks when all workers are busy.
+func (d *Downloader) Add(items ...*Item) error {
+	d.once.Do(d.start)
+
+	for _, item := range items {
+		if item == nil {
+			continue
+		}
+		id := item.GetID()
+
+		d.mu.Lock()
+		if d.closed {
+			d.mu.Unlock()
+			return ErrDownloaderClosed
+		}
+		seen := d.seen[id]
+		d.seen[id] = true
+		if !seen {
+			// Wait only closes the queue once this item has been processed
+			d.wg.Add(1)
+		}
+		d.mu.Unlock()
+
+		if !seen {
+			d.queue <- item
+		}
+	}
+	return nil
+}
+
+// AddFeed will paginate over a feed, and queue all items for download.
+//
+// Supported feeds are *FeedMedia (e.g. User.Feed, User.Tags, Account.Archived),
+// *Hashtag, *SavedMedia, *Collection, *IGTVChannel, *StoryMedia, *Reel,
+// and []*Item.
+func (d *Downloader) AddFeed(feed interface{}) error {
+	switch f := feed.(type) {
+	case *FeedMedia:
+		n := len(f.Items)
+		if err := d.Add(f.Items...); err != nil {
+			return err
+		}
+		for f.Next() {
+			if err := d.Add(f.Items[n:]...); err != nil {
+				return err
+			}
+			n = len(f.Items)
+		}
+		return ignoreNoMore(f.Error())
+	case *Hashtag:
+		n := len(f.Items)
+		if err := d.Add(f.Items...); err != nil {
+			return err
+		}
+		for f.Next() {
+			if err := d.Add(f.Items[n:]...); err != nil {
+				return err
+			}
+			n = len(f.Items)
+		}
+		// Hashtag.Next returns false on the last page, with items
+		if err := d.Add(f.Items[n:]...); err != nil {
+			return err
+		}
+		return ignoreNoMore(f.Error())
+	case *SavedMedia:
+		n := 0
+		for {
+			more := f.Next()
+			for i := n; i < len(f.Items); i++ {
+				if err := d.Add(&f.Items[i].Media); err != nil {
+					return err
+				}
+			}
+			n = len(f.Items)
+			if !more {
+				break
+			}
+		}
+		return ignoreNoMore(f.Error())
+	case *Collection:
+		for {
+			// Collection.Next can replace the item slice, already queued
+			//   items will be skipped
+			more := f.Next()
+			for i := range f.Items {
+				if err := d.Add(&f.Items[i]); err != nil {
+					return err
+				}
+			}
+			if !more {
+				break
+			}
+		}
+		return ignoreNoMore(f.Error())
+	case *IGTVChannel:
+		n := len(f.Items)
+		if err := d.Add(f.Items...); err != nil {
+			return err
+		}
+		for f.Next() {
+			if err := d.Add(f.Items[n:]...); err != nil {
+				return err
+			}
+			n = len(f.Items)
+		}
+		return ignoreNoMore(f.Error())
+	case *StoryMedia:
+		return d.Add(f.Reel.Items...)
+	case *Reel:
+		if len(f.Items) == 0 && (f.ReelType == "highlight_reel" || f.ReelType == "archive_day_reel") {
+			if err := f.Sync(); err != nil {
+				return err
+			}
+		}
+		return d.Add(f.Items...)
+	case []*Item:
+		return d.Add(f...)
+	}
+	return ErrUnsupportedFeed
+}
+
+// Wait blocks until all queued items have been downloaded, and returns the
+// first error that occured, if any. Per item errors can be inspected
+// through Downloader.OnDone. No items can be added after calling Wait,
+// calling it again returns the same result.
+func (d *Downloader) Wait() error {
+	d.once.Do(d.start)
+
+	d.mu.Lock()
+	closed := d.closed
+	d.closed = true
+	d.mu.Unlock()
+
+	d.wg.Wait()
+	if !closed {
+		close(d.queue)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if n := len(d.errs); n > 0 {
+		return fmt.Errorf("%d of the downloads failed, first error: %w", n, d.errs[0])
+	}
+	return nil
+}
+
+// Manifest returns the entry of a downloaded media item, if it exists.
+func (d *Downloader) Manifest(id string) (*ManifestEntry, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	e, ok := d.manifest[id]
+	return e, ok
+}
+
+func (d *Downloader) start() {
+	d.queue = make(chan *Item, d.Workers)
+	for i := 0; i < d.Workers; i++ {
+		go d.worker()
+	}
+}
+
+func (d *Downloader) worker() {
+	for item := range d.queue {
+		files, skipped, err := d.process(item)
+		if err != nil {
+			err = fmt.Errorf("failed to download %s: %w", item.GetID(), err)
+			d.fail(err)
+		}
+		if d.OnDone != nil {
+			d.doneMu.Lock()
+			d.OnDone(item, files, skipped, err)
+			d.doneMu.Unlock()
+		}
+		d.wg.Done()
+	}
+}
+
+// fail records an error, to be returned by Wait.
+func (d *Downloader) fail(err error) {
+	d.mu.Lock()
+	d.errs = append(d.errs, err)
+	d.mu.Unlock()
+}
+
+func (d *Downloader) process(item *Item) ([]string, bool, error) {
+	id := item.GetID()
+	if e, ok := d.Manifest(id); ok && d.filesExist(e.Files) {
+		return e.Files, true, nil
+	}
+
+	var files []string
+	switch item.MediaType {
+	case 1, 2:
+		f, err := d.downloadItem(item, item, 0)
+		if err != nil {
+			return nil, false, err
+		}
+		files = append(files, f)
+	case 8:
+		for i := range item.CarouselMedia {
+			f, err := d.downloadItem(item, &item.CarouselMedia[i], i+1)
+			if err != nil {
+				return files, false, err
+			}
+			files = append(files, f)
+		}
+	default:
+		return nil, false, ErrNoMedia
+	}
+
+	entry := &ManifestEntry{
+		ID:        id,
+		Code:      item.Code,
+		Username:  item.User.Username,
+		TakenAt:   item.TakenAt,
+		Files:     files,
+		Completed: time.Now().Unix(),
+	}
+	return files, false, d.record(entry)
+}
+
+// downloadItem saves a single photo or video. The parent is used to fill
+// in the name template, as carousel children lack most of the metadata.
+func (d *Downloader) downloadItem(parent, item *Item, index int) (string, error) {
+	url, err := item.mediaURL(d.insta, d.Selector)
+	if err != nil {
+		return "", err
+	}
+
+	name := d.fileName(parent, item, index)
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	name += path.Ext(u.Path)
+	if err := d.claim(name, parent.GetID()); err != nil {
+		return "", err
+	}
+
+	dst := filepath.Join(d.Dir, filepath.FromSlash(name))
+	if err := d.insta.downloadFile(url, dst); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// claim reserves a file name for a media item, so media the name template
+// maps to the same file don't overwrite each other.
+func (d *Downloader) claim(name, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if other, ok := d.names[name]; ok && other != id {
+		return fmt.Errorf("%w: %s is already used by %s", ErrDownloadNameCollision, name, other)
+	}
+	d.names[name] = id
+	return nil
+}
+
+func (d *Downloader) fileName(parent, item *Item, index int) string {
+	tmpl := d.NameTemplate
+	if tmpl == "" {
+		tmpl = DefaultNameTemplate
+	}
+	if index > 0 && !strings.Contains(tmpl, "{index}") {
+		tmpl += "_{index}"
+	}
+
+	r := strings.NewReplacer(
+		"{username}", sanitizeFileName(parent.User.Username),
+		"{user_id}", toString(parent.User.ID),
+		"{pk}", toString(parent.Pk),
+		"{id}", sanitizeFileName(parent.GetID()),
+		"{code}", sanitizeFileName(parent.Code),
+		"{taken_at}", toString(parent.TakenAt),
+		"{date}", time.Unix(parent.TakenAt, 0).UTC().Format("2006-01-02"),
+		"{type}", item.MediaToString(),
+		"{index}", toString(index),
+	)
+	return r.Replace(tmpl)
+}
+
+func (d *Downloader) filesExist(files []string) bool {
+	if len(files) == 0 {
+		return false
+	}
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(d.Dir, filepath.FromSlash(f))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *Downloader) loadManifest() error {
+	f, err := os.Open(d.manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		e := &ManifestEntry{}
+		if err := json.Unmarshal(line, e); err != nil {
+			// A partially written last line, after e.g. a crash
+			d.insta.warnHandler("Skipping invalid manifest line:", err)
+			continue
+		}
+		d.manifest[e.ID] = e
+		for _, name := range e.Files {
+			d.names[name] = e.ID
+		}
+	}
+	return scanner.Err()
+}
+
+// record appends an entry to the manifest file. The manifest is kept as
+// JSON lines, so it doesn't need to be rewritten for every item.
+func (d *Downloader) record(e *ManifestEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	f, err := os.OpenFile(d.manifestPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	d.manifest[e.ID] = e
+	return nil
+}
+
+// mediaURL returns the url of the image or video of an item, in the quality
+// picked by the media selector.
+func (item *Item) mediaURL(insta *Instagram, sel MediaSelector) (string, error) {
+	var url string
+	switch item.MediaType {
+	case 1:
+		url = insta.selectMedia(item.Images.Versions, []MediaSelector{sel})
+	case 2:
+		url = insta.selectMedia(item.Videos, []MediaSelector{sel})
+	}
+	if url == "" {
+		return "", ErrNoMedia
+	}
+	return url, nil
+}
+
+func sanitizeFileName(s string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_", "..", "_").Replace(s)
+}
+
+func ignoreNoMore(err error) error {
+	if err == ErrNoMore {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,352 @@
+package goinsta
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	neturl "net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Conversation export formats.
+const (
+	// ExportJSON writes every message as a line of JSON
+	ExportJSON ExportFormat = "jsonl"
+	// ExportHTML writes a single HTML page, without external stylesheets
+	ExportHTML ExportFormat = "html"
+	// ExportText writes a plain text transcript in the mbox format, with a
+	// "From <username> <date>" line before every message
+	ExportText ExportFormat = "txt"
+)
+
+// ExportFormat is the format Conversation.Export writes the transcript in.
+type ExportFormat string
+
+// ExportOptions can be used to configure Conversation.Export.
+type ExportOptions struct {
+	// Dir is the directory attached media are downloaded to. The transcript
+	// refers to them by file name, so it should be written to the same
+	// directory. Without Dir, media are not downloaded and referred to by URL.
+	Dir string
+	// After is the timestamp of the last exported message, as returned in
+	// ExportReport.Last, to only export the messages sent since.
+	After int64
+}
+
+// ExportReport summarizes a single Export run.
+type ExportReport struct {
+	// Messages is the number of exported messages.
+	Messages int
+	// Media is the number of downloaded media files.
+	Media int
+	// Failed is the number of media files that could not be downloaded.
+	Failed int
+	// Last is the timestamp of the newest exported message, pass it as
+	// ExportOptions.After to continue the export later on.
+	Last int64
+}
+
+// ExportedMessage is a message as written by Conversation.Export.
+type ExportedMessage struct {
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	UserID    int64  `json:"user_id"`
+	Username  string `json:"username"`
+	Type      string `json:"type"`
+	Text      string `json:"text,omitempty"`
+	ReplyTo   string `json:"reply_to,omitempty"`
+	// Files are the attached media, as file names relative to
+	// ExportOptions.Dir, or as URLs if no directory was set
+	Files []string `json:"files,omitempty"`
+}
+
+// Time returns the time the message was sent at.
+func (m *ExportedMessage) Time() time.Time {
+	return time.UnixMicro(m.Timestamp).UTC()
+}
+
+var exportHTML = template.Must(template.New("export").Funcs(template.FuncMap{
+	"kind": mediaKind,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; max-width: 720px; margin: 2em auto; color: #262626; }
+.message { margin: 0.75em 0; }
+.meta { font-size: 0.8em; color: #8e8e8e; }
+.text { white-space: pre-wrap; }
+img, video { max-width: 320px; display: block; margin-top: 0.25em; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Messages}}<div class="message" id="{{.ID}}">
+<div class="meta"><b>{{.Username}}</b> {{.Time.Format "2006-01-02 15:04:05"}}{{if .ReplyTo}} &middot; <a href="#{{.ReplyTo}}">reply</a>{{end}}</div>
+{{if .Text}}<div class="text">{{.Text}}</div>
+{{end}}{{range .Files}}{{$kind := kind .}}{{if eq $kind "image"}}<img src="{{.}}">
+{{else if eq $kind "video"}}<video src="{{.}}" controls></video>
+{{else if eq $kind "audio"}}<audio src="{{.}}" controls></audio>
+{{else}}<a href="{{.}}">{{.}}</a>
+{{end}}{{end}}</div>
+{{end}}</body>
+</html>
+`))
+
+// Export writes the history of the conversation to w, oldest message first.
+// All history is fetched by paging through the conversation, and the names
+// of senders are resolved from its users.
+//
+// Attached photos, videos, voice messages, GIFs and shared posts are
+// downloaded to ExportOptions.Dir, next to the transcript. Media that fail
+// to download are passed to the warn handler and counted in the report,
+// after which the export continues.
+//
+// For an incremental export, pass the timestamp in ExportReport.Last as
+// ExportOptions.After on the next run. Pages are only fetched till that
+// message is reached. JSON and text transcripts can be appended to the
+// previous one, HTML transcripts are a new page with only the new messages.
+func (c *Conversation) Export(w io.Writer, format ExportFormat, opts ...*ExportOptions) (*ExportReport, error) {
+	o := &ExportOptions{}
+	if len(opts) > 0 && opts[0] != nil {
+		o = opts[0]
+	}
+	switch format {
+	case ExportJSON, ExportHTML, ExportText:
+	default:
+		return nil, ErrExportFormat
+	}
+	if o.Dir != "" {
+		if err := os.MkdirAll(o.Dir, 0o777); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.history(o.After); err != nil {
+		return nil, err
+	}
+
+	report := &ExportReport{Last: o.After}
+	names := c.usernames()
+	var messages []*ExportedMessage
+	// Items are sorted newest first
+	for i := len(c.Items) - 1; i >= 0; i-- {
+		item := c.Items[i]
+		if item.Timestamp <= o.After {
+			continue
+		}
+
+		m := &ExportedMessage{
+			ID:        item.ID,
+			Timestamp: item.Timestamp,
+			UserID:    item.UserID,
+			Username:  names[item.UserID],
+			Type:      item.Type,
+			Text:      item.exportText(),
+		}
+		if m.Username == "" {
+			m.Username = toString(item.UserID)
+		}
+		if item.RepliedTo != nil {
+			m.ReplyTo = item.RepliedTo.ID
+		}
+		for _, a := range item.attachments(c.insta) {
+			if o.Dir == "" {
+				m.Files = append(m.Files, a.url)
+				continue
+			}
+			name, err := c.insta.exportMedia(a.url, a.name, o.Dir)
+			if err != nil {
+				report.Failed++
+				c.insta.warnHandler(fmt.Sprintf("Failed to download media of message %s: %v", item.ID, err))
+				continue
+			}
+			report.Media++
+			m.Files = append(m.Files, name)
+		}
+
+		messages = append(messages, m)
+		report.Last = item.Timestamp
+	}
+	report.Messages = len(messages)
+
+	if err := c.writeTranscript(w, format, messages); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// usernames maps the IDs of all participants, including yourself and users
+// that left, to their usernames.
+func (c *Conversation) usernames() map[int64]string {
+	names := make(map[int64]string)
+	if account := c.insta.Account; account != nil {
+		names[account.ID] = account.Username
+	}
+	for _, users := range [][]*User{c.LeftUsers, c.Users} {
+		for _, u := range users {
+			names[u.ID] = u.Username
+		}
+	}
+	return names
+}
+
+func (c *Conversation) writeTranscript(w io.Writer, format ExportFormat, messages []*ExportedMessage) error {
+	switch format {
+	case ExportJSON:
+		enc := json.NewEncoder(w)
+		for _, m := range messages {
+			if err := enc.Encode(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ExportHTML:
+		title := c.Title
+		if title == "" {
+			title = c.ID
+		}
+		return exportHTML.Execute(w, map[string]interface{}{
+			"Title":    title,
+			"Messages": messages,
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, m := range messages {
+		fmt.Fprintf(bw, "From %s %s\n", m.Username, m.Time().Format(time.ANSIC))
+		fmt.Fprintf(bw, "Date: %s\n", m.Time().Format(time.RFC1123Z))
+		fmt.Fprintf(bw, "Message-ID: <%s@%s>\n", m.ID, c.ID)
+		if m.ReplyTo != "" {
+			fmt.Fprintf(bw, "In-Reply-To: <%s@%s>\n", m.ReplyTo, c.ID)
+		}
+		fmt.Fprintf(bw, "\n")
+		if m.Text != "" {
+			for _, line := range strings.Split(m.Text, "\n") {
+				// Escape lines that would start a new message
+				if strings.HasPrefix(strings.TrimLeft(line, ">"), "From ") {
+					line = ">" + line
+				}
+				fmt.Fprintln(bw, line)
+			}
+		}
+		for _, f := range m.Files {
+			fmt.Fprintf(bw, "[%s]\n", f)
+		}
+		fmt.Fprintln(bw)
+	}
+	return bw.Flush()
+}
+
+// exportText returns the text of a message, or a description of what has
+// been shared.
+func (item *InboxItem) exportText() string {
+	switch {
+	case item.Text != "":
+		return item.Text
+	case item.Type == "link":
+		return item.Link.Text
+	case item.Type == "like":
+		return item.Like
+	case item.ActionLog != nil:
+		return item.ActionLog.Description
+	case item.MediaShare != nil:
+		return item.MediaShare.Caption.Text
+	case item.Profile != nil:
+		return "@" + item.Profile.Username
+	case item.Hashtag != nil:
+		return "#" + item.Hashtag.Name
+	case item.Location != nil:
+		return item.Location.Name
+	}
+	return ""
+}
+
+type attachment struct {
+	url  string
+	name string
+}
+
+// attachments returns the URLs of the media attached to a message, with the
+// file names to save them as.
+func (item *InboxItem) attachments(insta *Instagram) []attachment {
+	var media []*Item
+	if item.Media != nil {
+		media = append(media, item.Media)
+	}
+	if item.VisualMedia != nil && item.VisualMedia.Media != nil {
+		media = append(media, item.VisualMedia.Media)
+	}
+	if item.MediaShare != nil {
+		media = append(media, item.MediaShare)
+	}
+
+	var files []attachment
+	for _, m := range media {
+		if len(m.CarouselMedia) == 0 {
+			if url, err := m.mediaURL(insta, nil); err == nil {
+				files = append(files, attachment{url, item.ID})
+			}
+			continue
+		}
+		for i := range m.CarouselMedia {
+			if url, err := m.CarouselMedia[i].mediaURL(insta, nil); err == nil {
+				files = append(files, attachment{url, fmt.Sprintf("%s_%d", item.ID, i+1)})
+			}
+		}
+	}
+
+	if item.VoiceMedia != nil {
+		if url := item.VoiceMedia.Media.Audio.AudioSrc; url != "" {
+			files = append(files, attachment{url, item.ID})
+		}
+	}
+	if item.AnimatedMedia != nil {
+		gif := item.AnimatedMedia.Images.FixedHeight
+		if url := gif.Mp4; url != "" {
+			files = append(files, attachment{url, item.ID})
+		} else if url := gif.URL; url != "" {
+			files = append(files, attachment{url, item.ID})
+		}
+	}
+	return files
+}
+
+// exportMedia downloads a media file to dir, unless it has been downloaded
+// by a previous export, and returns its file name.
+func (insta *Instagram) exportMedia(url, name, dir string) (string, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return "", err
+	}
+	name = sanitizeFileName(name) + path.Ext(u.Path)
+	dst := filepath.Join(dir, name)
+	if _, err := os.Stat(dst); err == nil {
+		return name, nil
+	}
+	return name, insta.downloadFile(url, dst)
+}
+
+// mediaKind returns whether a file is an image, video or audio file, by its
+// extension.
+func mediaKind(file string) string {
+	if u, err := neturl.Parse(file); err == nil {
+		file = u.Path
+	}
+	switch strings.ToLower(path.Ext(file)) {
+	case ".jpg", ".jpeg", ".png", ".webp", ".gif", ".heic":
+		return "image"
+	case ".mp4", ".mov", ".webm":
+		return "video"
+	case ".m4a", ".aac", ".mp3", ".ogg":
+		return "audio"
+	}
+	return ""
+}
@@ -126,6 +126,9 @@ type Instagram struct {
 	// Request Wrapper
 	reqWrapper ReqWrapper
 
+	// Picks the quality of media downloads, defaults to SelectBest
+	mediaSelector MediaSelector
+
 	// Proxy string
 	proxy         string
 	proxyInsecure bool
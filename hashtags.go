@@ -3,6 +3,7 @@ package goinsta
 import (
 	"encoding/json"
 	"fmt"
+	neturl "net/url"
 )
 
 // Hashtag is used for getting the media that matches a hashtag on instagram.
@@ -104,6 +105,11 @@ func (insta *Instagram) NewHashtag(name string) *Hashtag {
 	}
 }
 
+// URL returns the link to the hashtag page.
+func (h *Hashtag) URL() string {
+	return fmt.Sprintf("%sexplore/tags/%s/", instaWebURL, neturl.PathEscape(h.Name))
+}
+
 // Sync wraps Hashtag.Info()
 func (h *Hashtag) Sync() error {
 	return h.Info()
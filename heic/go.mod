@@ -0,0 +1,10 @@
+module github.com/Davincible/goinsta/v3/heic
+
+go 1.22.0
+
+require github.com/gen2brain/heic v0.4.3
+
+require (
+	github.com/ebitengine/purego v0.8.2 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
+)
@@ -0,0 +1,28 @@
+// Package heic registers a HEIC decoder with the image package, so
+// goinsta.ConvertToJPEG and Upload can convert HEIC photos. Import it for its
+// side effect:
+//
+//	import _ "github.com/Davincible/goinsta/v3/heic"
+//
+// Images are decoded with github.com/gen2brain/heic, which uses the shared
+// libheif library if it is installed, and otherwise runs libheif compiled to
+// WebAssembly. It is a separate module, so programs that don't need HEIC
+// support don't depend on it.
+package heic
+
+import (
+	"image"
+
+	"github.com/gen2brain/heic"
+)
+
+// brands are the ftyp major brands of HEIC images, gen2brain/heic only
+// registers the heic brand itself. The generic mif1 and msf1 brands are left
+// out, libheif doesn't accept them as a major brand.
+var brands = []string{"heix", "hevc", "hevx", "heim", "heis", "hevm", "hevs"}
+
+func init() {
+	for _, brand := range brands {
+		image.RegisterFormat("heic", "????ftyp"+brand, heic.Decode, heic.DecodeConfig)
+	}
+}
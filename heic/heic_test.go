@@ -0,0 +1,23 @@
+package heic
+
+import (
+	"bytes"
+	"image"
+	"os"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	b, err := os.ReadFile("testdata/photo.heic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); format != "heic" || size.X == 0 || size.Y == 0 {
+		t.Errorf("Expected a decoded heic image, got %s of %v", format, size)
+	}
+}
@@ -0,0 +1,249 @@
+package goinsta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+
+	// Supported image formats, other than jpeg
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+)
+
+// Default quality used when converting images to JPEG
+const defaultJPEGQuality = 95
+
+// heifBrands are the ftyp brands of HEIC and HEIF images.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true, "heim": true,
+	"heis": true, "hevm": true, "hevs": true, "mif1": true, "msf1": true,
+}
+
+// detectContentType extends http.DetectContentType with HEIC images.
+func detectContentType(b []byte) string {
+	t := http.DetectContentType(b)
+	if t == "application/octet-stream" && len(b) >= 12 &&
+		string(b[4:8]) == "ftyp" && heifBrands[string(b[8:12])] {
+		return "image/heic"
+	}
+	return t
+}
+
+// isImage reports whether the content type is an image format that can be
+// uploaded, either directly or after conversion to JPEG.
+func isImage(contentType string) bool {
+	switch contentType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp", "image/heic":
+		return true
+	}
+	return false
+}
+
+// ConvertToJPEG converts a PNG, WebP, GIF or HEIC image to a JPEG that can be
+// uploaded. Transparent areas are filled with the background color, white
+// if nil, and the image is rotated according to its EXIF orientation. Color
+// profiles and other metadata are not carried over. Of animated GIFs only
+// the first frame is used. A quality of 0 uses the default of 95.
+//
+// JPEG images are only re-encoded if they need to be rotated, otherwise
+// their EXIF, XMP and ICC segments are stripped and the image data is left
+// as is.
+//
+// HEIC images can only be decoded if a HEIC decoder has been registered with
+// image.RegisterFormat, e.g. by importing github.com/Davincible/goinsta/v3/heic.
+func ConvertToJPEG(b []byte, quality int, background color.Color) ([]byte, error) {
+	t := detectContentType(b)
+	if !isImage(t) {
+		return nil, ErrInvalidImage
+	}
+	orientation := exifOrientation(b)
+	if t == "image/jpeg" && orientation <= 1 {
+		return stripJPEGMetadata(b), nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if errors.Is(err, image.ErrFormat) && t == "image/heic" {
+		return nil, ErrNoHEICDecoder
+	} else if err != nil {
+		return nil, err
+	}
+
+	if quality <= 0 || quality > 100 {
+		quality = defaultJPEGQuality
+	}
+	if background == nil {
+		background = color.White
+	}
+
+	img = orientImage(flattenImage(img, background), orientation)
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// convertImage converts o.buf to JPEG if needed.
+func (o *UploadOptions) convertImage() error {
+	b, err := ConvertToJPEG(o.buf.Bytes(), o.JPEGQuality, o.Background)
+	if err != nil {
+		return err
+	}
+	o.buf = bytes.NewBuffer(b)
+	return nil
+}
+
+// flattenImage draws the image onto a background of color c, which removes
+// any transparency.
+func flattenImage(img image.Image, c color.Color) *image.RGBA {
+	b := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Over)
+	return dst
+}
+
+// orientImage rotates and flips the image according to an EXIF orientation
+// value, so it is displayed upright without the orientation tag.
+func orientImage(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // mirror horizontal
+				dx, dy = w-1-x, y
+			case 3: // rotate 180
+				dx, dy = w-1-x, h-1-y
+			case 4: // mirror vertical
+				dx, dy = x, h-1-y
+			case 5: // transpose
+				dx, dy = y, x
+			case 6: // rotate 90 clockwise
+				dx, dy = h-1-y, x
+			case 7: // transverse
+				dx, dy = h-1-y, w-1-x
+			case 8: // rotate 90 counter clockwise
+				dx, dy = y, w-1-x
+			}
+			i, j := img.PixOffset(x, y), dst.PixOffset(dx, dy)
+			copy(dst.Pix[j:j+4], img.Pix[i:i+4])
+		}
+	}
+	return dst
+}
+
+// stripJPEGMetadata removes the APP1 and APP2 segments from a JPEG, which
+// hold its EXIF and XMP metadata and its color profile.
+func stripJPEGMetadata(b []byte) []byte {
+	out := make([]byte, 0, len(b))
+	out = append(out, b[:2]...)
+	i := 2
+	// Walk the segments up to the start of scan
+	for i+4 <= len(b) && b[i] == 0xFF {
+		marker := b[i+1]
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		if marker == 0xDA || i+2+length > len(b) {
+			break
+		}
+		if marker != 0xE1 && marker != 0xE2 {
+			out = append(out, b[i:i+2+length]...)
+		}
+		i += 2 + length
+	}
+	return append(out, b[i:]...)
+}
+
+// exifOrientation returns the EXIF orientation of a JPEG, PNG or WebP image,
+// or 0 if it has none.
+func exifOrientation(b []byte) int {
+	switch detectContentType(b) {
+	case "image/jpeg":
+		// Walk the segments up to the start of scan
+		for i := 2; i+4 <= len(b) && b[i] == 0xFF; {
+			marker := b[i+1]
+			length := int(binary.BigEndian.Uint16(b[i+2:]))
+			if marker == 0xDA || i+2+length > len(b) {
+				break
+			}
+			seg := b[i+4 : i+2+length]
+			if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+				return tiffOrientation(seg[6:])
+			}
+			i += 2 + length
+		}
+	case "image/png":
+		for i := 8; i+12 <= len(b); {
+			length := int(binary.BigEndian.Uint32(b[i:]))
+			if length < 0 || i+12+length > len(b) {
+				break
+			}
+			if string(b[i+4:i+8]) == "eXIf" {
+				return tiffOrientation(b[i+8 : i+8+length])
+			}
+			i += 12 + length
+		}
+	case "image/webp":
+		for i := 12; i+8 <= len(b); {
+			length := int(binary.LittleEndian.Uint32(b[i+4:]))
+			if length < 0 || i+8+length > len(b) {
+				break
+			}
+			if string(b[i:i+4]) == "EXIF" {
+				return tiffOrientation(bytes.TrimPrefix(b[i+8:i+8+length], []byte("Exif\x00\x00")))
+			}
+			i += 8 + length + length%2
+		}
+	}
+	return 0
+}
+
+// tiffOrientation reads the orientation tag from the first IFD of TIFF
+// formatted EXIF data.
+func tiffOrientation(t []byte) int {
+	if len(t) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(t[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < 8 || ifd+2 > len(t) {
+		return 0
+	}
+	n := int(order.Uint16(t[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(t) {
+			return 0
+		}
+		if order.Uint16(t[entry:]) == 0x0112 {
+			return int(order.Uint16(t[entry+8:]))
+		}
+	}
+	return 0
+}
@@ -115,6 +115,9 @@ type InboxItem struct {
 	VoiceMedia    *VoiceMedia    `json:"voice_media"`
 	VisualMedia   *VisualMedia   `json:"visual_media"`
 	ActionLog     *actionLog     `json:"action_log"`
+	Profile       *User          `json:"profile"`
+	Hashtag       *Hashtag       `json:"hashtag"`
+	Location      *Location      `json:"location"`
 	Link          struct {
 		Text    string `json:"text"`
 		Context struct {
@@ -124,6 +127,26 @@ type InboxItem struct {
 			ImageURL string `json:"link_image_url"`
 		} `json:"link_context"`
 	} `json:"link"`
+
+	// Reactions to the message, by any user
+	Reactions *ItemReactions `json:"reactions"`
+	// RepliedTo is the message this message is a reply to
+	RepliedTo *InboxItem `json:"replied_to_message"`
+}
+
+// ItemReactions are the hearts and emoji reactions to a message.
+type ItemReactions struct {
+	Likes      []ItemReaction `json:"likes"`
+	LikesCount int            `json:"likes_count"`
+	Emojis     []ItemReaction `json:"emojis"`
+}
+
+// ItemReaction is a reaction of a user to a message.
+type ItemReaction struct {
+	SenderID      int64  `json:"sender_id"`
+	Timestamp     int64  `json:"timestamp"`
+	ClientContext string `json:"client_context"`
+	Emoji         string `json:"emoji"`
 }
 
 type inboxResp struct {
@@ -355,7 +378,7 @@ func (inbox *Inbox) New(user *User, text string) (*Conversation, error) {
 		"_uuid":                insta.uuid,
 		"offline_threading_id": clientContext,
 	}
-	err = conv.send(query)
+	_, err = conv.send(urlInboxSend, &InboxItem{Type: "text", Text: text}, query)
 	if err != nil {
 		return nil, err
 	}
@@ -367,34 +390,37 @@ func (inbox *Inbox) New(user *User, text string) (*Conversation, error) {
 	return conv, nil
 }
 
-func (c *Conversation) send(query map[string]string) error {
-	body, _, err := c.insta.sendRequest(
+// send posts a message to one of the broadcast endpoints, and adds msg to
+//   the conversation with the ID and timestamp Instagram assigned to it.
+func (c *Conversation) send(endpoint string, msg *InboxItem, query map[string]string) (*InboxItem, error) {
+	insta := c.insta
+	body, _, err := insta.sendRequest(
 		&reqOptions{
-			Endpoint: urlInboxSend,
+			Endpoint: endpoint,
 			IsPost:   true,
 			Query:    query,
 		},
 	)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	var resp msgResp
 	err = json.Unmarshal(body, &resp)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	c.ID = resp.Payload.ThreadID
 
 	ts, _ := strconv.ParseInt(resp.Payload.Timestamp, 10, 64)
-	msg := &InboxItem{
-		ID:            resp.Payload.ItemID,
-		ClientContext: resp.Payload.ClientContext,
-		Timestamp:     ts,
-		Type:          "text",
+	msg.ID = resp.Payload.ItemID
+	msg.ClientContext = resp.Payload.ClientContext
+	msg.Timestamp = ts
+	if insta.Account != nil {
+		msg.UserID = insta.Account.ID
 	}
 	c.addMessage(msg)
-	return nil
+	return msg, nil
 }
 
 // Reset sets inbox cursor at the beginning.
@@ -590,66 +616,49 @@ func (c Conversation) lastItemID() string {
 	return c.Items[n-1].ID
 }
 
-// DEPRICATED - doesn't work anymore
-// Like sends heart to the conversation
-//
-// See example: examples/media/likeAll.go
-// func (c *Conversation) Like() error {
-// 	insta := c.insta
-// 	to, err := prepareRecipients(c)
-// 	if err != nil {
-// 		return err
-// 	}
-//
-// 	thread, err := json.Marshal([]string{c.ID})
-// 	if err != nil {
-// 		return err
-// 	}
-//
-// 	data := insta.prepareDataQuery(
-// 		map[string]interface{}{
-// 			"recipient_users": to,
-// 			"client_context":  generateUUID(),
-// 			"thread_ids":      string(thread),
-// 			"action":          "send_item",
-// 		},
-// 	)
-// 	_, _, err = insta.sendRequest(
-// 		&reqOptions{
-// 			Endpoint: urlInboxSendLike,
-// 			Query:    data,
-// 			IsPost:   true,
-// 		},
-// 	)
-// 	return err
-// }
-
 // Send sends message in conversation
 func (c *Conversation) Send(text string) error {
+	_, err := c.sendItem(
+		urlInboxSend,
+		&InboxItem{Type: "text", Text: text},
+		map[string]string{"text": text},
+	)
+	return err
+}
+
+// sendItem sends a message of any type to the conversation, extra holds the
+//   parameters specific to the type of message.
+func (c *Conversation) sendItem(endpoint string, msg *InboxItem, extra map[string]string) (*InboxItem, error) {
+	query, err := c.broadcastQuery()
+	if err != nil {
+		return nil, err
+	}
+	return c.send(endpoint, msg, MergeMapS(query, extra))
+}
+
+// broadcastQuery returns the parameters to address a broadcast to the
+//   conversation.
+func (c *Conversation) broadcastQuery() (map[string]string, error) {
 	insta := c.insta
 	// I DON'T KNOW WHY BUT INSTAGRAM WANTS A DOUBLE SLICE OF INTS FOR ONE ID. << lol
 	to, err := prepareRecipients(c)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// I DONT KNOW WHY BUT INSTAGRAM WANTS SLICE OF STRINGS FOR ONE ID. << lol
 	thread, err := json.Marshal([]string{c.ID})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	query := map[string]string{
+	return map[string]string{
 		"recipient_users": to,
 		"client_context":  generateUUID(),
 		"thread_ids":      string(thread),
 		"action":          "send_item",
-		"text":            text,
 		"_uuid":           insta.uuid,
 		"device_id":       insta.dID,
-	}
-
-	err = c.send(query)
-	return err
+	}, nil
 }
 
 // Write is like Send but being compatible with io.Writer.
@@ -819,9 +828,11 @@ func (inbox *Inbox) updateState(resp *inboxResp) {
 
 	if resp.isPending {
 		oldConv := inbox.Pending
+		conversations := inbox.Conversations
 		*inbox = resp.Inbox
 		inbox.insta = insta
 		inbox.Pending = oldConv
+		inbox.Conversations = conversations
 		for _, conv := range resp.Inbox.Conversations {
 			inbox.updatePending(conv)
 		}
@@ -942,4 +953,20 @@ func (msg *InboxItem) setValues(insta *Instagram) {
 		msg.Media.insta = insta
 		msg.Media.User.insta = insta
 	}
+	if msg.MediaShare != nil {
+		msg.MediaShare.insta = insta
+		msg.MediaShare.User.insta = insta
+	}
+	if msg.Profile != nil {
+		msg.Profile.insta = insta
+	}
+	if msg.Hashtag != nil {
+		msg.Hashtag.insta = insta
+	}
+	if msg.Location != nil {
+		msg.Location.insta = insta
+	}
+	if msg.RepliedTo != nil {
+		msg.RepliedTo.setValues(insta)
+	}
 }
@@ -0,0 +1,242 @@
+package goinsta
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// InsightsPeriod is the number of days account insights are calculated over,
+// sent as the "first" parameter of the insights endpoint.
+type InsightsPeriod int
+
+// Insights periods, as offered by the app.
+const (
+	InsightsWeek  InsightsPeriod = 7
+	InsightsMonth InsightsPeriod = 30
+)
+
+// MediaInsights are the insights of a single post or story. Insights are only
+// available for posts of business and creator accounts.
+type MediaInsights struct {
+	MediaID string `json:"-"`
+
+	Reach          int `json:"reach_count"`
+	Impressions    int `json:"impression_count"`
+	Engagement     int `json:"engagement_count"`
+	Likes          int `json:"like_count"`
+	Comments       int `json:"comment_count"`
+	Saves          int `json:"save_count"`
+	Shares         int `json:"share_count"`
+	ProfileVisits  int `json:"owner_profile_views_count"`
+	Follows        int `json:"follow_count"`
+	WebsiteClicks  int `json:"website_click_count"`
+	VideoViews     int `json:"video_view_count"`
+	FromHashtags   int `json:"impressions_from_hashtags"`
+	FromHome       int `json:"impressions_from_home"`
+	FromProfile    int `json:"impressions_from_profile"`
+	FromExplore    int `json:"impressions_from_explore"`
+	FromOther      int `json:"impressions_from_other"`
+	FollowerReach  int `json:"reach_followers_count"`
+	NonFollowReach int `json:"reach_non_followers_count"`
+
+	// Only set for story items
+	Exits       int `json:"exits_count"`
+	Replies     int `json:"replies_count"`
+	TapsForward int `json:"taps_forward_count"`
+	TapsBack    int `json:"taps_back_count"`
+	SwipeAways  int `json:"swipe_aways_count"`
+	LinkClicks  int `json:"link_clicks_count"`
+	StickerTaps int `json:"sticker_taps_count"`
+	Navigation  int `json:"navigation_count"`
+}
+
+// AccountInsights are the account level insights of a business or creator
+// account, over the requested period.
+type AccountInsights struct {
+	Period InsightsPeriod
+
+	Impressions      int
+	Reach            int
+	ProfileVisits    int
+	WebsiteClicks    int
+	EmailClicks      int
+	CallClicks       int
+	DirectionClicks  int
+	Followers        int
+	FollowersDelta   int
+	Posts            int
+	Demographics     FollowerDemographics
+	ImpressionsGraph []InsightsDataPoint
+	ReachGraph       []InsightsDataPoint
+}
+
+// FollowerDemographics describe the followers of an account, as percentages
+// or counts per label, depending on what Instagram provides.
+type FollowerDemographics struct {
+	Gender       []InsightsDataPoint
+	AgeRanges    []InsightsDataPoint
+	TopCities    []InsightsDataPoint
+	TopCountries []InsightsDataPoint
+}
+
+// InsightsDataPoint is a single value of an insights graph.
+type InsightsDataPoint struct {
+	Label string  `json:"label"`
+	Value float64 `json:"value"`
+}
+
+type insightsGraph struct {
+	DataPoints []InsightsDataPoint `json:"data_points"`
+}
+
+type accountInsightsResp struct {
+	User struct {
+		BusinessManager struct {
+			AccountInsightsUnit struct {
+				Impressions     int           `json:"impressions_metric_count"`
+				ImpressionGraph insightsGraph `json:"impressions_metric_graph"`
+				Reach           int           `json:"reach_metric_count"`
+				ReachGraph      insightsGraph `json:"reach_metric_graph"`
+				ProfileVisits   int           `json:"profile_visits_metric_count"`
+				WebsiteClicks   int           `json:"website_visits_metric_count"`
+				EmailClicks     int           `json:"email_contacts_metric_count"`
+				CallClicks      int           `json:"phone_call_metric_count"`
+				DirectionClicks int           `json:"get_directions_metric_count"`
+			} `json:"account_insights_unit"`
+			AccountSummaryUnit struct {
+				Posts          int `json:"posts_count"`
+				Followers      int `json:"followers_count"`
+				FollowersDelta int `json:"followers_delta_from_last_week"`
+			} `json:"account_summary_unit"`
+			FollowersUnit struct {
+				Gender    insightsGraph `json:"gender_graph"`
+				Age       insightsGraph `json:"all_followers_age_graph"`
+				Cities    insightsGraph `json:"followers_top_cities_graph"`
+				Countries insightsGraph `json:"followers_top_countries_graph"`
+			} `json:"followers_unit"`
+		} `json:"business_manager"`
+	} `json:"instagram_user"`
+	Status string `json:"status"`
+}
+
+// Insights fetches the account level insights over the given period. Only
+// available for business and creator accounts.
+func (account *Account) Insights(period InsightsPeriod) (*AccountInsights, error) {
+	insta := account.insta
+	if !account.isProfessional() {
+		return nil, ErrNotProfessionalAccount
+	}
+	if period <= 0 {
+		period = InsightsWeek
+	}
+
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: urlAccountInsights,
+			Query: map[string]string{
+				"show_promotions_in_landing_page": "true",
+				// The number of days, see InsightsPeriod
+				"first": strconv.Itoa(int(period)),
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := accountInsightsResp{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("failed to fetch insights, status: %s", resp.Status)
+	}
+
+	bm := resp.User.BusinessManager
+	unit := bm.AccountInsightsUnit
+	return &AccountInsights{
+		Period:           period,
+		Impressions:      unit.Impressions,
+		Reach:            unit.Reach,
+		ProfileVisits:    unit.ProfileVisits,
+		WebsiteClicks:    unit.WebsiteClicks,
+		EmailClicks:      unit.EmailClicks,
+		CallClicks:       unit.CallClicks,
+		DirectionClicks:  unit.DirectionClicks,
+		Followers:        bm.AccountSummaryUnit.Followers,
+		FollowersDelta:   bm.AccountSummaryUnit.FollowersDelta,
+		Posts:            bm.AccountSummaryUnit.Posts,
+		ImpressionsGraph: unit.ImpressionGraph.DataPoints,
+		ReachGraph:       unit.ReachGraph.DataPoints,
+		Demographics: FollowerDemographics{
+			Gender:       bm.FollowersUnit.Gender.DataPoints,
+			AgeRanges:    bm.FollowersUnit.Age.DataPoints,
+			TopCities:    bm.FollowersUnit.Cities.DataPoints,
+			TopCountries: bm.FollowersUnit.Countries.DataPoints,
+		},
+	}, nil
+}
+
+// Insights fetches the insights of a post or story. Only available for media
+// of business and creator accounts. For stories, the story specific fields
+// such as exits and taps are set.
+func (item *Item) Insights() (*MediaInsights, error) {
+	insta := item.insta
+	if !insta.Account.isProfessional() {
+		return nil, ErrNotProfessionalAccount
+	}
+
+	id := toString(item.Pk)
+	if item.Pk == 0 {
+		id = item.GetID()
+	}
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: fmt.Sprintf(urlMediaInsights, id),
+			Query: map[string]string{
+				"ig_sig_key_version": instaSigKeyVersion,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := struct {
+		Insights MediaInsights `json:"media_organic_insights"`
+		Status   string        `json:"status"`
+	}{}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "ok" {
+		return nil, fmt.Errorf("failed to fetch insights, status: %s", resp.Status)
+	}
+	resp.Insights.MediaID = item.GetID()
+	return &resp.Insights, nil
+}
+
+// Insights fetches the insights of every story in the reel, in the same order
+// as Reel.Items. Highlights need to be synced first.
+func (media *Reel) Insights() ([]*MediaInsights, error) {
+	insights := make([]*MediaInsights, 0, len(media.Items))
+	for _, item := range media.Items {
+		i, err := item.Insights()
+		if err != nil {
+			return insights, err
+		}
+		insights = append(insights, i)
+	}
+	return insights, nil
+}
+
+// isProfessional reports whether the account is a business or creator
+// account, which have access to insights.
+func (account *Account) isProfessional() bool {
+	if account == nil {
+		return false
+	}
+	return account.IsBusiness || account.CanSeeOrganicInsights ||
+		account.AccountType == 2 || account.AccountType == 3
+}
@@ -97,13 +97,14 @@ type Item struct {
 	// Use PreviewComments function instead of getting it directly.
 	Previewcomments interface{} `json:"preview_comments,omitempty"`
 
-	// Tags are tagged people in photo
-	Tags struct {
-		In []Tag `json:"in"`
-	} `json:"usertags,omitempty"`
-	FbUserTags           Tag    `json:"fb_user_tags"`
-	CanViewerSave        bool   `json:"can_viewer_save"`
-	OrganicTrackingToken string `json:"organic_tracking_token"`
+	// Tags are tagged people in photo. This used to be a list of Tag, read
+	// as Tags.In[i].In, which didn't match the response and was always
+	// empty. Read the tagged users as Tags.In[i].User instead.
+	Tags                  Tag     `json:"usertags,omitempty"`
+	TimelinePinnedUserIDs []int64 `json:"timeline_pinned_user_ids,omitempty"`
+	FbUserTags            Tag     `json:"fb_user_tags"`
+	CanViewerSave         bool    `json:"can_viewer_save"`
+	OrganicTrackingToken  string  `json:"organic_tracking_token"`
 	// Images contains URL images in different versions.
 	// Version = quality.
 	Images          Images   `json:"image_versions2,omitempty"`
@@ -470,6 +471,10 @@ type bestMedia struct {
 // GetBest returns url to best quality image or video.
 //
 // Arguments can be []Video or []Candidate
+//
+// A version is only preferred over another if both its width and height are
+// larger. SelectBest, used by the download methods, compares the area instead.
+// To use a different quality, see SelectMedia.
 func GetBest(obj interface{}) string {
 	m := bestMedia{}
 
@@ -532,6 +537,207 @@ func (item *Item) Delete() error {
 	return item.insta.delete(toString(item.ID), item.MediaToString(), item)
 }
 
+// Archive hides a post from your profile, it can be found in
+//   Account.Archived.
+func (item *Item) Archive() error {
+	return item.changeArchive(urlMediaArchive)
+}
+
+// Unarchive restores an archived post to your profile.
+func (item *Item) Unarchive() error {
+	return item.changeArchive(urlMediaUnarchive)
+}
+
+func (item *Item) changeArchive(endpoint string) error {
+	insta := item.insta
+	id := item.GetID()
+	data, err := json.Marshal(
+		map[string]string{
+			"media_id": id,
+			"_uid":     toString(insta.Account.ID),
+			"_uuid":    insta.uuid,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = insta.sendRequest(
+		&reqOptions{
+			Endpoint: fmt.Sprintf(endpoint, id) + "?media_type=" + strings.ToUpper(item.MediaToString()),
+			Query:    generateSignature(data),
+			IsPost:   true,
+		},
+	)
+	return err
+}
+
+// EditOptions are the changes to make with Item.Edit. Fields that are left
+// nil will not be changed.
+type EditOptions struct {
+	// Caption replaces the caption of the post
+	Caption *string
+	// Location replaces the location tag of the post
+	Location *LocationTag
+	// RemoveLocation removes the location tag of the post
+	RemoveLocation bool
+	// UserTags replaces the tagged users of a single photo or video. Users
+	//   that are no longer in the list will be untagged.
+	UserTags *[]UserTag
+	// AlbumTags replaces the tagged users of the items of a carousel post,
+	//   by index. A nil entry leaves the tags of that item unchanged, while
+	//   an empty list untags everyone. This is why the entries are pointers,
+	//   unlike UploadOptions.AlbumTags, where there are no tags to keep yet.
+	AlbumTags []*[]UserTag
+	// AltText replaces the alt text of a single photo
+	AltText *string
+	// AlbumAltText replaces the alt text of the items of a carousel post, by
+	//   index. A nil entry leaves the alt text of that item unchanged.
+	AlbumAltText []*string
+}
+
+// editTags is the format of user tags when editing media.
+type editTags struct {
+	In      []postTagUser `json:"in"`
+	Removed []string      `json:"removed"`
+}
+
+// Edit changes the caption, location, user tags or alt text of a published
+//   post. The item is updated in place, and returned for convenience.
+func (item *Item) Edit(o EditOptions) (*Item, error) {
+	insta := item.insta
+	if len(o.AlbumTags) > len(item.CarouselMedia) || len(o.AlbumAltText) > len(item.CarouselMedia) {
+		return nil, ErrEditAlbumIndex
+	}
+
+	caption := item.Caption.Text
+	if o.Caption != nil {
+		caption = *o.Caption
+	}
+
+	query := map[string]interface{}{
+		"_uid":                    toString(insta.Account.ID),
+		"_uuid":                   insta.uuid,
+		"device_id":               insta.dID,
+		"caption_text":            caption,
+		"container_module":        "edit_media_info",
+		"feed_position":           "0",
+		"is_carousel_bumped_post": "false",
+	}
+
+	switch {
+	case o.RemoveLocation:
+		query["location"] = "{}"
+	case o.Location != nil:
+		b, err := json.Marshal(o.Location)
+		if err != nil {
+			return nil, err
+		}
+		query["location"] = string(b)
+	}
+
+	if o.UserTags != nil {
+		tags, err := editUserTags(item, *o.UserTags)
+		if err != nil {
+			return nil, err
+		}
+		query["usertags"] = tags
+	}
+	if o.AltText != nil {
+		query["custom_accessibility_caption"] = *o.AltText
+	}
+
+	if len(o.AlbumTags) > 0 || len(o.AlbumAltText) > 0 {
+		var children []map[string]string
+		for i := range item.CarouselMedia {
+			child := &item.CarouselMedia[i]
+			meta := map[string]string{"media_id": child.GetID()}
+			if i < len(o.AlbumTags) && o.AlbumTags[i] != nil {
+				tags, err := editUserTags(child, *o.AlbumTags[i])
+				if err != nil {
+					return nil, err
+				}
+				meta["usertags"] = tags
+			}
+			if i < len(o.AlbumAltText) && o.AlbumAltText[i] != nil {
+				meta["custom_accessibility_caption"] = *o.AlbumAltText[i]
+			}
+			children = append(children, meta)
+		}
+		b, err := json.Marshal(children)
+		if err != nil {
+			return nil, err
+		}
+		query["children_metadata"] = string(b)
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: fmt.Sprintf(urlMediaEdit, item.GetID()),
+			IsPost:   true,
+			Query:    generateSignature(data),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Media   Item   `json:"media"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	if res.Status != "ok" {
+		return nil, fmt.Errorf("failed to edit media, status: %s, %s", res.Status, res.Message)
+	}
+
+	media := item.media
+	*item = res.Media
+	if media != nil {
+		setToItem(item, media)
+	} else {
+		item.insta = insta
+		item.User.insta = insta
+		item.Comments = newComments(item)
+	}
+	return item, nil
+}
+
+// editUserTags formats the new user tags of an item, and lists the users
+//   that are no longer tagged.
+func editUserTags(item *Item, tags []UserTag) (string, error) {
+	f := editTags{
+		In:      formatUserTags(tags, item.MediaType == 2).In,
+		Removed: []string{},
+	}
+	if f.In == nil {
+		f.In = []postTagUser{}
+	}
+
+	keep := map[int64]bool{}
+	for _, tag := range tags {
+		if tag.User != nil {
+			keep[tag.User.ID] = true
+		}
+	}
+	for _, tag := range item.Tags.In {
+		if !keep[tag.User.ID] {
+			f.Removed = append(f.Removed, toString(tag.User.ID))
+		}
+	}
+
+	b, err := json.Marshal(f)
+	return string(b), err
+}
+
 func (insta *Instagram) delete(id, media string, mediaType interface{}) error {
 	query := map[string]string{
 		"media_id": id,
@@ -650,7 +856,7 @@ func (item *Item) changeLike(endpoint string) error {
 	return err
 }
 
-// Download downloads media item (video or image) with the best quality.
+// DownloadTo downloads media item (video or image) with the best quality.
 //
 // Input parameter is a path to either a directory or a file. If no file is
 //   specified it will try to extract a file name from the image and use that.
@@ -661,11 +867,10 @@ func (item *Item) changeLike(endpoint string) error {
 // This function makes folder automatically
 //
 // See example: examples/media/itemDownload.go
-
-// func (item *Item) Download(folder, name string) (m []byte, err error) {
-// 	return nil, nil
-// }
-func (item *Item) DownloadTo(dst string) error {
+//
+// The quality can be picked by passing a MediaSelector, by default the one set
+// with Instagram.SetMediaSelector is used.
+func (item *Item) DownloadTo(dst string, sel ...MediaSelector) error {
 	insta := item.insta
 	folder, file := filepath.Split(dst)
 
@@ -675,11 +880,11 @@ func (item *Item) DownloadTo(dst string) error {
 
 	switch item.MediaType {
 	case 1:
-		return insta.downloadTo(folder, file, item.Images.Versions)
+		return insta.downloadTo(folder, file, item.Images.Versions, sel)
 	case 2:
-		return insta.downloadTo(folder, file, item.Videos)
+		return insta.downloadTo(folder, file, item.Videos, sel)
 	case 8:
-		return item.downloadCarousel(folder, file)
+		return item.downloadCarousel(folder, file, sel)
 	}
 
 	insta.warnHandler(
@@ -695,15 +900,18 @@ func (item *Item) DownloadTo(dst string) error {
 
 // Download will download a media item and directly return it as a byte slice.
 // If you wish to download a picture to a folder, use item.DownloadTo(path)
-func (item *Item) Download() ([]byte, error) {
+//
+// The quality can be picked by passing a MediaSelector, by default the one set
+// with Instagram.SetMediaSelector is used.
+func (item *Item) Download(sel ...MediaSelector) ([]byte, error) {
 	insta := item.insta
 
 	switch item.MediaType {
 	case 1:
-		url := GetBest(item.Images.Versions)
+		url := insta.selectMedia(item.Images.Versions, sel)
 		return insta.download(url)
 	case 2:
-		url := GetBest(item.Videos)
+		url := insta.selectMedia(item.Videos, sel)
 		return insta.download(url)
 	case 8:
 		return nil, fmt.Errorf("Unable to download a carousel with this method, use DownloadTo instead to save it to a file. If this is a feature you wish to use please let me know.")
@@ -711,13 +919,16 @@ func (item *Item) Download() ([]byte, error) {
 	return nil, ErrNoMedia
 }
 
-func (item *Item) downloadCarousel(folder, fn string) error {
+func (item *Item) downloadCarousel(folder, fn string, sel []MediaSelector) error {
 	if fn == "" {
 		fn = item.GetID()
 	}
 	for i, media := range item.CarouselMedia {
 		n := fmt.Sprintf("%s_%d", fn, i+1)
-		if err := media.DownloadTo(path.Join(folder, n)); err != nil {
+		if media.insta == nil {
+			media.insta = item.insta
+		}
+		if err := media.DownloadTo(path.Join(folder, n), sel...); err != nil {
 			return err
 		}
 	}
@@ -725,8 +936,8 @@ func (item *Item) downloadCarousel(folder, fn string) error {
 }
 
 // downloadTo saves a media item to folder/file
-func (insta *Instagram) downloadTo(folder, fn string, media interface{}) error {
-	url := GetBest(media)
+func (insta *Instagram) downloadTo(folder, fn string, media interface{}, sel []MediaSelector) error {
+	url := insta.selectMedia(media, sel)
 	fn, err := getDownloadName(url, fn)
 	if err != nil {
 		return err
@@ -749,6 +960,41 @@ func (insta *Instagram) download(url string) ([]byte, error) {
 	return media, err
 }
 
+// downloadFile streams the media from a url directly to dst. The file is first
+// written to a temporary file, which is renamed once the download completed,
+// so an interrupted download never leaves a partial file behind at dst.
+func (insta *Instagram) downloadFile(url, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o777); err != nil {
+		return err
+	}
+
+	resp, err := insta.c.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return errors.Wrapf(ErrDownloadStatus, "status code %d", resp.StatusCode)
+	}
+
+	tmp := dst + ".part"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
 // saveToFolder writes bytes to a file
 func saveToFolder(folder, fn string, media []byte) error {
 	dst := path.Join(folder, fn)
@@ -845,10 +1091,41 @@ func (item *Item) StoryIsCloseFriends() bool {
 	return item.Audience == "besties"
 }
 
+// IsPinned returns true if the item is pinned to the top of a user's profile.
+func (item *Item) IsPinned() bool {
+	return len(item.TimelinePinnedUserIDs) > 0
+}
+
 func (item *Item) GetID() string {
 	return toString(item.ID)
 }
 
+// URL returns the link to the item on instagram.com. Posts, reels and IGTV
+// videos link to their own page, stories to the story of the user.
+func (item *Item) URL() string {
+	if item.ProductType == "story" {
+		return fmt.Sprintf("%sstories/%s/%d/", instaWebURL, item.User.Username, item.Pk)
+	}
+
+	code := item.Code
+	if code == "" {
+		id := item.GetID()
+		if item.Pk != 0 {
+			id = toString(item.Pk)
+		}
+		code, _ = ShortIDFromMediaID(id)
+	}
+
+	kind := "p"
+	switch item.ProductType {
+	case "clips":
+		kind = "reel"
+	case "igtv":
+		kind = "tv"
+	}
+	return fmt.Sprintf("%s%s/%s/", instaWebURL, kind, code)
+}
+
 // FeedMedia represent a set of media items
 // Mainly used for user profile feeds. To get your main timeline use insta.Timeline
 type FeedMedia struct {
@@ -1024,6 +1301,7 @@ func (media *FeedMedia) Next(params ...interface{}) bool {
 			return true
 		}
 	}
+	media.err = err
 	return false
 }
 
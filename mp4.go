@@ -0,0 +1,456 @@
+package goinsta
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// mp4Box is a single ISO BMFF box. Data is the payload, without the header,
+// and is a sub slice of the original buffer, so modifications are in place.
+type mp4Box struct {
+	Type string
+	Data []byte
+	// Offset of the box header in the parsed buffer
+	Offset int
+}
+
+// mp4ContainerBoxes are the boxes that only contain other boxes.
+var mp4ContainerBoxes = map[string]bool{
+	"moov": true, "trak": true, "mdia": true, "minf": true, "stbl": true,
+	"mvex": true, "moof": true, "traf": true, "edts": true, "dinf": true,
+}
+
+// readBoxes parses all boxes in b, without descending into them.
+func readBoxes(b []byte) ([]mp4Box, error) {
+	var boxes []mp4Box
+	for off := 0; off < len(b); {
+		if len(b)-off < 8 {
+			return nil, errors.Wrap(ErrInvalidMP4, "truncated box header")
+		}
+		size := uint64(binary.BigEndian.Uint32(b[off:]))
+		typ := string(b[off+4 : off+8])
+		hdr := 8
+		switch size {
+		case 0:
+			size = uint64(len(b) - off)
+		case 1:
+			if len(b)-off < 16 {
+				return nil, errors.Wrap(ErrInvalidMP4, "truncated box header")
+			}
+			size = binary.BigEndian.Uint64(b[off+8:])
+			hdr = 16
+		}
+		if size < uint64(hdr) || size > uint64(len(b)-off) {
+			return nil, errors.Wrapf(ErrInvalidMP4, "invalid size of %s box", typ)
+		}
+		boxes = append(boxes, mp4Box{
+			Type:   typ,
+			Data:   b[off+hdr : off+int(size)],
+			Offset: off,
+		})
+		off += int(size)
+	}
+	return boxes, nil
+}
+
+// child returns the first direct child box of the given type.
+func (box mp4Box) child(typ string) (mp4Box, bool) {
+	children, err := readBoxes(box.Data)
+	if err != nil {
+		return mp4Box{}, false
+	}
+	for _, c := range children {
+		if c.Type == typ {
+			return c, true
+		}
+	}
+	return mp4Box{}, false
+}
+
+// find returns the first box of the given type along the path, e.g.
+// find("trak", "mdia", "mdhd").
+func (box mp4Box) find(path ...string) (mp4Box, bool) {
+	cur := box
+	for _, typ := range path {
+		c, ok := cur.child(typ)
+		if !ok {
+			return mp4Box{}, false
+		}
+		cur = c
+	}
+	return cur, true
+}
+
+// walkBoxes calls fn for every box in b, descending into container boxes.
+func walkBoxes(b []byte, fn func(box mp4Box) error) error {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return err
+	}
+	for _, box := range boxes {
+		if err := fn(box); err != nil {
+			return err
+		}
+		if mp4ContainerBoxes[box.Type] {
+			if err := walkBoxes(box.Data, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// version returns the version of a full box.
+func (box mp4Box) version() byte {
+	if len(box.Data) == 0 {
+		return 0
+	}
+	return box.Data[0]
+}
+
+// flags returns the flags of a full box.
+func (box mp4Box) flags() uint32 {
+	if len(box.Data) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(box.Data[:4]) & 0xffffff
+}
+
+func (box mp4Box) uint32At(off int) (uint32, error) {
+	if len(box.Data) < off+4 {
+		return 0, errors.Wrapf(ErrInvalidMP4, "%s box too short", box.Type)
+	}
+	return binary.BigEndian.Uint32(box.Data[off:]), nil
+}
+
+func (box mp4Box) setUint32At(off int, v uint32) error {
+	if len(box.Data) < off+4 {
+		return errors.Wrapf(ErrInvalidMP4, "%s box too short", box.Type)
+	}
+	binary.BigEndian.PutUint32(box.Data[off:], v)
+	return nil
+}
+
+// writeBox writes a box with the given payload to w.
+func writeBox(w io.Writer, typ string, payload ...[]byte) (int64, error) {
+	size := uint64(8)
+	for _, p := range payload {
+		size += uint64(len(p))
+	}
+
+	var hdr []byte
+	if size > math.MaxUint32 {
+		size += 8
+		hdr = make([]byte, 16)
+		binary.BigEndian.PutUint32(hdr, 1)
+		binary.BigEndian.PutUint64(hdr[8:], size)
+	} else {
+		hdr = make([]byte, 8)
+		binary.BigEndian.PutUint32(hdr, uint32(size))
+	}
+	copy(hdr[4:8], typ)
+
+	n, err := w.Write(hdr)
+	total := int64(n)
+	if err != nil {
+		return total, err
+	}
+	for _, p := range payload {
+		n, err := w.Write(p)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// fragmentedTrack is a parsed single track fragmented MP4, as served in
+// DASH manifests.
+type fragmentedTrack struct {
+	ftyp      []byte
+	moov      mp4Box
+	trak      mp4Box
+	trex      mp4Box
+	timescale uint32
+	fragments []*mp4Fragment
+}
+
+// mp4Fragment is a moof box, together with the media data that follows it.
+type mp4Fragment struct {
+	moof mp4Box
+	// media holds the raw boxes (usually a single mdat) after the moof
+	media [][]byte
+	// offset of the moof box in the source file
+	offset int
+	// decode time of the fragment in seconds
+	time float64
+}
+
+func parseFragmentedTrack(b []byte) (*fragmentedTrack, error) {
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &fragmentedTrack{}
+	var cur *mp4Fragment
+	for _, box := range boxes {
+		switch box.Type {
+		case "ftyp":
+			t.ftyp = box.Data
+		case "moov":
+			t.moov = box
+		case "moof":
+			cur = &mp4Fragment{moof: box, offset: box.Offset}
+			t.fragments = append(t.fragments, cur)
+		case "mdat":
+			if cur == nil {
+				return nil, errors.Wrap(ErrInvalidMP4, "media data found before first fragment")
+			}
+			cur.media = append(cur.media, b[box.Offset:box.Offset+8+len(box.Data)])
+		}
+		// Other boxes, like sidx, styp and mfra, only refer to the source
+		// file, and are dropped.
+	}
+	if t.moov.Type == "" {
+		return nil, errors.Wrap(ErrInvalidMP4, "no moov box found")
+	}
+
+	children, err := readBoxes(t.moov.Data)
+	if err != nil {
+		return nil, err
+	}
+	traks := 0
+	for _, c := range children {
+		if c.Type == "trak" {
+			t.trak = c
+			traks++
+		}
+	}
+	if traks != 1 {
+		return nil, errors.Wrapf(ErrInvalidMP4, "expected a single track, found %d", traks)
+	}
+	trex, ok := t.moov.find("mvex", "trex")
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidMP4, "file is not fragmented")
+	}
+	t.trex = trex
+
+	mdhd, ok := t.trak.find("mdia", "mdhd")
+	if !ok {
+		return nil, errors.Wrap(ErrInvalidMP4, "no mdhd box found")
+	}
+	off := 12
+	if mdhd.version() == 1 {
+		off = 20
+	}
+	if t.timescale, err = mdhd.uint32At(off); err != nil {
+		return nil, err
+	}
+	if t.timescale == 0 {
+		return nil, errors.Wrap(ErrInvalidMP4, "track timescale is zero")
+	}
+
+	for _, f := range t.fragments {
+		tfdt, ok := f.moof.find("traf", "tfdt")
+		if !ok {
+			continue
+		}
+		var dt uint64
+		if tfdt.version() == 1 && len(tfdt.Data) >= 12 {
+			dt = binary.BigEndian.Uint64(tfdt.Data[4:])
+		} else if len(tfdt.Data) >= 8 {
+			dt = uint64(binary.BigEndian.Uint32(tfdt.Data[4:]))
+		}
+		f.time = float64(dt) / float64(t.timescale)
+	}
+	return t, nil
+}
+
+// setTrackID changes the track ID of the track, in all of its boxes.
+func (t *fragmentedTrack) setTrackID(id uint32) error {
+	tkhd, ok := t.trak.child("tkhd")
+	if !ok {
+		return errors.Wrap(ErrInvalidMP4, "no tkhd box found")
+	}
+	off := 12
+	if tkhd.version() == 1 {
+		off = 20
+	}
+	if err := tkhd.setUint32At(off, id); err != nil {
+		return err
+	}
+	if err := t.trex.setUint32At(4, id); err != nil {
+		return err
+	}
+
+	for _, f := range t.fragments {
+		err := walkBoxes(f.moof.Data, func(box mp4Box) error {
+			if box.Type == "tfhd" {
+				return box.setUint32At(4, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// muxFragmented combines a fragmented video and a fragmented audio track
+// into a single fragmented MP4, written to w. Either track may be nil.
+//
+// The fragments are copied as is, interleaved by their decode time. As the
+// sample offsets in the fragments are relative to their moof box, only the
+// track and sequence numbers have to be rewritten.
+func muxFragmented(w io.Writer, video, audio []byte) error {
+	var tracks []*fragmentedTrack
+	for _, b := range [][]byte{video, audio} {
+		if b == nil {
+			continue
+		}
+		t, err := parseFragmentedTrack(b)
+		if err != nil {
+			return err
+		}
+		if err := t.setTrackID(uint32(len(tracks) + 1)); err != nil {
+			return err
+		}
+		tracks = append(tracks, t)
+	}
+	if len(tracks) == 0 {
+		return ErrDashNoRepresentation
+	}
+	main := tracks[0]
+
+	// Build the combined moov box from the first track
+	children, err := readBoxes(main.moov.Data)
+	if err != nil {
+		return err
+	}
+	moov := &boxBuffer{}
+	for _, c := range children {
+		switch c.Type {
+		case "mvhd":
+			// next_track_ID is the last field of the mvhd box
+			if err := c.setUint32At(len(c.Data)-4, uint32(len(tracks)+1)); err != nil {
+				return err
+			}
+			moov.box(c.Type, c.Data)
+		case "trak":
+			for _, t := range tracks {
+				moov.box("trak", t.trak.Data)
+			}
+		case "mvex":
+			mvex := &boxBuffer{}
+			mvexChildren, err := readBoxes(c.Data)
+			if err != nil {
+				return err
+			}
+			for _, mc := range mvexChildren {
+				if mc.Type != "trex" {
+					mvex.box(mc.Type, mc.Data)
+					continue
+				}
+				for _, t := range tracks {
+					mvex.box("trex", t.trex.Data)
+				}
+			}
+			moov.box("mvex", mvex.Bytes())
+		default:
+			moov.box(c.Type, c.Data)
+		}
+	}
+
+	out := &countingWriter{w: w}
+	if main.ftyp != nil {
+		if _, err := writeBox(out, "ftyp", main.ftyp); err != nil {
+			return err
+		}
+	}
+	if _, err := writeBox(out, "moov", moov.Bytes()); err != nil {
+		return err
+	}
+
+	var fragments []*mp4Fragment
+	for _, t := range tracks {
+		fragments = append(fragments, t.fragments...)
+	}
+	sort.SliceStable(fragments, func(i, j int) bool {
+		return fragments[i].time < fragments[j].time
+	})
+
+	for i, f := range fragments {
+		if err := f.rewrite(uint32(i+1), out.n); err != nil {
+			return err
+		}
+		if _, err := writeBox(out, "moof", f.moof.Data); err != nil {
+			return err
+		}
+		for _, m := range f.media {
+			if _, err := out.Write(m); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rewrite updates the sequence number of the fragment, and moves absolute
+// data offsets to the new position of the fragment.
+func (f *mp4Fragment) rewrite(seq uint32, pos int64) error {
+	return walkBoxes(f.moof.Data, func(box mp4Box) error {
+		switch box.Type {
+		case "mfhd":
+			return box.setUint32At(4, seq)
+		case "tfhd":
+			// base-data-offset-present
+			if box.flags()&0x1 == 0 {
+				return nil
+			}
+			if len(box.Data) < 16 {
+				return errors.Wrap(ErrInvalidMP4, "tfhd box too short")
+			}
+			base := int64(binary.BigEndian.Uint64(box.Data[8:]))
+			base += pos - int64(f.offset)
+			binary.BigEndian.PutUint64(box.Data[8:], uint64(base))
+		}
+		return nil
+	})
+}
+
+// boxBuffer is used to assemble boxes in memory.
+type boxBuffer struct {
+	buf []byte
+}
+
+func (b *boxBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+func (b *boxBuffer) box(typ string, payload []byte) {
+	writeBox(b, typ, payload)
+}
+
+func (b *boxBuffer) Bytes() []byte {
+	return b.buf
+}
+
+// countingWriter keeps track of the amount of bytes written.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
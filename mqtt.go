@@ -0,0 +1,147 @@
+package goinsta
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MQTT control packet types
+const (
+	mqttConnect    byte = 1
+	mqttConnAck    byte = 2
+	mqttPublish    byte = 3
+	mqttPubAck     byte = 4
+	mqttPingReq    byte = 12
+	mqttPingResp   byte = 13
+	mqttDisconnect byte = 14
+
+	// maximum remaining length of a packet
+	mqttMaxLength = 268435455
+)
+
+var errMQTTLength = errors.New("mqtt: malformed remaining length")
+
+// mqttPacket is an MQTT 3.1 control packet, Instagram's realtime broker speaks
+// a variant of it called MQTToT.
+type mqttPacket struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// readMQTTPacket reads the next packet from r.
+func readMQTTPacket(r *bufio.Reader) (*mqttPacket, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+
+	var length, shift int
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		length |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return nil, errMQTTLength
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return &mqttPacket{Type: header >> 4, Flags: header & 0x0f, Body: body}, nil
+}
+
+// Bytes encodes the packet.
+func (p *mqttPacket) Bytes() ([]byte, error) {
+	length := len(p.Body)
+	if length > mqttMaxLength {
+		return nil, errMQTTLength
+	}
+
+	b := []byte{p.Type<<4 | p.Flags}
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(b, p.Body...), nil
+}
+
+// mqttConnectPacket creates a connect packet, with a binary payload instead
+// of the client ID, will, and credentials of standard MQTT.
+func mqttConnectPacket(keepAlive uint16, payload []byte) *mqttPacket {
+	var body bytes.Buffer
+	writeMQTTString(&body, "MQTToT")
+	body.WriteByte(3)
+	// username, password and clean session flags
+	body.WriteByte(0xc2)
+	binary.Write(&body, binary.BigEndian, keepAlive)
+	body.Write(payload)
+	return &mqttPacket{Type: mqttConnect, Body: body.Bytes()}
+}
+
+// mqttPublishPacket creates a publish packet, with QoS 1 if id is not 0.
+func mqttPublishPacket(topic string, id uint16, payload []byte) *mqttPacket {
+	var body bytes.Buffer
+	writeMQTTString(&body, topic)
+	var flags byte
+	if id != 0 {
+		flags = 1 << 1
+		binary.Write(&body, binary.BigEndian, id)
+	}
+	body.Write(payload)
+	return &mqttPacket{Type: mqttPublish, Flags: flags, Body: body.Bytes()}
+}
+
+func mqttPubAckPacket(id uint16) *mqttPacket {
+	body := make([]byte, 2)
+	binary.BigEndian.PutUint16(body, id)
+	return &mqttPacket{Type: mqttPubAck, Body: body}
+}
+
+// publish splits a publish packet into its topic, packet ID and payload. The
+// packet ID is 0 for QoS 0.
+func (p *mqttPacket) publish() (string, uint16, []byte, error) {
+	b := p.Body
+	if len(b) < 2 {
+		return "", 0, nil, fmt.Errorf("mqtt: publish packet too short")
+	}
+	n := int(binary.BigEndian.Uint16(b))
+	if len(b) < 2+n {
+		return "", 0, nil, fmt.Errorf("mqtt: publish packet too short")
+	}
+	topic := string(b[2 : 2+n])
+	b = b[2+n:]
+
+	var id uint16
+	if qos := p.Flags >> 1 & 3; qos > 0 {
+		if len(b) < 2 {
+			return "", 0, nil, fmt.Errorf("mqtt: publish packet too short")
+		}
+		id = binary.BigEndian.Uint16(b)
+		b = b[2:]
+	}
+	return topic, id, b, nil
+}
+
+func writeMQTTString(w *bytes.Buffer, s string) {
+	binary.Write(w, binary.BigEndian, uint16(len(s)))
+	w.WriteString(s)
+}
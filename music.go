@@ -0,0 +1,327 @@
+package goinsta
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Default length of the part of a track that plays in a story
+const defaultMusicStickerDuration = 15 * time.Second
+
+// AudioTrack is a song from Instagram's music library, as returned by
+// Instagram.SearchMusic and Instagram.TrendingMusic. It can be added to
+// reels with UploadOptions.Audio, and to stories with a MusicSticker.
+type AudioTrack struct {
+	insta *Instagram
+
+	ID                              string `json:"id"`
+	AudioAssetID                    string `json:"audio_asset_id"`
+	AudioClusterID                  string `json:"audio_cluster_id"`
+	Title                           string `json:"title"`
+	Subtitle                        string `json:"subtitle"`
+	DisplayArtist                   string `json:"display_artist"`
+	ArtistID                        string `json:"artist_id"`
+	CoverArtworkURI                 string `json:"cover_artwork_uri"`
+	CoverArtworkThumbnailURI        string `json:"cover_artwork_thumbnail_uri"`
+	ProgressiveDownloadURL          string `json:"progressive_download_url"`
+	FastStartProgressiveDownloadURL string `json:"fast_start_progressive_download_url"`
+	DashManifest                    string `json:"dash_manifest"`
+	HighlightStartTimesInMs         []int  `json:"highlight_start_times_in_ms"`
+	DurationInMs                    int    `json:"duration_in_ms"`
+	IsExplicit                      bool   `json:"is_explicit"`
+	HasLyrics                       bool   `json:"has_lyrics"`
+	AllowsSaving                    bool   `json:"allows_saving"`
+	IgArtist                        *User  `json:"ig_artist"`
+}
+
+// AudioFeed is the feed of reels that use a track or original audio, see
+// AudioTrack.Reels and Instagram.AudioReels.
+type AudioFeed struct {
+	insta *Instagram
+	err   error
+	query map[string]string
+
+	Items     []*Item
+	NextID    string
+	Available bool
+}
+
+// SearchMusic searches Instagram's music library.
+func (insta *Instagram) SearchMusic(query string) ([]*AudioTrack, error) {
+	return insta.music(
+		urlMusicSearch,
+		map[string]string{
+			"query":             query,
+			"browse_session_id": generateUUID(),
+		},
+	)
+}
+
+// TrendingMusic returns the tracks that are currently trending.
+func (insta *Instagram) TrendingMusic() ([]*AudioTrack, error) {
+	return insta.music(
+		urlMusicTrending,
+		map[string]string{
+			"product":           "story_camera_clips_v2",
+			"browse_session_id": generateUUID(),
+		},
+	)
+}
+
+func (insta *Instagram) music(endpoint string, query map[string]string) ([]*AudioTrack, error) {
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: endpoint,
+			Query:    query,
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Items []struct {
+			Track *AudioTrack `json:"track"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, err
+	}
+	var tracks []*AudioTrack
+	for _, item := range res.Items {
+		if item.Track != nil {
+			item.Track.insta = insta
+			tracks = append(tracks, item.Track)
+		}
+	}
+	return tracks, nil
+}
+
+// PreviewURL returns the url of an audio file of the track, that can be
+// played or downloaded.
+func (t *AudioTrack) PreviewURL() string {
+	return firstNonEmpty(t.FastStartProgressiveDownloadURL, t.ProgressiveDownloadURL)
+}
+
+// Duration returns the length of the track.
+func (t *AudioTrack) Duration() time.Duration {
+	return time.Duration(t.DurationInMs) * time.Millisecond
+}
+
+// Reels returns the feed of reels that use the track. Use AudioFeed.Next to
+// fetch the first page.
+func (t *AudioTrack) Reels() *AudioFeed {
+	return t.insta.AudioReels(t.AudioClusterID)
+}
+
+// AudioReels returns the feed of reels that use an audio, by its audio
+// cluster ID, e.g. from the audio page link of a reel. Use AudioFeed.Next to
+// fetch the first page.
+func (insta *Instagram) AudioReels(audioClusterID string) *AudioFeed {
+	return &AudioFeed{
+		insta: insta,
+		query: map[string]string{
+			"audio_cluster_id": audioClusterID,
+			"_uuid":            insta.uuid,
+		},
+		Available: true,
+	}
+}
+
+// Next fetches the next page of reels. The reels of the new page are stored
+// in AudioFeed.Items, replacing the previous page.
+//
+// Returns false when there are no more pages, or an error occurred.
+func (feed *AudioFeed) Next() bool {
+	if feed.err != nil {
+		return false
+	}
+	insta := feed.insta
+
+	query := MergeMapS(feed.query, map[string]string{})
+	if feed.NextID != "" {
+		query["max_id"] = feed.NextID
+	}
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: urlMusicReels,
+			IsPost:   true,
+			Query:    query,
+		},
+	)
+	if err != nil {
+		feed.err = err
+		return false
+	}
+
+	var res struct {
+		Items []struct {
+			Media *Item `json:"media"`
+		} `json:"items"`
+		PagingInfo struct {
+			MaxID         string `json:"max_id"`
+			MoreAvailable bool   `json:"more_available"`
+		} `json:"paging_info"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		feed.err = err
+		return false
+	}
+
+	feed.Items = nil
+	for _, item := range res.Items {
+		if item.Media == nil {
+			continue
+		}
+		item.Media.insta = insta
+		item.Media.media = &FeedMedia{insta: insta, NextID: item.Media.ID}
+		feed.Items = append(feed.Items, item.Media)
+	}
+	feed.NextID = res.PagingInfo.MaxID
+	feed.Available = res.PagingInfo.MoreAvailable && feed.NextID != ""
+	if !feed.Available {
+		feed.err = ErrNoMore
+	}
+	return true
+}
+
+// Error returns the error of the last page fetch, if any.
+func (feed *AudioFeed) Error() error {
+	return feed.err
+}
+
+// MusicSticker adds a track to a story. Start defaults to the first
+// highlight of the track, Duration to 15 seconds.
+type MusicSticker struct {
+	StickerPosition
+	Track    *AudioTrack
+	Start    time.Duration
+	Duration time.Duration
+	// ShowLyrics shows the lyrics instead of the album art, if the track
+	// has lyrics.
+	ShowLyrics bool
+}
+
+func (s MusicSticker) sticker() (string, string, map[string]interface{}, error) {
+	if s.Track == nil || s.Track.ID == "" {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "music sticker needs a track")
+	}
+	start, err := s.Track.start(s.Start)
+	if err != nil {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, err.Error())
+	}
+	duration := s.Duration
+	if duration <= 0 {
+		duration = defaultMusicStickerDuration
+	}
+	display := "album_art"
+	if s.ShowLyrics && s.Track.HasLyrics {
+		display = "lyrics"
+	}
+	return "story_music_stickers", "music_overlay_sticker", s.data(0.5, 0.2, map[string]interface{}{
+		"music_asset_id":               s.Track.ID,
+		"audio_asset_id":               s.Track.AudioAssetID,
+		"audio_cluster_id":             s.Track.AudioClusterID,
+		"song_name":                    s.Track.Title,
+		"artist_name":                  s.Track.DisplayArtist,
+		"audio_asset_start_time_in_ms": start.Milliseconds(),
+		"overlap_duration_in_ms":       duration.Milliseconds(),
+		"display_type":                 display,
+		"is_sticker":                   true,
+		"tap_state":                    0,
+	}), nil
+}
+
+// start returns the offset to play the track from, which is the first
+// highlight if offset is 0.
+func (t *AudioTrack) start(offset time.Duration) (time.Duration, error) {
+	if offset == 0 && len(t.HighlightStartTimesInMs) > 0 {
+		offset = time.Duration(t.HighlightStartTimesInMs[0]) * time.Millisecond
+	}
+	if offset < 0 || (t.DurationInMs > 0 && offset >= t.Duration()) {
+		return 0, ConstraintError{
+			Constraint: "audio start",
+			Unit:       "s",
+			Value:      offset.Seconds(),
+			Max:        t.Duration().Seconds(),
+		}
+	}
+	return offset, nil
+}
+
+// checkAudio validates UploadOptions.Audio and AudioMix.
+func (o *UploadOptions) checkAudio() error {
+	if o.AudioMix < -1 || o.AudioMix > 1 {
+		return ConstraintError{Constraint: "audio mix", Value: o.AudioMix, Min: -1, Max: 1}
+	}
+	if o.Audio == nil {
+		return nil
+	}
+	if o.kind() != KindReel || len(o.Album) > 0 {
+		return ErrAudioNotReel
+	}
+	_, err := o.Audio.start(o.AudioStart)
+	return err
+}
+
+// addAudio adds UploadOptions.Audio and OriginalAudioName to a reel
+// configure payload.
+func (o *UploadOptions) addAudio(config map[string]interface{}) error {
+	if o.Audio == nil && o.OriginalAudioName == "" {
+		return nil
+	}
+	original := map[string]interface{}{"volume_level": 1.0}
+	if o.OriginalAudioName != "" {
+		original["title"] = o.OriginalAudioName
+	}
+	metadata := map[string]interface{}{"original": original}
+
+	if t := o.Audio; t != nil {
+		start, err := t.start(o.AudioStart)
+		if err != nil {
+			return err
+		}
+
+		// The track plays for the length of the video, or until it ends
+		overlap := time.Duration(o.duration) * time.Millisecond
+		if t.DurationInMs > 0 && t.Duration()-start < overlap {
+			overlap = t.Duration() - start
+		}
+
+		// Lowering one volume is what the mixer sliders in the app do
+		volume := func(v float64) float64 {
+			return math.Round(math.Min(v, 1)*100) / 100
+		}
+		original["volume_level"] = volume(1 + o.AudioMix)
+		metadata["song"] = map[string]interface{}{
+			"volume_level":         volume(1 - o.AudioMix),
+			"is_saved":             "0",
+			"artist_name":          t.DisplayArtist,
+			"audio_asset_id":       t.ID,
+			"audio_cluster_id":     t.AudioClusterID,
+			"track_name":           t.Title,
+			"is_picked_precapture": "1",
+		}
+		config["music_params"] = map[string]interface{}{
+			"audio_asset_id":                   t.ID,
+			"audio_cluster_id":                 t.AudioClusterID,
+			"audio_asset_start_time_in_ms":     start.Milliseconds(),
+			"derived_content_start_time_in_ms": 0,
+			"overlap_duration_in_ms":           overlap.Milliseconds(),
+			"product":                          "story_camera_clips_v2",
+			"song_name":                        t.Title,
+			"artist_name":                      t.DisplayArtist,
+			"alacorn_session_id":               "null",
+		}
+	}
+
+	b, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	config["clips_audio_metadata"] = string(b)
+	return nil
+}
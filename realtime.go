@@ -0,0 +1,584 @@
+package goinsta
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Realtime connection settings
+const (
+	realtimeAddr         = "edge-mqtt.facebook.com:443"
+	realtimeAppID        = 567067343352427
+	realtimeCapabilities = 183
+	realtimeClientStack  = 3
+	realtimeKeepAlive    = time.Minute
+	realtimeBackoff      = 5 * time.Second
+	realtimeMaxBackoff   = 5 * time.Minute
+	realtimeTimeout      = 30 * time.Second
+	realtimeBuffer       = 100
+)
+
+// Realtime topics, Instagram uses the numeric IDs as topic names
+const (
+	topicPubSub              = 88
+	topicSendMessageResponse = 133
+	topicIrisSub             = 134
+	topicIrisSubResponse     = 135
+	topicMessageSync         = 146
+	topicRealtimeSub         = 149
+	topicRegionHint          = 150
+)
+
+var (
+	rxpRealtimeItem     = regexp.MustCompile(`^/direct_v2/threads/([^/]+)/items/([^/]+)$`)
+	rxpRealtimeReaction = regexp.MustCompile(`^/direct_v2/threads/([^/]+)/items/([^/]+)/reactions/(?:likes|emojis)/([^/]+)$`)
+	rxpRealtimeTyping   = regexp.MustCompile(`^/direct_v2/threads/([^/]+)/activity_indicator_id/`)
+	rxpRealtimeSeen     = regexp.MustCompile(`^/direct_v2/threads/([^/]+)/participants/([^/]+)/has_seen$`)
+)
+
+// Realtime receives direct message events as they happen, over Instagram's
+// MQTT realtime connection. Create one with Instagram.NewRealtime, start it
+// with Run, and read the events from Events.
+type Realtime struct {
+	insta *Instagram
+
+	// Events receives the events, one of *MessageEvent, *MessageRemovedEvent,
+	// *ReactionEvent, *TypingEvent or *SeenEvent. It is closed when Run
+	// returns.
+	Events <-chan RealtimeEvent
+	events chan RealtimeEvent
+
+	// Addr of the broker, defaults to edge-mqtt.facebook.com:443
+	Addr string
+	// TLSConfig to connect with, by default the certificate of Addr is
+	// verified
+	TLSConfig *tls.Config
+	// KeepAlive is the interval to ping the broker at, defaults to a minute
+	KeepAlive time.Duration
+	// Backoff is the wait before reconnecting, which doubles for every failed
+	// attempt up to five minutes. Defaults to five seconds.
+	Backoff time.Duration
+	// OnError is called with the error the connection was lost with, before
+	// reconnecting. By default the error is passed to the warn handler.
+	OnError func(error)
+
+	mu           sync.Mutex
+	running      bool
+	seqID        int64
+	snapshotAtMs int64
+	packetID     uint16
+}
+
+// RealtimeEvent is an inbox event, received by Realtime or Inbox.Watch.
+type RealtimeEvent interface {
+	realtimeEvent()
+}
+
+// MessageEvent is a new message, or a change to an existing message.
+type MessageEvent struct {
+	ThreadID string
+	Item     *InboxItem
+	// Updated is set if the message already existed, e.g. when it has been
+	// edited
+	Updated bool
+}
+
+// MessageRemovedEvent is a message that has been unsent.
+type MessageRemovedEvent struct {
+	ThreadID string
+	ItemID   string
+}
+
+// ReactionEvent is a reaction that has been added to or removed from a
+// message.
+type ReactionEvent struct {
+	ThreadID string
+	ItemID   string
+	Reaction ItemReaction
+	Removed  bool
+}
+
+// TypingEvent is a user that started or stopped typing.
+type TypingEvent struct {
+	ThreadID string
+	UserID   int64
+	Typing   bool
+}
+
+// SeenEvent is a user that has seen the messages of a thread, up to ItemID.
+type SeenEvent struct {
+	ThreadID  string
+	UserID    int64
+	ItemID    string
+	Timestamp int64
+}
+
+func (*MessageEvent) realtimeEvent()        {}
+func (*MessageRemovedEvent) realtimeEvent() {}
+func (*ReactionEvent) realtimeEvent()       {}
+func (*TypingEvent) realtimeEvent()         {}
+func (*SeenEvent) realtimeEvent()           {}
+
+type messageSync struct {
+	Event string `json:"event"`
+	Data  []struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value string `json:"value"`
+	} `json:"data"`
+	SeqID int64 `json:"seq_id"`
+}
+
+// NewRealtime creates a realtime client, which receives the events since the
+// current state of Instagram.Inbox. If the inbox hasn't been synced, Run
+// syncs it first.
+func (insta *Instagram) NewRealtime() *Realtime {
+	events := make(chan RealtimeEvent, realtimeBuffer)
+	return &Realtime{
+		insta:        insta,
+		Events:       events,
+		events:       events,
+		Addr:         realtimeAddr,
+		KeepAlive:    realtimeKeepAlive,
+		Backoff:      realtimeBackoff,
+		seqID:        insta.Inbox.SeqID,
+		snapshotAtMs: insta.Inbox.SnapshotAtMs,
+	}
+}
+
+// State returns the inbox state the client will receive events from when it
+// reconnects.
+func (rt *Realtime) State() (seqID, snapshotAtMs int64) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	return rt.seqID, rt.snapshotAtMs
+}
+
+// SetState sets the inbox state to receive events from, e.g. to continue
+// from a State saved earlier. It needs to be called before Run.
+func (rt *Realtime) SetState(seqID, snapshotAtMs int64) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.seqID, rt.snapshotAtMs = seqID, snapshotAtMs
+}
+
+// Run connects to the broker and sends the events to Events, until ctx is
+// done. Lost connections are reconnected, continuing from the last event
+// received. Run returns when ctx is done, or when the broker refuses the
+// session. A client can only be run once.
+func (rt *Realtime) Run(ctx context.Context) error {
+	rt.mu.Lock()
+	if rt.insta == nil || rt.events == nil {
+		rt.mu.Unlock()
+		return ErrRealtimeNotCreated
+	}
+	if rt.running {
+		rt.mu.Unlock()
+		return ErrRealtimeRunning
+	}
+	rt.running = true
+	if rt.Addr == "" {
+		rt.Addr = realtimeAddr
+	}
+	if rt.KeepAlive <= 0 {
+		rt.KeepAlive = realtimeKeepAlive
+	}
+	if rt.Backoff <= 0 {
+		rt.Backoff = realtimeBackoff
+	}
+	rt.mu.Unlock()
+	defer close(rt.events)
+
+	if _, snapshot := rt.State(); snapshot == 0 {
+		if err := rt.resync(); err != nil {
+			return err
+		}
+	}
+
+	backoff := rt.Backoff
+	for {
+		connected, err := rt.connect(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == ErrRealtimeRefused || err == ErrLoginRequired {
+			return err
+		}
+		if connected {
+			backoff = rt.Backoff
+		}
+		if rt.OnError != nil {
+			rt.OnError(err)
+		} else {
+			rt.insta.warnHandler(fmt.Errorf("realtime connection lost, reconnecting in %s: %w", backoff, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > realtimeMaxBackoff {
+			backoff = realtimeMaxBackoff
+		}
+	}
+}
+
+// connect runs a single connection, and returns whether the broker accepted
+// it, and the error the connection was lost with.
+func (rt *Realtime) connect(ctx context.Context) (bool, error) {
+	payload, err := rt.connectPayload()
+	if err != nil {
+		return false, err
+	}
+
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: realtimeTimeout},
+		Config:    rt.TLSConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", rt.Addr)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	// Close the connection to stop reading when ctx is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	write := func(p *mqttPacket) error {
+		b, err := p.Bytes()
+		if err != nil {
+			return err
+		}
+		conn.SetWriteDeadline(time.Now().Add(realtimeTimeout))
+		_, err = conn.Write(b)
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	if err := write(mqttConnectPacket(uint16(rt.KeepAlive/time.Second), payload)); err != nil {
+		return false, err
+	}
+	conn.SetReadDeadline(time.Now().Add(realtimeTimeout))
+	p, err := readMQTTPacket(r)
+	if err != nil {
+		return false, err
+	}
+	if p.Type != mqttConnAck || len(p.Body) < 2 {
+		return false, fmt.Errorf("expected connack, got packet of type %d", p.Type)
+	}
+	switch code := p.Body[1]; code {
+	case 0:
+	case 4, 5:
+		return false, ErrRealtimeRefused
+	default:
+		return false, fmt.Errorf("realtime connection refused with code %d", code)
+	}
+
+	// Subscribe to the events since the current state
+	sub, err := rt.irisSubscription()
+	if err != nil {
+		return true, err
+	}
+	if err := write(mqttPublishPacket(strconv.Itoa(topicIrisSub), rt.nextPacketID(), sub)); err != nil {
+		return true, err
+	}
+
+	packets := make(chan *mqttPacket)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			conn.SetReadDeadline(time.Now().Add(2 * rt.KeepAlive))
+			p, err := readMQTTPacket(r)
+			if err != nil {
+				errs <- err
+				return
+			}
+			select {
+			case packets <- p:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(rt.KeepAlive)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			write(&mqttPacket{Type: mqttDisconnect})
+			return true, ctx.Err()
+		case err := <-errs:
+			return true, err
+		case <-ping.C:
+			if err := write(&mqttPacket{Type: mqttPingReq}); err != nil {
+				return true, err
+			}
+		case p := <-packets:
+			if p.Type != mqttPublish {
+				continue
+			}
+			topic, id, payload, err := p.publish()
+			if err != nil {
+				return true, err
+			}
+			if id != 0 {
+				if err := write(mqttPubAckPacket(id)); err != nil {
+					return true, err
+				}
+			}
+			if err := rt.handle(ctx, topic, payload); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+// handle processes a message published by the broker.
+func (rt *Realtime) handle(ctx context.Context, topic string, payload []byte) error {
+	data, err := inflate(payload)
+	if err != nil {
+		return err
+	}
+
+	switch topic {
+	case strconv.Itoa(topicIrisSubResponse):
+		var resp struct {
+			Succeeded    bool   `json:"succeeded"`
+			ErrorType    int    `json:"error_type"`
+			ErrorMessage string `json:"error_message"`
+		}
+		if err := json.Unmarshal(data, &resp); err != nil {
+			return err
+		}
+		if !resp.Succeeded {
+			// The state is too old to continue from, start from the current
+			// inbox instead
+			if err := rt.resync(); err != nil {
+				return err
+			}
+			return fmt.Errorf("failed to subscribe to inbox events: %s (%d)", resp.ErrorMessage, resp.ErrorType)
+		}
+	case strconv.Itoa(topicMessageSync):
+		var syncs []messageSync
+		if err := json.Unmarshal(data, &syncs); err != nil {
+			return err
+		}
+		for _, s := range syncs {
+			for _, op := range s.Data {
+				ev := rt.parseEvent(op.Op, op.Path, op.Value)
+				if ev == nil {
+					continue
+				}
+				select {
+				case rt.events <- ev:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			rt.mu.Lock()
+			if s.SeqID > rt.seqID {
+				rt.seqID = s.SeqID
+			}
+			rt.mu.Unlock()
+		}
+	}
+	return nil
+}
+
+// parseEvent converts an inbox patch operation into an event, nil is
+// returned for unknown operations.
+func (rt *Realtime) parseEvent(op, path, value string) RealtimeEvent {
+	removed := op == "remove"
+
+	if m := rxpRealtimeReaction.FindStringSubmatch(path); m != nil {
+		ev := &ReactionEvent{ThreadID: m[1], ItemID: m[2], Removed: removed}
+		if !removed {
+			json.Unmarshal([]byte(value), &ev.Reaction)
+		}
+		if ev.Reaction.SenderID == 0 {
+			ev.Reaction.SenderID, _ = strconv.ParseInt(m[3], 10, 64)
+		}
+		return ev
+	}
+
+	if m := rxpRealtimeItem.FindStringSubmatch(path); m != nil {
+		if removed {
+			return &MessageRemovedEvent{ThreadID: m[1], ItemID: m[2]}
+		}
+		item := &InboxItem{}
+		if err := json.Unmarshal([]byte(value), item); err != nil {
+			rt.insta.warnHandler(fmt.Errorf("failed to parse realtime message: %w", err))
+			return nil
+		}
+		item.setValues(rt.insta)
+		return &MessageEvent{ThreadID: m[1], Item: item, Updated: op == "replace"}
+	}
+
+	if m := rxpRealtimeTyping.FindStringSubmatch(path); m != nil {
+		var indicator struct {
+			SenderID       json.Number `json:"sender_id"`
+			ActivityStatus int         `json:"activity_status"`
+		}
+		json.Unmarshal([]byte(value), &indicator)
+		userID, _ := indicator.SenderID.Int64()
+		return &TypingEvent{
+			ThreadID: m[1],
+			UserID:   userID,
+			Typing:   !removed && indicator.ActivityStatus == 1,
+		}
+	}
+
+	if m := rxpRealtimeSeen.FindStringSubmatch(path); m != nil && !removed {
+		var seen struct {
+			ItemID    string      `json:"item_id"`
+			Timestamp json.Number `json:"timestamp"`
+		}
+		json.Unmarshal([]byte(value), &seen)
+		ev := &SeenEvent{ThreadID: m[1], ItemID: seen.ItemID}
+		ev.UserID, _ = strconv.ParseInt(m[2], 10, 64)
+		ev.Timestamp, _ = seen.Timestamp.Int64()
+		return ev
+	}
+	return nil
+}
+
+// resync syncs the inbox, and continues from its state.
+func (rt *Realtime) resync() error {
+	inbox := rt.insta.Inbox
+	if err := inbox.Sync(); err != nil {
+		return err
+	}
+	rt.SetState(inbox.SeqID, inbox.SnapshotAtMs)
+	return nil
+}
+
+// connectPayload creates the Thrift encoded, compressed payload of the
+// connect packet, which holds the client info and credentials.
+func (rt *Realtime) connectPayload() ([]byte, error) {
+	insta := rt.insta
+	auth, ok := insta.headerOptions.Load("Authorization")
+	if !ok || auth.(string) == "" || insta.Account == nil {
+		return nil, ErrLoginRequired
+	}
+
+	clientID := insta.pid
+	if len(clientID) > 20 {
+		clientID = clientID[:20]
+	}
+
+	w := &thriftWriter{}
+	w.Binary(1, clientID)
+	w.Struct(4)
+	w.I64(1, insta.Account.ID)
+	w.Binary(2, insta.userAgent)
+	w.I64(3, realtimeCapabilities)
+	w.I64(4, 0)
+	w.I32(5, 1)
+	w.Bool(6, false)
+	w.Bool(7, true)
+	w.Binary(8, insta.pid)
+	w.Bool(9, true)
+	w.I32(10, 1)
+	w.I32(11, 0)
+	w.I64(12, time.Now().UnixNano()/int64(time.Millisecond)&0xffffffff)
+	w.ListI32(14, []int32{
+		topicPubSub, topicIrisSubResponse, topicRealtimeSub,
+		topicRegionHint, topicSendMessageResponse, topicMessageSync,
+	})
+	w.Binary(15, "cookie_auth")
+	w.I64(16, realtimeAppID)
+	w.Binary(20, "")
+	w.Byte(21, realtimeClientStack)
+	w.Stop()
+	w.Binary(5, "authorization="+auth.(string))
+	w.MapBinary(10, map[string]string{
+		"app_version":               appVersion,
+		"X-IG-Capabilities":         igCapabilities,
+		"User-Agent":                insta.userAgent,
+		"Accept-Language":           "en-US",
+		"platform":                  "android",
+		"ig_mqtt_route":             "django",
+		"pubsub_msg_type_blacklist": "direct, typing_type",
+		"auth_cache_enabled":        "0",
+	})
+	w.Stop()
+	return deflate(w.Bytes())
+}
+
+// irisSubscription creates the payload to subscribe to the inbox events
+// since the current state.
+func (rt *Realtime) irisSubscription() ([]byte, error) {
+	seqID, snapshotAtMs := rt.State()
+	offset, _ := strconv.Atoi(getTimeOffset())
+	b, err := json.Marshal(map[string]interface{}{
+		"seq_id":               seqID,
+		"snapshot_at_ms":       snapshotAtMs,
+		"snapshot_app_version": "message",
+		"timezone_offset":      offset,
+		"subscription_type":    "message",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deflate(b)
+}
+
+func (rt *Realtime) nextPacketID() uint16 {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.packetID++
+	if rt.packetID == 0 {
+		rt.packetID++
+	}
+	return rt.packetID
+}
+
+func deflate(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := zlib.NewWriterLevel(&buf, zlib.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// inflate decompresses a payload, payloads that aren't compressed are
+// returned as is.
+func inflate(b []byte) ([]byte, error) {
+	if len(b) == 0 || b[0] != 0x78 {
+		return b, nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -65,6 +65,9 @@ type reqOptions struct {
 	// If Status 429 should be ignored, ErrTooManyRequests. This behaviour should be implemented in
 	//  the wrapper. Goinsta does nothing directly with this value.
 	Ignore429 bool
+
+	// Context of the request, can be used to cancel it. Defaults to context.Background()
+	Context context.Context
 }
 
 func (insta *Instagram) sendSimpleRequest(uri string, a ...interface{}) (body []byte, err error) {
@@ -163,8 +166,13 @@ func (insta *Instagram) sendRequest(o *reqOptions) (body []byte, h http.Header,
 		u.RawQuery = vs.Encode()
 	}
 
+	ctx := o.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
 	var req *http.Request
-	req, err = http.NewRequest(method, u.String(), bf)
+	req, err = http.NewRequestWithContext(ctx, method, u.String(), bf)
 	if err != nil {
 		return
 	}
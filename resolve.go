@@ -0,0 +1,279 @@
+package goinsta
+
+import (
+	"encoding/base64"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+)
+
+// Types of links returned by ParseURL.
+const (
+	URLMedia     = "media"
+	URLUser      = "user"
+	URLStory     = "story"
+	URLStories   = "stories"
+	URLHighlight = "highlight"
+	URLHashtag   = "hashtag"
+	URLLocation  = "location"
+	URLShare     = "share"
+)
+
+// maximum amount of share link redirects to follow
+const maxResolveRedirects = 3
+
+// ParsedURL is an Instagram link, split into its parts by ParseURL.
+type ParsedURL struct {
+	// Type is one of the URL* constants
+	Type string
+
+	// Code is the short code of a post, reel or IGTV video
+	Code string
+	// ID is the media ID of a post or story, the highlight ID, or the
+	// location ID
+	ID string
+	// Username is set for profile and story links
+	Username string
+	// Tag is the name of the hashtag, without the #
+	Tag string
+
+	url *neturl.URL
+}
+
+// instagramHosts are the domains of which links can be parsed.
+var instagramHosts = map[string]bool{
+	"instagram.com":     true,
+	"www.instagram.com": true,
+	"m.instagram.com":   true,
+	"instagr.am":        true,
+	"www.instagr.am":    true,
+}
+
+// reservedPaths are top level paths that are not usernames.
+var reservedPaths = map[string]bool{
+	"accounts": true, "direct": true, "about": true, "legal": true,
+	"developer": true, "explore": true, "web": true, "emails": true,
+	"challenge": true, "privacy": true, "session": true, "static": true,
+}
+
+// ParseURL parses any kind of Instagram link, without making any requests.
+//
+// Supported are posts (/p/<code>), reels (/reel/<code>), IGTV (/tv/<code>),
+// stories (/stories/<user>/<id>), highlights (/stories/highlights/<id>),
+// hashtags (/explore/tags/<tag>), locations (/explore/locations/<id>),
+// profiles (/<username>), and share links (/share/..., /s/...). Links can
+// be on instagram.com or instagr.am, with or without scheme.
+//
+// Share links with a path of /share/ can only be resolved by following the
+// redirect, this is done by Instagram.Resolve.
+func ParseURL(link string) (*ParsedURL, error) {
+	link = strings.TrimSpace(link)
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	u, err := neturl.Parse(link)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	if !instagramHosts[strings.ToLower(u.Hostname())] {
+		return nil, ErrInvalidURL
+	}
+
+	var parts []string
+	for _, p := range strings.Split(u.Path, "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return nil, ErrUnsupportedURL
+	}
+
+	r := &ParsedURL{url: u}
+	switch parts[0] {
+	case "p", "reel", "reels", "tv":
+		if len(parts) < 2 {
+			return nil, ErrUnsupportedURL
+		}
+		return r.media(parts[1])
+	case "stories":
+		switch {
+		case len(parts) >= 3 && parts[1] == "highlights":
+			r.Type = URLHighlight
+			r.ID = parts[2]
+		case len(parts) >= 3:
+			r.Type = URLStory
+			r.Username = parts[1]
+			r.ID = parts[2]
+		case len(parts) == 2:
+			r.Type = URLStories
+			r.Username = parts[1]
+		default:
+			return nil, ErrUnsupportedURL
+		}
+	case "explore":
+		if len(parts) < 3 {
+			return nil, ErrUnsupportedURL
+		}
+		switch parts[1] {
+		case "tags":
+			r.Type = URLHashtag
+			r.Tag = parts[2]
+		case "locations":
+			r.Type = URLLocation
+			r.ID = parts[2]
+		default:
+			return nil, ErrUnsupportedURL
+		}
+	case "s":
+		// Highlight share links contain a base64 encoded "highlight:<id>"
+		if len(parts) < 2 {
+			return nil, ErrUnsupportedURL
+		}
+		id, ok := decodeHighlightShare(parts[1])
+		if !ok {
+			return nil, ErrUnsupportedURL
+		}
+		r.Type = URLHighlight
+		r.ID = id
+	case "share":
+		r.Type = URLShare
+	default:
+		if reservedPaths[parts[0]] {
+			return nil, ErrUnsupportedURL
+		}
+		// Newer post links include the username, e.g. /<username>/p/<code>
+		if len(parts) >= 3 {
+			switch parts[1] {
+			case "p", "reel", "tv":
+				r.Username = parts[0]
+				return r.media(parts[2])
+			}
+		}
+		r.Type = URLUser
+		r.Username = parts[0]
+	}
+	return r, nil
+}
+
+func (r *ParsedURL) media(code string) (*ParsedURL, error) {
+	// Short codes of private posts are longer, but only the first 11
+	// characters make up the media ID.
+	if len(code) > 11 {
+		code = code[:11]
+	}
+	id, err := MediaIDFromShortID(code)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	r.Type = URLMedia
+	r.Code = code
+	r.ID = id
+	return r, nil
+}
+
+// String returns the original URL.
+func (r *ParsedURL) String() string {
+	return r.url.String()
+}
+
+func decodeHighlightShare(s string) (string, bool) {
+	s = strings.TrimRight(s, "=")
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.RawStdEncoding} {
+		b, err := enc.DecodeString(s)
+		if err != nil {
+			continue
+		}
+		id := strings.TrimPrefix(string(b), "highlight:")
+		if id != string(b) && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Resolve fetches the object an Instagram link points to. The result is one
+// of *Item, *User, *Reel, *Hashtag or *Location, depending on the link. See
+// ParseURL for the supported links.
+//
+//	obj, err := insta.Resolve("https://www.instagram.com/p/CZXMm0vLBrr/")
+//	switch o := obj.(type) {
+//	case *goinsta.Item:
+//	case *goinsta.User:
+//	}
+//
+// Share links are followed to their destination first. For location links,
+// only the ID of the returned Location is set, call Location.Feed to
+// fetch its posts.
+func (insta *Instagram) Resolve(link string) (interface{}, error) {
+	r, err := ParseURL(link)
+	for i := 0; err == nil && r.Type == URLShare; i++ {
+		if i == maxResolveRedirects {
+			return nil, ErrUnsupportedURL
+		}
+		link, err = insta.followRedirects(r.String())
+		if err == nil {
+			r, err = ParseURL(link)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch r.Type {
+	case URLMedia, URLStory:
+		media, err := insta.GetMedia(r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(media.Items) == 0 {
+			return nil, ErrMediaDeleted
+		}
+		return media.Items[0], nil
+	case URLUser:
+		return insta.Profiles.ByName(r.Username)
+	case URLStories:
+		user, err := insta.Profiles.ByName(r.Username)
+		if err != nil {
+			return nil, err
+		}
+		stories, err := user.Stories()
+		if err != nil {
+			return nil, err
+		}
+		return &stories.Reel, nil
+	case URLHighlight:
+		reel := &Reel{
+			insta:    insta,
+			ID:       "highlight:" + r.ID,
+			ReelType: "highlight_reel",
+		}
+		return reel, reel.Sync()
+	case URLHashtag:
+		h := insta.NewHashtag(r.Tag)
+		return h, h.Info()
+	case URLLocation:
+		id, err := strconv.ParseInt(r.ID, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidURL
+		}
+		return &Location{insta: insta, ID: id}, nil
+	}
+	return nil, ErrUnsupportedURL
+}
+
+// followRedirects returns the final URL a link redirects to.
+func (insta *Instagram) followRedirects(link string) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", insta.userAgent)
+	resp, err := insta.c.Do(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return resp.Request.URL.String(), nil
+}
@@ -0,0 +1,536 @@
+package goinsta
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultScheduleRetries = 3
+	defaultScheduleBackoff = time.Minute
+)
+
+// ScheduleStatus is the state of a scheduled post.
+type ScheduleStatus string
+
+const (
+	// The post is waiting to be published, or to be retried
+	SchedulePending ScheduleStatus = "pending"
+	// The post has been published, see ScheduledPost.MediaID
+	SchedulePublished ScheduleStatus = "published"
+	// The post failed permanently, see ScheduledPost.LastError
+	ScheduleFailed ScheduleStatus = "failed"
+)
+
+// ScheduledPost is an upload queued with Scheduler.Schedule. Media is
+// referenced by file path, so the post can be stored and published after a
+// restart. The options are the same as those of UploadOptions.
+type ScheduledPost struct {
+	ID        string    `json:"id"`
+	PublishAt time.Time `json:"publish_at"`
+
+	// Files to upload. Multiple files are posted as a carousel, or as
+	// multiple stories if IsStory is set.
+	Files           []string `json:"files"`
+	Thumbnail       string   `json:"thumbnail,omitempty"`
+	AlbumThumbnails []string `json:"album_thumbnails,omitempty"`
+
+	Caption      string           `json:"caption,omitempty"`
+	IsStory      bool             `json:"is_story,omitempty"`
+	Kind         UploadKind       `json:"kind,omitempty"`
+	Title        string           `json:"title,omitempty"`
+	CoverFrame   time.Duration    `json:"cover_frame,omitempty"`
+	AltText      string           `json:"alt_text,omitempty"`
+	AlbumAltText []string         `json:"album_alt_text,omitempty"`
+	UserTags     []ScheduledTag   `json:"user_tags,omitempty"`
+	AlbumTags    [][]ScheduledTag `json:"album_tags,omitempty"`
+	Location     *LocationTag     `json:"location,omitempty"`
+	Conform      ConformMode      `json:"conform,omitempty"`
+	Gravity      Gravity          `json:"gravity,omitempty"`
+
+	MuteAudio            bool `json:"mute_audio,omitempty"`
+	DisableComments      bool `json:"disable_comments,omitempty"`
+	DisableLikeViewCount bool `json:"disable_like_view_count,omitempty"`
+	DisableSubtitles     bool `json:"disable_subtitles,omitempty"`
+	DisableShareToFeed   bool `json:"disable_share_to_feed,omitempty"`
+
+	// Set by the scheduler
+	Status      ScheduleStatus `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"next_attempt,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	MediaID     string         `json:"media_id,omitempty"`
+	PublishedAt time.Time      `json:"published_at,omitempty"`
+}
+
+// ScheduledTag is a user tag of a scheduled post, see UserTag.
+type ScheduledTag struct {
+	UserID   int64      `json:"user_id"`
+	Position [2]float64 `json:"position"`
+}
+
+// ScheduleStore persists scheduled posts, so they survive restarts. Load is
+// called once when creating the Scheduler, Save after every change to a post.
+type ScheduleStore interface {
+	Load() ([]*ScheduledPost, error)
+	Save(post *ScheduledPost) error
+	Delete(id string) error
+}
+
+// Scheduler publishes posts at their scheduled time, with the session of the
+// account it was created with. Posts are kept in a ScheduleStore, failed
+// uploads are retried with an exponential backoff, unless the error is
+// permanent, e.g. an invalid file.
+//
+// If the process is stopped during an upload, the post is uploaded again
+// after a restart.
+//
+//	store := goinsta.NewScheduleFileStore("./schedule.json")
+//	s, err := insta.NewScheduler(store)
+//	if err != nil {
+//		panic(err)
+//	}
+//	_, err = s.Schedule(&goinsta.ScheduledPost{
+//		Files:     []string{"./photo.jpg"},
+//		Caption:   "Good morning!",
+//		PublishAt: time.Now().Add(24 * time.Hour),
+//	})
+//	err = s.Run(ctx)
+type Scheduler struct {
+	insta *Instagram
+	store ScheduleStore
+
+	// Retries is the number of times a failed upload is retried. Defaults to
+	// 3, set to 0 to disable retries.
+	Retries int
+	// Backoff is the delay before the first retry, it doubles for every next
+	// retry. Defaults to one minute.
+	Backoff time.Duration
+
+	// OnPublished is called after a post has been published. Can be nil.
+	OnPublished func(post ScheduledPost, item *Item)
+	// OnRetry is called after an upload failed, and will be retried at
+	// post.NextAttempt. Can be nil.
+	OnRetry func(post ScheduledPost, err error)
+	// OnFailed is called when a post failed permanently, or ran out of
+	// retries. Can be nil.
+	OnFailed func(post ScheduledPost, err error)
+
+	posts   map[string]*ScheduledPost
+	wake    chan struct{}
+	running bool
+	mu      *sync.Mutex
+}
+
+// NewScheduler creates a scheduler that keeps its posts in store, and loads
+// the posts of a previous run.
+func (insta *Instagram) NewScheduler(store ScheduleStore) (*Scheduler, error) {
+	s := &Scheduler{
+		insta:   insta,
+		store:   store,
+		Retries: defaultScheduleRetries,
+		Backoff: defaultScheduleBackoff,
+		posts:   make(map[string]*ScheduledPost),
+		wake:    make(chan struct{}, 1),
+		mu:      &sync.Mutex{},
+	}
+
+	posts, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, post := range posts {
+		s.posts[post.ID] = post
+	}
+	return s, nil
+}
+
+// Schedule adds a post to the queue and stores it. A post without PublishAt
+// is published as soon as possible. The ID of the post is returned, and can
+// be used to cancel it.
+func (s *Scheduler) Schedule(post *ScheduledPost) (string, error) {
+	if len(post.Files) == 0 {
+		return "", ErrScheduleNoMedia
+	}
+	for _, f := range append(append([]string{post.Thumbnail}, post.Files...), post.AlbumThumbnails...) {
+		if f == "" {
+			continue
+		}
+		if _, err := os.Stat(f); err != nil {
+			return "", err
+		}
+	}
+
+	p := *post
+	p.ID = generateUUID()
+	p.Status = SchedulePending
+	p.Attempts, p.NextAttempt, p.LastError = 0, time.Time{}, ""
+	if p.PublishAt.IsZero() {
+		p.PublishAt = time.Now()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.Save(&p); err != nil {
+		return "", err
+	}
+	s.posts[p.ID] = &p
+	s.notify()
+	return p.ID, nil
+}
+
+// Cancel removes a post from the queue and the store.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.posts[id]; !ok {
+		return ErrScheduleNotFound
+	}
+	if err := s.store.Delete(id); err != nil {
+		return err
+	}
+	delete(s.posts, id)
+	s.notify()
+	return nil
+}
+
+// Posts returns all posts known to the scheduler, including published and
+// failed ones, ordered by PublishAt.
+func (s *Scheduler) Posts() []ScheduledPost {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	posts := make([]ScheduledPost, 0, len(s.posts))
+	for _, p := range s.posts {
+		posts = append(posts, *p)
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return posts[i].PublishAt.Before(posts[j].PublishAt)
+	})
+	return posts
+}
+
+// Run publishes posts as they become due, until ctx is canceled. Posts are
+// uploaded one at a time. An upload that is interrupted by canceling ctx
+// is not counted as an attempt. Run always returns a non-nil error.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.running {
+		s.mu.Unlock()
+		return ErrSchedulerRunning
+	}
+	s.running = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+	}()
+
+	for {
+		post, wait := s.next()
+		if post != nil && wait <= 0 {
+			s.publish(ctx, post)
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		if post != nil {
+			timer = time.NewTimer(wait)
+			due = timer.C
+		}
+		select {
+		case <-ctx.Done():
+		case <-s.wake:
+		case <-due:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// next returns the pending post that is due first, and the time until it
+// is due.
+func (s *Scheduler) next() (*ScheduledPost, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var next *ScheduledPost
+	var at time.Time
+	for _, p := range s.posts {
+		if p.Status != SchedulePending {
+			continue
+		}
+		t := p.PublishAt
+		if p.NextAttempt.After(t) {
+			t = p.NextAttempt
+		}
+		if next == nil || t.Before(at) {
+			next, at = p, t
+		}
+	}
+	if next == nil {
+		return nil, 0
+	}
+	return next, time.Until(at)
+}
+
+// publish uploads a post, and records the outcome.
+func (s *Scheduler) publish(ctx context.Context, post *ScheduledPost) {
+	s.mu.Lock()
+	p := *post
+	s.mu.Unlock()
+
+	var item *Item
+	configured := false
+	o, closeFiles, err := p.options()
+	if err == nil {
+		o.Context = ctx
+		item, err = s.insta.Upload(o)
+		closeFiles()
+		configured = o.configured
+	}
+	if ctx.Err() != nil {
+		// Interrupted, the post will be uploaded on the next run
+		return
+	}
+
+	p.Attempts++
+	retry := false
+	switch {
+	case err == nil:
+		p.Status = SchedulePublished
+		p.MediaID = item.GetID()
+		p.PublishedAt = time.Now()
+		p.LastError = ""
+	case !configured && isTransient(err) && p.Attempts <= s.Retries:
+		retry = true
+		p.NextAttempt = time.Now().Add(s.Backoff << (p.Attempts - 1))
+		p.LastError = err.Error()
+	default:
+		p.Status = ScheduleFailed
+		p.LastError = err.Error()
+	}
+
+	s.mu.Lock()
+	if _, ok := s.posts[p.ID]; !ok {
+		// Canceled during the upload
+		s.mu.Unlock()
+		return
+	}
+	*post = p
+	if serr := s.store.Save(post); serr != nil {
+		s.insta.warnHandler("Failed to store scheduled post", p.ID, serr)
+	}
+	s.mu.Unlock()
+
+	switch {
+	case err == nil && s.OnPublished != nil:
+		s.OnPublished(p, item)
+	case retry && s.OnRetry != nil:
+		s.OnRetry(p, err)
+	case err != nil && !retry && s.OnFailed != nil:
+		s.OnFailed(p, err)
+	}
+}
+
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// options opens the files of a post, and returns the upload options and a
+// function to close the files.
+func (p *ScheduledPost) options() (*UploadOptions, func(), error) {
+	var files []*os.File
+	closeFiles := func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	open := func(name string) (io.Reader, error) {
+		if name == "" {
+			return nil, nil
+		}
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		return f, nil
+	}
+
+	o := &UploadOptions{
+		Caption:              p.Caption,
+		IsStory:              p.IsStory,
+		Kind:                 p.Kind,
+		Title:                p.Title,
+		CoverFrame:           p.CoverFrame,
+		AltText:              p.AltText,
+		AlbumAltText:         p.AlbumAltText,
+		Location:             p.Location,
+		Conform:              p.Conform,
+		Gravity:              p.Gravity,
+		MuteAudio:            p.MuteAudio,
+		DisableComments:      p.DisableComments,
+		DisableLikeViewCount: p.DisableLikeViewCount,
+		DisableSubtitles:     p.DisableSubtitles,
+		DisableShareToFeed:   p.DisableShareToFeed,
+	}
+	if len(p.UserTags) > 0 {
+		tags := userTags(p.UserTags)
+		o.UserTags = &tags
+	}
+	if len(p.AlbumTags) > 0 {
+		tags := make([][]UserTag, len(p.AlbumTags))
+		for i, t := range p.AlbumTags {
+			tags[i] = userTags(t)
+		}
+		o.AlbumTags = &tags
+	}
+
+	var err error
+	if o.Thumbnail, err = open(p.Thumbnail); err != nil {
+		closeFiles()
+		return nil, nil, err
+	}
+	for _, name := range p.AlbumThumbnails {
+		f, err := open(name)
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		o.AlbumThumbnails = append(o.AlbumThumbnails, f)
+	}
+	for _, name := range p.Files {
+		f, err := open(name)
+		if err != nil {
+			closeFiles()
+			return nil, nil, err
+		}
+		o.Album = append(o.Album, f)
+	}
+	if len(o.Album) == 1 {
+		o.File, o.Album = o.Album[0], nil
+	}
+	return o, closeFiles, nil
+}
+
+func userTags(tags []ScheduledTag) []UserTag {
+	var out []UserTag
+	for _, t := range tags {
+		out = append(out, UserTag{User: &User{ID: t.UserID}, Position: t.Position})
+	}
+	return out
+}
+
+// isTransient reports whether an upload that failed with err can be retried,
+// which is only the case for network errors and server errors. Uploads are
+// never retried once they have been configured, as the post may have been
+// published even if the request failed.
+func isTransient(err error) bool {
+	var nerr net.Error
+	var serr ErrorN
+	var unavailable Error503
+	switch {
+	case errors.As(err, &nerr), errors.Is(err, io.ErrUnexpectedEOF):
+		return true
+	case errors.As(err, &serr):
+		return statusCode(serr) >= 500
+	}
+	return errors.As(err, &unavailable)
+}
+
+// statusCode returns the HTTP status code of err. The status field is
+// overwritten by the response body if it has one, e.g. with "fail", in which
+// case the code is taken from the HTTP status line kept in the error type.
+func statusCode(err ErrorN) int {
+	if code, cerr := strconv.Atoi(err.Status); cerr == nil {
+		return code
+	}
+	if len(err.ErrorType) >= 3 {
+		if code, cerr := strconv.Atoi(err.ErrorType[:3]); cerr == nil {
+			return code
+		}
+	}
+	return 0
+}
+
+// NewScheduleFileStore returns a ScheduleStore that keeps all posts in a
+// single JSON file. The file is replaced atomically on every change.
+func NewScheduleFileStore(path string) ScheduleStore {
+	return &fileScheduleStore{path: path, mu: &sync.Mutex{}}
+}
+
+type fileScheduleStore struct {
+	path string
+	mu   *sync.Mutex
+}
+
+func (f *fileScheduleStore) Load() ([]*ScheduledPost, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.load()
+}
+
+func (f *fileScheduleStore) Save(post *ScheduledPost) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	posts, err := f.load()
+	if err != nil {
+		return err
+	}
+	for i, p := range posts {
+		if p.ID == post.ID {
+			posts[i] = post
+			return writeJSON(f.path, posts)
+		}
+	}
+	return writeJSON(f.path, append(posts, post))
+}
+
+func (f *fileScheduleStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	posts, err := f.load()
+	if err != nil {
+		return err
+	}
+	for i, p := range posts {
+		if p.ID == id {
+			return writeJSON(f.path, append(posts[:i], posts[i+1:]...))
+		}
+	}
+	return nil
+}
+
+func (f *fileScheduleStore) load() ([]*ScheduledPost, error) {
+	b, err := os.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var posts []*ScheduledPost
+	if err := json.Unmarshal(b, &posts); err != nil {
+		return nil, err
+	}
+	return posts, nil
+}
@@ -0,0 +1,197 @@
+package goinsta
+
+import (
+	"net/http"
+	"sort"
+)
+
+// MediaVersion is a single quality version of a photo or video.
+type MediaVersion struct {
+	insta *Instagram
+
+	Width  int
+	Height int
+	URL    string
+}
+
+// MediaSelector picks the version to use out of the available versions of a
+// photo or video. An empty MediaVersion can be returned if none match.
+//
+// A MediaSelector can be set for all downloads with
+// Instagram.SetMediaSelector, or be passed to the download methods of
+// Item, User and Broadcast per call.
+type MediaSelector func(versions []MediaVersion) MediaVersion
+
+// SelectBest picks the version with the highest resolution. This is the
+// default selector.
+//
+// Versions are compared by area, so a wider but shorter version can be picked.
+// This differs from GetBest, which only prefers a version if both its width
+// and height are larger.
+func SelectBest(versions []MediaVersion) MediaVersion {
+	var best MediaVersion
+	for _, v := range versions {
+		if v.URL != "" && (best.URL == "" || v.area() > best.area()) {
+			best = v
+		}
+	}
+	return best
+}
+
+// SelectSmallest picks the version with the lowest resolution.
+func SelectSmallest(versions []MediaVersion) MediaVersion {
+	var best MediaVersion
+	for _, v := range versions {
+		if v.URL != "" && (best.URL == "" || v.area() < best.area()) {
+			best = v
+		}
+	}
+	return best
+}
+
+// SelectClosestWidth returns a selector that picks the version of which the
+// width is closest to width. Of two equally close versions, the larger one is
+// used.
+func SelectClosestWidth(width int) MediaSelector {
+	return func(versions []MediaVersion) MediaVersion {
+		var best MediaVersion
+		bestDiff := -1
+		for _, v := range versions {
+			if v.URL == "" {
+				continue
+			}
+			diff := abs(v.Width - width)
+			if bestDiff == -1 || diff < bestDiff || diff == bestDiff && v.Width > best.Width {
+				best, bestDiff = v, diff
+			}
+		}
+		return best
+	}
+}
+
+// SelectSmallestAbove returns a selector that picks the smallest version that
+// is at least width pixels wide. If there is none, the largest version
+// is used.
+func SelectSmallestAbove(width int) MediaSelector {
+	return func(versions []MediaVersion) MediaVersion {
+		var above []MediaVersion
+		for _, v := range versions {
+			if v.URL != "" && v.Width >= width {
+				above = append(above, v)
+			}
+		}
+		if len(above) == 0 {
+			return SelectBest(versions)
+		}
+		return SelectSmallest(above)
+	}
+}
+
+// SelectMaxBytes returns a selector that picks the highest resolution version
+// of at most maxBytes in size. If all versions are too large, the smallest
+// one is used.
+//
+// As the size is not known upfront, a HEAD request is made for every version
+// that is considered, starting with the highest resolution.
+func SelectMaxBytes(maxBytes int64) MediaSelector {
+	return func(versions []MediaVersion) MediaVersion {
+		sorted := make([]MediaVersion, 0, len(versions))
+		for _, v := range versions {
+			if v.URL != "" {
+				sorted = append(sorted, v)
+			}
+		}
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].area() > sorted[j].area()
+		})
+
+		for _, v := range sorted {
+			size, err := v.Size()
+			if err == nil && size >= 0 && size <= maxBytes {
+				return v
+			}
+		}
+		return SelectSmallest(sorted)
+	}
+}
+
+// Size returns the size of the media file in bytes, by making a HEAD
+// request. If the size is unknown, -1 is returned.
+func (v MediaVersion) Size() (int64, error) {
+	c := http.DefaultClient
+	if v.insta != nil {
+		c = v.insta.c
+	}
+	resp, err := c.Head(v.URL)
+	if err != nil {
+		return -1, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return -1, ErrDownloadStatus
+	}
+	return resp.ContentLength, nil
+}
+
+func (v MediaVersion) area() int {
+	return v.Width * v.Height
+}
+
+// SetMediaSelector sets the selector used to pick the quality of all media
+// downloads. Set it to nil to use the default, SelectBest.
+func (insta *Instagram) SetMediaSelector(sel MediaSelector) {
+	insta.mediaSelector = sel
+}
+
+// SelectMedia returns the url of the version selected by sel.
+//
+// Arguments can be []Video, []Candidate, []PicURLInfo or []MediaVersion.
+func SelectMedia(obj interface{}, sel MediaSelector) string {
+	if sel == nil {
+		sel = SelectBest
+	}
+	return sel(mediaVersions(nil, obj)).URL
+}
+
+// selectMedia picks a version with the selector passed, or the selector set
+// on the Instagram instance.
+func (insta *Instagram) selectMedia(obj interface{}, sel []MediaSelector) string {
+	s := SelectBest
+	if len(sel) > 0 && sel[0] != nil {
+		s = sel[0]
+	} else if insta != nil && insta.mediaSelector != nil {
+		s = insta.mediaSelector
+	}
+	return s(mediaVersions(insta, obj)).URL
+}
+
+func mediaVersions(insta *Instagram, obj interface{}) []MediaVersion {
+	var versions []MediaVersion
+	switch t := obj.(type) {
+	case []Video:
+		for _, v := range t {
+			versions = append(versions, MediaVersion{insta: insta, Width: v.Width, Height: v.Height, URL: v.URL})
+		}
+	case []Candidate:
+		for _, c := range t {
+			versions = append(versions, MediaVersion{insta: insta, Width: c.Width, Height: c.Height, URL: c.URL})
+		}
+	case []PicURLInfo:
+		for _, p := range t {
+			versions = append(versions, MediaVersion{insta: insta, Width: p.Width, Height: p.Height, URL: p.URL})
+		}
+	case []MediaVersion:
+		for _, v := range t {
+			v.insta = insta
+			versions = append(versions, v)
+		}
+	}
+	return versions
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
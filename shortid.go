@@ -1,30 +1,55 @@
 package goinsta
 
 import (
-	"fmt"
 	"strconv"
 	"strings"
 )
 
-func leftPad2Len(s string, padStr string, overallLen int) string {
-	padCountInt := 1 + ((overallLen - len(padStr)) / len(padStr))
-	var retStr = strings.Repeat(padStr, padCountInt) + s
-	return retStr[(len(retStr) - overallLen):]
-}
-
 const base64UrlCharmap = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
 
+// MediaIDFromShortID converts the short code of a post, as used in URLs, to
+// its media ID.
 func MediaIDFromShortID(code string) (string, error) {
-	strID := ""
+	if code == "" {
+		return "", ErrInvalidShortID
+	}
+
+	var id uint64
 	for i := 0; i < len(code); i++ {
-		base64 := strings.Index(base64UrlCharmap, string(code[i]))
-		str2bin := strconv.FormatInt(int64(base64), 2)
-		sixbits := leftPad2Len(str2bin, "0", 6)
-		strID = strID + sixbits
+		n := strings.IndexByte(base64UrlCharmap, code[i])
+		if n < 0 {
+			return "", ErrInvalidShortID
+		}
+		// Media IDs fit in a signed 64 bit integer
+		if id > (1<<63-1)>>6 {
+			return "", ErrInvalidShortID
+		}
+		id = id<<6 | uint64(n)
 	}
-	result, err := strconv.ParseInt(strID, 2, 64)
-	if err != nil {
-		return "", err
+	return strconv.FormatUint(id, 10), nil
+}
+
+// ShortIDFromMediaID converts a media ID to the short code used in URLs.
+// The ID can be the media pk, or the <pk>_<user id> format returned by
+// Item.GetID.
+func ShortIDFromMediaID(id string) (string, error) {
+	if i := strings.IndexByte(id, '_'); i >= 0 {
+		id = id[:i]
+	}
+	pk, err := strconv.ParseInt(id, 10, 64)
+	if err != nil || pk < 0 {
+		return "", ErrInvalidShortID
+	}
+
+	var b [11]byte
+	i := len(b)
+	for {
+		i--
+		b[i] = base64UrlCharmap[pk&63]
+		pk >>= 6
+		if pk == 0 {
+			break
+		}
 	}
-	return fmt.Sprintf("%d", result), nil
+	return string(b[i:]), nil
 }
@@ -0,0 +1,315 @@
+package goinsta
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StickerPosition is the placement of a story sticker. X and Y are the center
+// of the sticker, Width and Height its size, all relative to the story from
+// 0 to 1. Rotation is in degrees. If X and Y are both 0 the sticker is
+// centered, if Width and Height are both 0 a default size is used.
+type StickerPosition struct {
+	X        float64
+	Y        float64
+	Width    float64
+	Height   float64
+	Rotation float64
+}
+
+// StorySticker is an interactive sticker that can be added to a story with
+// UploadOptions.Stickers. It is one of MentionSticker, HashtagSticker,
+// LocationSticker, LinkSticker, PollSticker, QuestionSticker, QuizSticker,
+// SliderSticker or CountdownSticker.
+type StorySticker interface {
+	// sticker returns the configure payload key, the sticker ID and the
+	// sticker data
+	sticker() (string, string, map[string]interface{}, error)
+}
+
+// MentionSticker tags a user in a story.
+type MentionSticker struct {
+	StickerPosition
+	User *User
+}
+
+// HashtagSticker adds a hashtag to a story. Tag can be with or without #.
+type HashtagSticker struct {
+	StickerPosition
+	Tag string
+}
+
+// LocationSticker adds a location to a story.
+type LocationSticker struct {
+	StickerPosition
+	Location *Location
+}
+
+// LinkSticker adds a link to a story, Text optionally replaces the URL as
+// sticker text.
+type LinkSticker struct {
+	StickerPosition
+	URL  string
+	Text string
+}
+
+// PollSticker lets viewers vote on one of two options. If no options are
+// provided, Yes and No are used.
+type PollSticker struct {
+	StickerPosition
+	Question string
+	Options  []string
+}
+
+// QuestionSticker lets viewers answer a question. Colors are hex codes,
+// e.g. #ffffff.
+type QuestionSticker struct {
+	StickerPosition
+	Question        string
+	TextColor       string
+	BackgroundColor string
+}
+
+// QuizSticker is a multiple choice question with 2 to 4 options, of which
+// Correct is the index of the correct one.
+type QuizSticker struct {
+	StickerPosition
+	Question  string
+	Options   []string
+	Correct   int
+	TextColor string
+}
+
+// SliderSticker lets viewers rate a question on an emoji slider. Emoji
+// defaults to 😍.
+type SliderSticker struct {
+	StickerPosition
+	Question        string
+	Emoji           string
+	TextColor       string
+	BackgroundColor string
+}
+
+// CountdownSticker counts down to End, viewers can follow it to be notified
+// when it ends.
+type CountdownSticker struct {
+	StickerPosition
+	Text             string
+	End              time.Time
+	DisableFollowing bool
+}
+
+func (p StickerPosition) data(width, height float64, extra map[string]interface{}) map[string]interface{} {
+	if p.X == 0 && p.Y == 0 {
+		p.X, p.Y = 0.5, 0.5
+	}
+	if p.Width == 0 && p.Height == 0 {
+		p.Width, p.Height = width, height
+	}
+	return MergeMapI(
+		map[string]interface{}{
+			"x":        p.X,
+			"y":        p.Y,
+			"z":        0,
+			"width":    p.Width,
+			"height":   p.Height,
+			"rotation": p.Rotation,
+		},
+		extra,
+	)
+}
+
+func (s MentionSticker) sticker() (string, string, map[string]interface{}, error) {
+	if s.User == nil || s.User.ID == 0 {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "mention sticker needs a user")
+	}
+	return "reel_mentions", "mention_sticker", s.data(0.5, 0.1, map[string]interface{}{
+		"type":             "mention",
+		"user_id":          toString(s.User.ID),
+		"display_type":     "mention_username",
+		"is_sticker":       true,
+		"tap_state":        0,
+		"tap_state_str_id": "mention_sticker_gradient",
+	}), nil
+}
+
+func (s HashtagSticker) sticker() (string, string, map[string]interface{}, error) {
+	tag := strings.TrimPrefix(strings.TrimSpace(s.Tag), "#")
+	if tag == "" {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "hashtag sticker needs a tag")
+	}
+	return "story_hashtags", "hashtag_sticker", s.data(0.5, 0.1, map[string]interface{}{
+		"type":             "hashtag",
+		"tag_name":         tag,
+		"is_sticker":       true,
+		"tap_state":        0,
+		"tap_state_str_id": "hashtag_sticker_gradient",
+	}), nil
+}
+
+func (s LocationSticker) sticker() (string, string, map[string]interface{}, error) {
+	if s.Location == nil || s.Location.ID == 0 {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "location sticker needs a location")
+	}
+	return "story_locations", "location_sticker", s.data(0.5, 0.1, map[string]interface{}{
+		"type":             "location",
+		"location_id":      toString(s.Location.ID),
+		"is_sticker":       true,
+		"tap_state":        0,
+		"tap_state_str_id": "location_sticker_vibrant",
+	}), nil
+}
+
+func (s LinkSticker) sticker() (string, string, map[string]interface{}, error) {
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		return "", "", nil, errors.Wrapf(ErrInvalidSticker, "link sticker needs an http(s) url, got %q", s.URL)
+	}
+	data := s.data(0.5, 0.1, map[string]interface{}{
+		"type":             "story_link",
+		"link_type":        "web",
+		"url":              s.URL,
+		"is_sticker":       true,
+		"tap_state":        0,
+		"tap_state_str_id": "link_sticker_default",
+	})
+	if s.Text != "" {
+		data["link_title"] = s.Text
+	}
+	return "story_link_stickers", "link_sticker_default", data, nil
+}
+
+func (s PollSticker) sticker() (string, string, map[string]interface{}, error) {
+	options := s.Options
+	if len(options) == 0 {
+		options = []string{"Yes", "No"}
+	}
+	if len(options) != 2 {
+		return "", "", nil, errors.Wrapf(ErrInvalidSticker, "poll sticker needs 2 options, got %d", len(options))
+	}
+	tallies := []map[string]interface{}{}
+	for _, o := range options {
+		tallies = append(tallies, map[string]interface{}{"text": o, "count": 0, "font_size": 28.0})
+	}
+	return "story_polls", "polling_sticker_vibrant", s.data(0.6, 0.2, map[string]interface{}{
+		"question":         s.Question,
+		"viewer_vote":      0,
+		"viewer_can_vote":  true,
+		"tallies":          tallies,
+		"is_shared_result": false,
+		"finished":         false,
+		"is_sticker":       true,
+	}), nil
+}
+
+func (s QuestionSticker) sticker() (string, string, map[string]interface{}, error) {
+	if s.Question == "" {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "question sticker needs a question")
+	}
+	return "story_questions", "question_sticker_ma_v2", s.data(0.7, 0.25, map[string]interface{}{
+		"question":            s.Question,
+		"question_type":       "text",
+		"viewer_can_interact": true,
+		"text_color":          firstNonEmpty(s.TextColor, "#000000"),
+		"background_color":    firstNonEmpty(s.BackgroundColor, "#ffffff"),
+		"profile_pic_url":     "",
+		"is_sticker":          true,
+	}), nil
+}
+
+func (s QuizSticker) sticker() (string, string, map[string]interface{}, error) {
+	if len(s.Options) < 2 || len(s.Options) > 4 {
+		return "", "", nil, errors.Wrapf(ErrInvalidSticker, "quiz sticker needs 2 to 4 options, got %d", len(s.Options))
+	}
+	if s.Correct < 0 || s.Correct >= len(s.Options) {
+		return "", "", nil, errors.Wrapf(ErrInvalidSticker, "quiz sticker answer %d is not one of the options", s.Correct)
+	}
+	options := []map[string]interface{}{}
+	for _, o := range s.Options {
+		options = append(options, map[string]interface{}{"text": o, "count": 0})
+	}
+	return "story_quizs", "quiz_story_sticker_default", s.data(0.7, 0.3, map[string]interface{}{
+		"question":               s.Question,
+		"options":                options,
+		"correct_answer":         s.Correct,
+		"viewer_can_answer":      true,
+		"finished":               false,
+		"text_color":             firstNonEmpty(s.TextColor, "#ffffff"),
+		"start_background_color": "#262626",
+		"end_background_color":   "#262626",
+		"is_sticker":             true,
+	}), nil
+}
+
+func (s SliderSticker) sticker() (string, string, map[string]interface{}, error) {
+	emoji := firstNonEmpty(s.Emoji, "😍")
+	return "story_sliders", "emoji_slider_" + emoji, s.data(0.7, 0.2, map[string]interface{}{
+		"question":            s.Question,
+		"emoji":               emoji,
+		"text_color":          firstNonEmpty(s.TextColor, "#ffffff"),
+		"background_color":    firstNonEmpty(s.BackgroundColor, "#000000"),
+		"viewer_can_vote":     true,
+		"slider_vote_average": 0,
+		"slider_vote_count":   0,
+		"is_sticker":          true,
+	}), nil
+}
+
+func (s CountdownSticker) sticker() (string, string, map[string]interface{}, error) {
+	if !s.End.After(time.Now()) {
+		return "", "", nil, errors.Wrap(ErrInvalidSticker, "countdown sticker needs to end in the future")
+	}
+	return "story_countdowns", "countdown_sticker_time", s.data(0.7, 0.25, map[string]interface{}{
+		"text":                   s.Text,
+		"end_ts":                 s.End.Unix(),
+		"following_enabled":      !s.DisableFollowing,
+		"text_color":             "#ffffff",
+		"start_background_color": "#ca2ee1",
+		"end_background_color":   "#5eb1ff",
+		"digit_color":            "#7e0091",
+		"digit_card_color":       "#ffffffcc",
+		"is_sticker":             true,
+	}), nil
+}
+
+// addStickers adds UploadOptions.Stickers to a story configure payload. Each
+// type of sticker is a JSON encoded list, and the sticker IDs are listed
+// in story_sticker_ids.
+func (o *UploadOptions) addStickers(config map[string]interface{}) error {
+	if len(o.Stickers) == 0 {
+		return nil
+	}
+
+	stickers := map[string][]map[string]interface{}{}
+	var ids []string
+	seen := map[string]bool{}
+	for _, s := range o.Stickers {
+		if s == nil {
+			continue
+		}
+		key, id, data, err := s.sticker()
+		if err != nil {
+			return err
+		}
+		stickers[key] = append(stickers[key], data)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for key, list := range stickers {
+		b, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+		config[key] = string(b)
+	}
+	config["story_sticker_ids"] = strings.Join(ids, ",")
+	if _, ok := stickers["reel_mentions"]; ok {
+		config["mas_opt_in"] = "NOT_PROMPTED"
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package goinsta
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -147,6 +148,19 @@ func (media *StoryMedia) setValues(insta *Instagram) {
 	}
 }
 
+// URL returns the link to the highlight, or for story reels the link to the
+// stories of the user.
+func (media *Reel) URL() string {
+	id := toString(media.ID)
+	if strings.HasPrefix(id, "highlight:") {
+		return fmt.Sprintf("%sstories/highlights/%s/", instaWebURL, strings.TrimPrefix(id, "highlight:"))
+	}
+	if media.ReelType == "archive_day_reel" {
+		return instaWebURL + "archive/stories/"
+	}
+	return fmt.Sprintf("%sstories/%s/", instaWebURL, media.User.Username)
+}
+
 func (media *Reel) setValues(insta *Instagram) {
 	media.insta = insta
 	media.User.insta = insta
@@ -185,6 +199,73 @@ func (media *StoryMedia) Seen() error {
 }
 */
 
+// StoryArchive is the archive of your own stories, with one Reel per day.
+type StoryArchive struct {
+	insta *Instagram
+	err   error
+
+	Items         []*Reel     `json:"items"`
+	NumResults    int         `json:"num_results"`
+	MoreAvailable bool        `json:"more_available"`
+	NextID        interface{} `json:"max_id"`
+	Status        string      `json:"status"`
+}
+
+// Next fetches the next page of archived days. The days of the new page
+// are stored in StoryArchive.Items, replacing the previous page.
+//
+// Returns false when there are no more pages, or an error occurred.
+func (archive *StoryArchive) Next() bool {
+	if archive.err != nil {
+		return false
+	}
+	insta := archive.insta
+
+	query := map[string]string{
+		"include_suggested_highlights": "false",
+		"is_in_archive_home":           "true",
+		"include_cover":                "0",
+		"timezone_offset":              timeOffset,
+	}
+	if next := toString(archive.NextID); next != "" {
+		query["max_id"] = next
+	}
+
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: urlStoryArchive,
+			Query:    query,
+		},
+	)
+	if err != nil {
+		archive.err = err
+		return false
+	}
+
+	page := StoryArchive{insta: insta}
+	if err := json.Unmarshal(body, &page); err != nil {
+		archive.err = err
+		return false
+	}
+	*archive = page
+
+	for _, reel := range archive.Items {
+		if reel.ReelType == "" {
+			reel.ReelType = "archive_day_reel"
+		}
+		reel.setValues(insta)
+	}
+	if !archive.MoreAvailable || toString(archive.NextID) == "" {
+		archive.err = ErrNoMore
+	}
+	return true
+}
+
+// Error returns the error of the last page fetch, if any.
+func (archive *StoryArchive) Error() error {
+	return archive.err
+}
+
 type trayRequest struct {
 	Name  string `json:"name"`
 	Value string `json:"value"`
@@ -218,12 +299,13 @@ func (insta *Instagram) fetchStories(id int64) (*StoryMedia, error) {
 
 // Sync function is used when Highlights must be sync.
 // Highlight must be sync when User.Highlights does not return any object inside StoryMedia slice.
+// The day reels of Account.StoryArchive also need to be synced to fetch their items.
 //
 // This function does NOT update Stories items.
 //
 // This function updates (fetches) StoryMedia.Items
 func (media *Reel) Sync() error {
-	if media.ReelType != "highlight_reel" {
+	if media.ReelType != "highlight_reel" && media.ReelType != "archive_day_reel" {
 		return ErrNotHighlight
 	}
 
@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// archiveServer serves a profile, a user feed of two pages, and the media of
+// its posts.
+type archiveServer struct {
+	*stubServer
+	// posts on the first page, the second page holds post 1
+	newest []string
+	pages  int
+}
+
+func newArchiveServer(newest ...string) *archiveServer {
+	s := &archiveServer{stubServer: &stubServer{}, newest: newest}
+	s.responses = map[string]string{
+		"users/42/info/": `{"user": {"pk": 42, "username": "tester"}, "status": "ok"}`,
+	}
+	s.handle("cdn.test", func(req *http.Request, _ url.Values) (int, string, error) {
+		return http.StatusOK, "media " + req.URL.Path, nil
+	})
+	s.handle("feed/user/42/", func(_ *http.Request, form url.Values) (int, string, error) {
+		s.pages++
+		if form.Get("max_id") == "" {
+			return http.StatusOK, `{"items": [` + strings.Join(s.newest, ", ") + `], "more_available": true, "next_max_id": "page2", "status": "ok"}`, nil
+		}
+		return http.StatusOK, `{"items": [` + archivePost(1, 100, false) + `], "more_available": false, "status": "ok"}`, nil
+	})
+	return s
+}
+
+func archivePost(pk int, takenAt int64, pinned bool) string {
+	post := map[string]interface{}{
+		"pk":         pk,
+		"id":         fmt.Sprintf("%d_42", pk),
+		"media_type": 1,
+		"taken_at":   takenAt,
+		"user":       map[string]interface{}{"pk": 42, "username": "tester"},
+		"image_versions2": map[string]interface{}{
+			"candidates": []map[string]interface{}{{"url": fmt.Sprintf("https://cdn.test/%d.jpg", pk), "width": 10, "height": 10}},
+		},
+	}
+	if pinned {
+		post["timeline_pinned_user_ids"] = []int{42}
+	}
+	b, _ := json.Marshal(post)
+	return string(b)
+}
+
+func TestArchiveIncremental(t *testing.T) {
+	dir := t.TempDir()
+	srv := newArchiveServer(archivePost(9, 50, true), archivePost(3, 300, false), archivePost(2, 200, false))
+	insta := goinsta.New("", "")
+	insta.SetHTTPTransport(srv)
+	insta.SetWarnHandler(func(...interface{}) {})
+	user := &goinsta.User{ID: 42}
+	opts := &goinsta.ArchiveOptions{SkipStories: true, SkipHighlights: true, SkipIGTV: true, SkipTagged: true}
+
+	// Writing the sidecar of post 2 fails, as a directory is in the way
+	blocked := filepath.Join(dir, goinsta.ArchivePosts, "200_2.json")
+	if err := os.MkdirAll(filepath.Join(blocked, "x"), 0o777); err != nil {
+		t.Fatal(err)
+	}
+	report, err := insta.Archive(user, dir, opts)
+	if err == nil {
+		t.Fatal("expected the failed sidecar to be returned")
+	}
+	if report.Downloaded[goinsta.ArchivePosts] != 3 || report.Failed[goinsta.ArchivePosts] != 1 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	// The state was not advanced, so the next run walks all posts again and
+	// retries the sidecar
+	if err := os.RemoveAll(blocked); err != nil {
+		t.Fatal(err)
+	}
+	srv.mu.Lock()
+	srv.newest = append([]string{archivePost(4, 400, false)}, srv.newest...)
+	srv.pages = 0
+	srv.mu.Unlock()
+	report, err = insta.Archive(user, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Downloaded[goinsta.ArchivePosts] != 1 || report.Skipped[goinsta.ArchivePosts] != 4 || srv.pages != 2 {
+		t.Errorf("unexpected report: %+v, after %d pages", report, srv.pages)
+	}
+	if _, err := os.Stat(filepath.Join(dir, goinsta.ArchivePosts, "200_2.json")); err != nil {
+		t.Errorf("sidecar of post 2 was not retried: %v", err)
+	}
+
+	// Now only the new post is archived, the old pinned post does not stop
+	// the walk, and the walk stops at the newest archived post
+	srv.mu.Lock()
+	srv.newest = []string{archivePost(9, 50, true), archivePost(5, 500, false), archivePost(4, 400, false), archivePost(3, 300, false)}
+	srv.pages = 0
+	srv.mu.Unlock()
+	report, err = insta.Archive(user, dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Downloaded[goinsta.ArchivePosts] != 1 || report.Skipped[goinsta.ArchivePosts] != 1 || srv.pages != 1 {
+		t.Errorf("unexpected report: %+v, after %d pages", report, srv.pages)
+	}
+	for _, name := range []string{"500_5.jpg", "500_5.json", "50_9.json"} {
+		if _, err := os.Stat(filepath.Join(dir, goinsta.ArchivePosts, name)); err != nil {
+			t.Errorf("%s was not archived: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+const testManifest = `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" mediaPresentationDuration="PT1M2.5S" type="static">
+ <Period id="0" duration="PT1M2.5S">
+  <AdaptationSet id="0" contentType="video" segmentAlignment="true">
+   <Representation id="v1" bandwidth="400000" codecs="avc1.4d401e" mimeType="video/mp4" width="480" height="852" frameRate="30" FBQualityLabel="480p">
+    <BaseURL>%[1]s/v1.mp4?a=1&amp;b=2</BaseURL>
+    <SegmentBase indexRange="100-200"><Initialization range="0-99"/></SegmentBase>
+   </Representation>
+   <Representation id="v2" bandwidth="1200000" codecs="avc1.4d401f" mimeType="video/mp4" width="720" height="1280" frameRate="30" FBQualityLabel="720p">
+    <BaseURL>%[1]s/v2.mp4</BaseURL>
+   </Representation>
+  </AdaptationSet>
+  <AdaptationSet id="1" contentType="audio">
+   <Representation id="a1" bandwidth="64000" codecs="mp4a.40.5" mimeType="audio/mp4" audioSamplingRate="44100">
+    <BaseURL>%[1]s/a1.mp4</BaseURL>
+   </Representation>
+  </AdaptationSet>
+ </Period>
+</MPD>`
+
+func TestDashManifest(t *testing.T) {
+	m, err := goinsta.ParseDashManifest(fmt.Sprintf(testManifest, "https://example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Duration != 62500*time.Millisecond {
+		t.Fatalf("Wrong duration: %s", m.Duration)
+	}
+	if len(m.Video) != 2 || len(m.Audio) != 1 {
+		t.Fatalf("Expected 2 video and 1 audio representations, got %d and %d", len(m.Video), len(m.Audio))
+	}
+
+	best := m.BestVideo(0)
+	if best.ID != "v2" || best.Width != 720 || best.Height != 1280 || best.QualityLabel != "720p" {
+		t.Fatalf("Wrong best video: %+v", best)
+	}
+	if capped := m.BestVideo(500000); capped.ID != "v1" {
+		t.Fatalf("Expected v1 within the bandwidth cap, got %s", capped.ID)
+	}
+	if lowest := m.BestVideo(1); lowest.ID != "v1" {
+		t.Fatalf("Expected the lowest quality when all exceed the cap, got %s", lowest.ID)
+	}
+	if u := m.Video[1].Segments[0]; u != "https://example.com/v1.mp4?a=1&b=2" {
+		t.Fatalf("Wrong url: %s", u)
+	}
+	if a := m.BestAudio(0); a.Codecs != "mp4a.40.5" || a.SampleRate != 44100 {
+		t.Fatalf("Wrong audio: %+v", a)
+	}
+}
+
+func TestDashMux(t *testing.T) {
+	files := map[string][]byte{
+		"/v2.mp4": fragmentedMP4(90000, 3000),
+		"/a1.mp4": fragmentedMP4(44100, 882),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	m, err := goinsta.ParseDashManifest(fmt.Sprintf(testManifest, srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insta := goinsta.New("", "")
+	dst := filepath.Join(t.TempDir(), "out.mp4")
+	if err := insta.DownloadDash(dst, m.BestVideo(0), m.BestAudio(0)); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var types []string
+	var trackIDs []uint32
+	var seq []uint32
+	walk(t, b, func(typ string, data []byte) {
+		switch typ {
+		case "ftyp", "moov", "moof", "mdat":
+			types = append(types, typ)
+		case "tkhd":
+			trackIDs = append(trackIDs, binary.BigEndian.Uint32(data[12:]))
+		case "mfhd":
+			seq = append(seq, binary.BigEndian.Uint32(data[4:]))
+		case "tfhd":
+			trackIDs = append(trackIDs, binary.BigEndian.Uint32(data[4:]))
+		}
+	})
+
+	if fmt.Sprint(types) != "[ftyp moov moof mdat moof mdat moof mdat moof mdat]" {
+		t.Fatalf("Unexpected box layout: %v", types)
+	}
+	// Two tkhd boxes, followed by the fragments interleaved by decode time
+	if fmt.Sprint(trackIDs) != "[1 2 1 2 2 1]" {
+		t.Fatalf("Unexpected track ids: %v", trackIDs)
+	}
+	if fmt.Sprint(seq) != "[1 2 3 4]" {
+		t.Fatalf("Unexpected sequence numbers: %v", seq)
+	}
+
+	// A missing stream should fail, without leaving a file behind
+	m.Video[0].Segments = []string{srv.URL + "/missing.mp4"}
+	dst = filepath.Join(t.TempDir(), "missing.mp4")
+	if err := insta.DownloadDash(dst, m.BestVideo(0), nil); err == nil {
+		t.Fatal("Expected an error for a missing stream")
+	}
+	if _, err := os.Stat(dst); !os.IsNotExist(err) {
+		t.Fatal("Partial file was left behind")
+	}
+}
+
+func box(typ string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	b := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint32(b, uint32(8+len(body)))
+	copy(b[4:], typ)
+	return append(b, body...)
+}
+
+func u32(v ...uint32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, n := range v {
+		binary.BigEndian.PutUint32(b[4*i:], n)
+	}
+	return b
+}
+
+// fragmentedMP4 builds a minimal single track fragmented MP4 with two
+// fragments.
+func fragmentedMP4(timescale, duration uint32) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], 1000)
+	binary.BigEndian.PutUint32(mvhd[96:], 2)
+	tkhd := make([]byte, 84)
+	binary.BigEndian.PutUint32(tkhd[12:], 7)
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], timescale)
+
+	out := box("ftyp", []byte("iso5"), u32(512), []byte("iso5dash"))
+	out = append(out, box("moov",
+		box("mvhd", mvhd),
+		box("trak", box("tkhd", tkhd), box("mdia", box("mdhd", mdhd))),
+		box("mvex", box("trex", u32(0, 7, 1, 0, 0, 0))),
+	)...)
+	out = append(out, box("sidx", u32(0, 7))...)
+
+	for i := uint32(0); i < 2; i++ {
+		data := []byte(fmt.Sprintf("sample %d", i))
+		traf := box("traf",
+			box("tfhd", u32(0x020000, 7)),
+			box("tfdt", u32(0, i*duration)),
+			// trun with data offset, patched below
+			box("trun", u32(0x000001, 1, 0)),
+		)
+		moof := box("moof", box("mfhd", u32(0, i+10)), traf)
+		// data offset is relative to the moof, pointing past the mdat header
+		binary.BigEndian.PutUint32(moof[len(moof)-4:], uint32(len(moof)+8))
+		out = append(out, moof...)
+		out = append(out, box("mdat", data)...)
+	}
+	return out
+}
+
+var containers = map[string]bool{"moov": true, "trak": true, "mdia": true, "mvex": true, "moof": true, "traf": true}
+
+func walk(t *testing.T, b []byte, fn func(typ string, data []byte)) {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			t.Fatal("Truncated box")
+		}
+		size := binary.BigEndian.Uint32(b)
+		if size < 8 || int(size) > len(b) {
+			t.Fatalf("Invalid box size %d", size)
+		}
+		typ, data := string(b[4:8]), b[8:size]
+		fn(typ, data)
+		if containers[typ] {
+			walk(t, data, fn)
+		}
+		b = b[size:]
+	}
+}
@@ -0,0 +1,318 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// directServer answers inbox and thread requests on an upload server.
+type directServer struct {
+	*ruploadServer
+	// thread is returned by thread updates if set, and when creating groups
+	thread string
+	sent   int
+}
+
+func (s *directServer) threads(req *http.Request, form url.Values) (int, string, error) {
+	switch {
+	case strings.Contains(req.URL.Path, "create_group_thread"):
+		return http.StatusOK, s.thread, nil
+	case !strings.Contains(req.URL.Path, "/broadcast/"):
+		if s.thread != "" {
+			return http.StatusOK, `{"status": "ok", "thread": ` + s.thread + `}`, nil
+		}
+		return http.StatusOK, `{"status": "ok"}`, nil
+	}
+	s.sent++
+	thread := strings.Trim(form.Get("thread_ids"), `[]"`)
+	return http.StatusOK, `{"action": "item_ack", "status": "ok", "payload": {"client_context": "` + form.Get("client_context") +
+		`", "item_id": "item` + strconv.Itoa(s.sent) + `", "thread_id": "` + thread + `", "timestamp": "` + strconv.Itoa(1666000000000000+s.sent) + `"}}`, nil
+}
+
+func directInbox(t *testing.T) (*goinsta.Inbox, *directServer) {
+	srv := &directServer{ruploadServer: newRuploadServer(-1)}
+	srv.responses = map[string]string{
+		"direct_v2/inbox/": `{"inbox": {"threads": [` +
+			`{"thread_id": "340", "thread_type": "private", "users": [{"pk": 2, "username": "friend"}]}, ` +
+			`{"thread_id": "341", "thread_type": "private", "users": [{"pk": 3, "username": "other"}]}` +
+			`]}, "status": "ok"}`,
+	}
+	srv.handle("direct_v2/threads/", srv.threads)
+	srv.handle("direct_v2/create_group_thread/", srv.threads)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	if err := insta.Inbox.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if len(insta.Inbox.Conversations) != 2 {
+		t.Fatalf("expected 2 conversations, got %d", len(insta.Inbox.Conversations))
+	}
+	return insta.Inbox, srv
+}
+
+func thread(t *testing.T, inbox *goinsta.Inbox, id string) *goinsta.Conversation {
+	for _, c := range inbox.Conversations {
+		if c.ID == id {
+			return c
+		}
+	}
+	t.Fatalf("thread %s not found", id)
+	return nil
+}
+
+func TestDirectSend(t *testing.T) {
+	inbox, srv := directInbox(t)
+	conv := thread(t, inbox, "340")
+
+	// An audio only file, by turning the video track into a text track
+	voice := testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", audio: "mp4a", audioType: 0x40, duration: 3000, size: 2000}.bytes()
+	voice = bytes.Replace(voice, []byte("vide"), []byte("text"), 1)
+
+	tests := []struct {
+		name     string
+		send     func() (*goinsta.InboxItem, error)
+		path     string
+		itemType string
+		params   map[string]string
+	}{
+		{
+			"photo",
+			func() (*goinsta.InboxItem, error) { return conv.SendPhoto(jpegReader(t, 1080, 1080)) },
+			"/api/v1/direct_v2/threads/broadcast/configure_photo/",
+			"media",
+			map[string]string{"allow_full_aspect_ratio": "true"},
+		},
+		{
+			"video",
+			func() (*goinsta.InboxItem, error) { return conv.SendVideo(bytes.NewReader(fakeVideo(3000))) },
+			"/api/v1/direct_v2/threads/broadcast/configure_video/",
+			"media",
+			map[string]string{"sampled": "true"},
+		},
+		{
+			"voice",
+			func() (*goinsta.InboxItem, error) {
+				srv.received = nil // a new file upload
+				return conv.SendVoice(bytes.NewReader(voice))
+			},
+			"/api/v1/direct_v2/threads/broadcast/share_voice/",
+			"voice_media",
+			map[string]string{"waveform_sampling_frequency_hz": "10"},
+		},
+		{
+			"link",
+			func() (*goinsta.InboxItem, error) { return conv.SendLink("look at https://example.com/page") },
+			"/api/v1/direct_v2/threads/broadcast/link/",
+			"link",
+			map[string]string{"link_text": "look at https://example.com/page", "link_urls": `["https://example.com/page"]`},
+		},
+		{
+			"media",
+			func() (*goinsta.InboxItem, error) {
+				return conv.ShareMedia(&goinsta.Item{ID: "42_1", MediaType: 2, User: goinsta.User{ID: 1}})
+			},
+			"/api/v1/direct_v2/threads/broadcast/media_share/?media_type=video",
+			"media_share",
+			map[string]string{"media_id": "42_1"},
+		},
+		{
+			"profile",
+			func() (*goinsta.InboxItem, error) { return conv.ShareProfile(&goinsta.User{ID: 7}) },
+			"/api/v1/direct_v2/threads/broadcast/profile/",
+			"profile",
+			map[string]string{"profile_user_id": "7"},
+		},
+		{
+			"hashtag",
+			func() (*goinsta.InboxItem, error) { return conv.ShareHashtag(&goinsta.Hashtag{Name: "golang"}) },
+			"/api/v1/direct_v2/threads/broadcast/hashtag/",
+			"hashtag",
+			map[string]string{"hashtag": "golang"},
+		},
+		{
+			"location",
+			func() (*goinsta.InboxItem, error) { return conv.ShareLocation(&goinsta.Location{ID: 99}) },
+			"/api/v1/direct_v2/threads/broadcast/location/",
+			"location",
+			map[string]string{"venue_id": "99"},
+		},
+		{
+			"like",
+			func() (*goinsta.InboxItem, error) { return conv.SendLike() },
+			"/api/v1/direct_v2/threads/broadcast/like/",
+			"like",
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item, err := test.send()
+			if err != nil {
+				t.Fatal(err)
+			}
+			paths, forms := srv.requests("direct_v2/threads/")
+			if len(forms) != i+1 {
+				t.Fatalf("expected %d broadcasts, got %d", i+1, len(forms))
+			}
+			if path := paths[i]; path != test.path {
+				t.Errorf("expected request to %s, got %s", test.path, path)
+			}
+			form := forms[i]
+			if form.Get("thread_ids") != `["340"]` || form.Get("recipient_users") != "[[2]]" {
+				t.Errorf("message not addressed to the thread: %v", form)
+			}
+			for k, v := range test.params {
+				if form.Get(k) != v {
+					t.Errorf("expected %s=%s, got %q", k, v, form.Get(k))
+				}
+			}
+			if item.Type != test.itemType || item.ID != "item"+strconv.Itoa(i+1) || item.UserID != 1 {
+				t.Errorf("unexpected item: %+v", item)
+			}
+			if conv.Items[0] != item {
+				t.Error("item was not added to the conversation")
+			}
+		})
+	}
+
+	_, forms := srv.requests("direct_v2/threads/")
+	if err := json.Unmarshal([]byte(forms[2].Get("waveform")), &[]float64{}); err != nil {
+		t.Errorf("invalid waveform: %v", err)
+	}
+	if forms[0].Get("upload_id") == "" || forms[1].Get("upload_id") == "" {
+		t.Error("media messages have no upload id")
+	}
+}
+
+func TestDirectSendErrors(t *testing.T) {
+	inbox, _ := directInbox(t)
+	conv := thread(t, inbox, "340")
+
+	if _, err := conv.SendLink("no link here"); err != goinsta.ErrNoLink {
+		t.Errorf("expected ErrNoLink, got %v", err)
+	}
+	if _, err := conv.SendPhoto(bytes.NewReader(fakeVideo(3000))); err != goinsta.ErrInvalidImage {
+		t.Errorf("expected ErrInvalidImage, got %v", err)
+	}
+	if _, err := conv.SendVideo(jpegReader(t, 100, 100)); err != goinsta.ErrInvalidFormat {
+		t.Errorf("expected ErrInvalidFormat, got %v", err)
+	}
+	noAudio := testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", duration: 3000, size: 2000}.bytes()
+	noAudio = bytes.Replace(noAudio, []byte("vide"), []byte("text"), 1)
+	if _, err := conv.SendVoice(bytes.NewReader(noAudio)); err != goinsta.ErrNoAudioTrack {
+		t.Errorf("expected ErrNoAudioTrack, got %v", err)
+	}
+}
+
+func TestDirectReactions(t *testing.T) {
+	inbox, srv := directInbox(t)
+	conv := thread(t, inbox, "340")
+	last := srv.last
+
+	msg, err := conv.SendLike()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Quoted reply
+	reply, err := conv.Reply(msg, "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, form := last(); form.Get("replied_to_item_id") != msg.ID || form.Get("replied_to_client_context") != msg.ClientContext {
+		t.Errorf("reply does not quote the message: %v", form)
+	}
+	if reply.RepliedTo != msg || reply.Text != "hi" {
+		t.Errorf("unexpected reply: %+v", reply)
+	}
+
+	// Reactions
+	if err := conv.React(msg, "😂"); err != nil {
+		t.Fatal(err)
+	}
+	path, form := last()
+	if path != "/api/v1/direct_v2/threads/broadcast/reaction/" || form.Get("emoji") != "😂" ||
+		form.Get("reaction_status") != "created" || form.Get("item_id") != msg.ID {
+		t.Errorf("unexpected reaction request to %s: %v", path, form)
+	}
+	if err := conv.React(msg, ""); err != nil {
+		t.Fatal(err)
+	}
+	if r := msg.Reactions.Emojis; len(r) != 1 || r[0].Emoji != "❤️" || r[0].SenderID != 1 {
+		t.Errorf("expected a single heart, got %+v", r)
+	}
+	if err := conv.Unreact(msg); err != nil {
+		t.Fatal(err)
+	}
+	if _, form := last(); form.Get("reaction_status") != "deleted" {
+		t.Errorf("expected reaction to be deleted: %v", form)
+	}
+	if len(msg.Reactions.Emojis) != 0 {
+		t.Errorf("expected no reactions, got %+v", msg.Reactions.Emojis)
+	}
+
+	// Forwarding
+	other := thread(t, inbox, "341")
+	fwd, err := conv.Forward(msg, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, form = last()
+	if path != "/api/v1/direct_v2/threads/broadcast/forward/" || form.Get("thread_ids") != `["341"]` ||
+		form.Get("forwarded_from_thread_id") != "340" || form.Get("forwarded_from_thread_item_id") != msg.ID {
+		t.Errorf("unexpected forward request to %s: %v", path, form)
+	}
+	if fwd.Type != "like" || fwd.ID == msg.ID || other.Items[0] != fwd || conv.Items[0] == fwd {
+		t.Errorf("unexpected forwarded item: %+v", fwd)
+	}
+
+	// Unsend
+	if err := conv.Unsend(&goinsta.InboxItem{ID: "theirs", UserID: 2}); err != goinsta.ErrNotOwnItem {
+		t.Errorf("expected ErrNotOwnItem, got %v", err)
+	}
+	if err := conv.Unsend(msg); err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := last(); path != "/api/v1/direct_v2/threads/340/items/"+msg.ID+"/delete/" {
+		t.Errorf("unexpected unsend request to %s", path)
+	}
+	for _, item := range conv.Items {
+		if item.ID == msg.ID {
+			t.Error("unsent message is still in the conversation")
+		}
+	}
+}
+
+func TestInboxItemReactions(t *testing.T) {
+	var item goinsta.InboxItem
+	err := json.Unmarshal([]byte(`{
+		"item_id": "1",
+		"item_type": "text",
+		"text": "yes",
+		"reactions": {
+			"likes": [{"sender_id": 2, "timestamp": 1666000000000000, "client_context": "a"}],
+			"likes_count": 1,
+			"emojis": [{"sender_id": 3, "timestamp": 1666000000000001, "client_context": "b", "emoji": "🔥"}]
+		},
+		"replied_to_message": {"item_id": "0", "item_type": "text", "text": "really?"}
+	}`), &item)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := item.Reactions; r == nil || r.LikesCount != 1 || r.Likes[0].SenderID != 2 || r.Emojis[0].Emoji != "🔥" {
+		t.Errorf("unexpected reactions: %+v", item.Reactions)
+	}
+	if item.RepliedTo == nil || item.RepliedTo.Text != "really?" {
+		t.Errorf("unexpected replied to message: %+v", item.RepliedTo)
+	}
+}
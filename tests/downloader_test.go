@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestDownloaderManifest(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write([]byte("media bytes of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	photo := func(pk int64, name string) goinsta.Item {
+		return goinsta.Item{
+			Pk:        pk,
+			ID:        fmt.Sprintf("%d_42", pk),
+			MediaType: 1,
+			TakenAt:   1650000000,
+			User:      goinsta.User{ID: 42, Username: "tester"},
+			Images: goinsta.Images{
+				Versions: []goinsta.Candidate{
+					{Width: 150, Height: 150, URL: srv.URL + "/small/" + name},
+					{Width: 1080, Height: 1080, URL: srv.URL + "/large/" + name},
+				},
+			},
+		}
+	}
+
+	single := photo(1, "a.jpg")
+	carousel := goinsta.Item{
+		Pk:            2,
+		ID:            "2_42",
+		MediaType:     8,
+		TakenAt:       1650000001,
+		User:          goinsta.User{ID: 42, Username: "tester"},
+		CarouselMedia: []goinsta.Item{photo(3, "b.jpg"), photo(4, "c.jpg")},
+	}
+
+	dir := t.TempDir()
+	insta := goinsta.New("", "")
+	insta.SetWarnHandler(t.Log)
+
+	d, err := insta.NewDownloader(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Duplicate items should only be downloaded once
+	d.Add(&single, &carousel, &single)
+	if err := d.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&hits) != 3 {
+		t.Fatalf("Expected 3 downloads, got %d", hits)
+	}
+
+	for _, f := range []string{
+		"tester/1650000000_1.jpg",
+		"tester/1650000001_2_1.jpg",
+		"tester/1650000001_2_2.jpg",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, f))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) == 0 {
+			t.Fatalf("File %s is empty", f)
+		}
+	}
+
+	// A second run should skip everything recorded in the manifest
+	d, err = insta.NewDownloader(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := int32(0)
+	d.OnDone = func(item *goinsta.Item, files []string, skip bool, err error) {
+		if skip {
+			atomic.AddInt32(&skipped, 1)
+		}
+	}
+	d.Add(&single, &carousel)
+	if err := d.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if atomic.LoadInt32(&hits) != 3 || atomic.LoadInt32(&skipped) != 2 {
+		t.Fatalf("Expected all items to be skipped, got %d downloads and %d skipped", hits, skipped)
+	}
+}
+
+func TestDownloaderClosed(t *testing.T) {
+	insta := goinsta.New("", "")
+	d, err := insta.NewDownloader(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Wait(); err != nil {
+		t.Errorf("expected a second Wait to succeed, got %v", err)
+	}
+	if err := d.Add(&goinsta.Item{ID: "1_42"}); err != goinsta.ErrDownloaderClosed {
+		t.Errorf("expected ErrDownloaderClosed, got %v", err)
+	}
+	if err := d.AddFeed([]*goinsta.Item{{ID: "1_42"}}); err != goinsta.ErrDownloaderClosed {
+		t.Errorf("expected ErrDownloaderClosed, got %v", err)
+	}
+}
+
+func TestDownloaderFeedError(t *testing.T) {
+	insta := realtimeInsta(t, "")
+	insta.SetHTTPTransport(&flakyTransport{fails: 1, next: http.DefaultTransport})
+
+	d, err := insta.NewDownloader(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddFeed(insta.Account.Feed()); err == nil {
+		t.Error("expected the failed request to be returned")
+	}
+	if err := d.Wait(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloaderNameCollision(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("media bytes of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	var items []*goinsta.Item
+	for pk := int64(1); pk <= 4; pk++ {
+		items = append(items, &goinsta.Item{
+			Pk:        pk,
+			ID:        fmt.Sprintf("%d_42", pk),
+			MediaType: 1,
+			User:      goinsta.User{ID: 42, Username: "tester"},
+			Images:    goinsta.Images{Versions: []goinsta.Candidate{{Width: 10, Height: 10, URL: fmt.Sprintf("%s/%d.jpg", srv.URL, pk)}}},
+		})
+	}
+
+	dir := t.TempDir()
+	d, err := goinsta.New("", "").NewDownloader(dir, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.NameTemplate = "{username}"
+	// OnDone is not called concurrently, so it can append without locking
+	var done []error
+	d.OnDone = func(item *goinsta.Item, files []string, skipped bool, err error) {
+		done = append(done, err)
+	}
+	d.Add(items...)
+	if err := d.Wait(); !errors.Is(err, goinsta.ErrDownloadNameCollision) {
+		t.Errorf("expected ErrDownloadNameCollision, got %v", err)
+	}
+
+	collisions := 0
+	for _, err := range done {
+		if errors.Is(err, goinsta.ErrDownloadNameCollision) {
+			collisions++
+		}
+	}
+	if len(done) != 4 || collisions != 3 {
+		t.Errorf("expected one download and 3 collisions, got %v", done)
+	}
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// stubServer answers requests with the response of the first path suffix
+// that matches, and records the requests made. Tests that need more than a
+// fixed response register a handler instead.
+type stubServer struct {
+	mu        sync.Mutex
+	responses map[string]string
+	handlers  map[string]stubHandler
+	paths     []string
+	forms     []url.Values
+}
+
+// stubHandler returns the status code and body of the response to a
+// request, or an error to fail the request with. The form holds the form
+// encoded body of the request, or its query, other bodies are left in
+// req.Body.
+// Handlers are called with the server locked.
+type stubHandler func(req *http.Request, form url.Values) (int, string, error)
+
+// handle registers h for the requests with a host and path that contain
+// key. If several keys match, the longest one is used.
+func (s *stubServer) handle(key string, h stubHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handlers == nil {
+		s.handlers = make(map[string]stubHandler)
+	}
+	s.handlers[key] = h
+}
+
+func (s *stubServer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	form := req.URL.Query()
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 && strings.HasPrefix(req.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+			if form, err = url.ParseQuery(string(b)); err != nil {
+				return nil, err
+			}
+		}
+		req.Body = io.NopCloser(bytes.NewReader(b))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paths = append(s.paths, req.URL.RequestURI())
+	s.forms = append(s.forms, form)
+
+	status, body := http.StatusOK, `{"status": "ok"}`
+	var handler string
+	for key := range s.handlers {
+		if len(key) > len(handler) && strings.Contains(req.URL.Host+req.URL.Path, key) {
+			handler = key
+		}
+	}
+	if handler != "" {
+		var err error
+		if status, body, err = s.handlers[handler](req, form); err != nil {
+			return nil, err
+		}
+	} else {
+		for suffix, resp := range s.responses {
+			if strings.HasSuffix(req.URL.Path, suffix) {
+				body = resp
+				break
+			}
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// last returns the request URI and form of the last request.
+func (s *stubServer) last() (string, url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.paths)
+	return s.paths[n-1], s.forms[n-1]
+}
+
+func (s *stubServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.paths)
+}
+
+// requests returns the request URIs and forms of the requests made to paths
+// containing key.
+func (s *stubServer) requests(key string) ([]string, []url.Values) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	var forms []url.Values
+	for i, path := range s.paths {
+		if strings.Contains(path, key) {
+			paths = append(paths, path)
+			forms = append(forms, s.forms[i])
+		}
+	}
+	return paths, forms
+}
+
+// signedBody decodes the signed body of a request.
+func signedBody(t *testing.T, form url.Values) map[string]interface{} {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(form.Get("signed_body"), "SIGNATURE.")), &body); err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+// stubInsta returns a logged in session, that sends its requests to the stub
+// server.
+func stubInsta(t *testing.T, srv *stubServer) *goinsta.Instagram {
+	insta := realtimeInsta(t, "")
+	insta.SetHTTPTransport(srv)
+	// Imported sessions refresh the xmid token before the first request
+	srv.responses["zr/token/result/"] = `{"token": {"ttl": 100000000000, "request_time": 0}, "status": "ok"}`
+	return insta
+}
+
+// stubFeed returns the items of a feed page served by the stub server.
+func stubFeed(t *testing.T, insta *goinsta.Instagram, srv *stubServer, items string) []*goinsta.Item {
+	srv.responses["feed/user/1/"] = `{"items": [` + items + `], "more_available": false, "status": "ok"}`
+
+	feed := insta.Account.Feed()
+	if !feed.Next() {
+		t.Fatal(feed.Error())
+	}
+	return feed.Items
+}
+
+func TestItemEdit(t *testing.T) {
+	srv := &stubServer{responses: map[string]string{
+		"edit_media/": `{"media": {"pk": 10, "id": "10_1", "caption": {"text": "edited"}}, "status": "ok"}`,
+	}}
+	items := stubFeed(t, stubInsta(t, srv), srv, `
+		{"pk": 10, "id": "10_1", "media_type": 1, "caption": {"text": "old"}, "usertags": {"in": [{"user": {"pk": 5}}, {"user": {"pk": 6}}]}},
+		{"pk": 20, "id": "20_1", "media_type": 8, "carousel_media": [
+			{"pk": 21, "id": "21_1", "media_type": 1, "usertags": {"in": [{"user": {"pk": 7}}]}},
+			{"pk": 22, "id": "22_1", "media_type": 1}
+		]}`)
+	photo, album := items[0], items[1]
+
+	// Single photo: user 6 is untagged, and the location removed
+	caption := "edited"
+	_, err := photo.Edit(goinsta.EditOptions{
+		Caption:        &caption,
+		UserTags:       &[]goinsta.UserTag{{User: &goinsta.User{ID: 5}, Position: [2]float64{0.5, 0.5}}},
+		RemoveLocation: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, form := srv.last()
+	body := signedBody(t, form)
+	if !strings.HasSuffix(path, "media/10_1/edit_media/") || body["caption_text"] != "edited" || body["location"] != "{}" {
+		t.Errorf("unexpected edit request to %s: %v", path, body)
+	}
+	var tags struct {
+		In []struct {
+			UserID int64 `json:"user_id"`
+		} `json:"in"`
+		Removed []string `json:"removed"`
+	}
+	if err := json.Unmarshal([]byte(body["usertags"].(string)), &tags); err != nil {
+		t.Fatal(err)
+	}
+	if len(tags.In) != 1 || tags.In[0].UserID != 5 || len(tags.Removed) != 1 || tags.Removed[0] != "6" {
+		t.Errorf("unexpected usertags: %s", body["usertags"])
+	}
+	if photo.Caption.Text != "edited" {
+		t.Errorf("item was not updated: %q", photo.Caption.Text)
+	}
+
+	// Album: the first item is left unchanged, the second one is tagged
+	_, err = album.Edit(goinsta.EditOptions{
+		AlbumTags: []*[]goinsta.UserTag{nil, {{User: &goinsta.User{ID: 8}}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, form = srv.last()
+	body = signedBody(t, form)
+	if _, ok := body["location"]; ok {
+		t.Errorf("location should be left unchanged: %v", body["location"])
+	}
+	var children []map[string]string
+	if err := json.Unmarshal([]byte(body["children_metadata"].(string)), &children); err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 || children[0]["media_id"] != "21_1" || children[0]["usertags"] != "" {
+		t.Errorf("unexpected metadata of the first item: %v", children)
+	}
+	if len(children) == 2 && (children[1]["media_id"] != "22_1" || !strings.Contains(children[1]["usertags"], `"user_id":8`)) {
+		t.Errorf("unexpected metadata of the second item: %v", children[1])
+	}
+
+	_, err = album.Edit(goinsta.EditOptions{AlbumTags: make([]*[]goinsta.UserTag, 3)})
+	if err != goinsta.ErrEditAlbumIndex {
+		t.Errorf("expected ErrEditAlbumIndex, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// exportServer serves a conversation in two pages, and the media attached
+// to its messages.
+type exportServer struct {
+	*stubServer
+	newest string
+	older  int
+	media  int
+}
+
+func newExportServer(newest string) *exportServer {
+	s := &exportServer{stubServer: &stubServer{}, newest: newest}
+	s.responses = map[string]string{
+		"direct_v2/inbox/": `{"inbox": {"threads": [{"thread_id": "340", "thread_title": "friend", "users": [{"pk": 2, "username": "friend"}]}]}, "status": "ok"}`,
+	}
+	s.handle("cdn.test", func(req *http.Request, _ url.Values) (int, string, error) {
+		s.media++
+		return http.StatusOK, "media " + req.URL.Path, nil
+	})
+	s.handle("direct_v2/threads/340/", func(_ *http.Request, form url.Values) (int, string, error) {
+		thread := `{"thread_id": "340", "thread_title": "friend", "users": [{"pk": 2, "username": "friend"}], "has_older": true, "items": [` + s.newest + `]}`
+		if form.Get("cursor") == "2" {
+			s.older++
+			thread = `{"thread_id": "340", "thread_title": "friend", "users": [{"pk": 2, "username": "friend"}], "has_older": false, "items": [` +
+				`{"item_id": "1", "item_type": "text", "user_id": 2, "timestamp": 100, "text": "a <b> tag"}]}`
+		}
+		return http.StatusOK, `{"thread": ` + thread + `, "status": "ok"}`, nil
+	})
+	return s
+}
+
+func exportConversation(t *testing.T, srv *exportServer) *goinsta.Conversation {
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1, Username: "me"}
+	insta.SetHTTPTransport(srv)
+	if err := insta.Inbox.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	return thread(t, insta.Inbox, "340")
+}
+
+func TestConversationExport(t *testing.T) {
+	dir := t.TempDir()
+	srv := newExportServer(`` +
+		`{"item_id": "3", "item_type": "media", "user_id": 1, "timestamp": 300, "media": {"media_type": 1, "image_versions2": {"candidates": [{"url": "https://cdn.test/p.jpg?x=1", "width": 10, "height": 10}]}}}, ` +
+		`{"item_id": "2", "item_type": "voice_media", "user_id": 2, "timestamp": 200, "voice_media": {"media": {"audio": {"audio_src": "https://cdn.test/v.m4a"}}}}`,
+	)
+	conv := exportConversation(t, srv)
+
+	// A full export pages through all history
+	var buf bytes.Buffer
+	report, err := conv.Export(&buf, goinsta.ExportJSON, &goinsta.ExportOptions{Dir: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Messages != 3 || report.Media != 2 || report.Failed != 0 || report.Last != 300 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+
+	var messages []goinsta.ExportedMessage
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var m goinsta.ExportedMessage
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, m)
+	}
+	if len(messages) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(messages))
+	}
+	if m := messages[0]; m.ID != "1" || m.Username != "friend" || m.Text != "a <b> tag" {
+		t.Errorf("unexpected first message: %+v", m)
+	}
+	if m := messages[1]; m.ID != "2" || m.Username != "friend" || len(m.Files) != 1 || m.Files[0] != "2.m4a" {
+		t.Errorf("unexpected voice message: %+v", m)
+	}
+	if m := messages[2]; m.ID != "3" || m.Username != "me" || len(m.Files) != 1 || m.Files[0] != "3.jpg" {
+		t.Errorf("unexpected photo message: %+v", m)
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "3.jpg")); err != nil || string(b) != "media /p.jpg" {
+		t.Errorf("photo not downloaded: %q, %v", b, err)
+	}
+
+	// An incremental export only fetches and writes the new messages, and
+	// doesn't download media again
+	srv.mu.Lock()
+	srv.newest = `{"item_id": "4", "item_type": "text", "user_id": 2, "timestamp": 400, "text": "From now on\nbye"}, ` + srv.newest
+	older, media := srv.older, srv.media
+	srv.mu.Unlock()
+
+	buf.Reset()
+	report, err = conv.Export(&buf, goinsta.ExportText, &goinsta.ExportOptions{Dir: dir, After: report.Last})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Messages != 1 || report.Last != 400 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+	if srv.older != older || srv.media != media {
+		t.Errorf("expected no older pages or media to be fetched, got %d pages and %d files", srv.older-older, srv.media-media)
+	}
+	text := buf.String()
+	if !strings.HasPrefix(text, "From friend Thu Jan  1 00:00:00 1970\n") || !strings.Contains(text, "\n>From now on\nbye\n") {
+		t.Errorf("unexpected transcript:\n%s", text)
+	}
+
+	// Without a directory, media are linked by URL
+	buf.Reset()
+	if _, err := exportConversation(t, srv).Export(&buf, goinsta.ExportHTML); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, s := range []string{"<title>friend</title>", `<img src="https://cdn.test/p.jpg?x=1">`, `<audio src="https://cdn.test/v.m4a" controls>`, "a &lt;b&gt; tag"} {
+		if !strings.Contains(page, s) {
+			t.Errorf("page does not contain %q:\n%s", s, page)
+		}
+	}
+
+	if _, err := conv.Export(&buf, "pdf"); err != goinsta.ErrExportFormat {
+		t.Errorf("expected ErrExportFormat, got %v", err)
+	}
+}
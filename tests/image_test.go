@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// withOrientation inserts an EXIF segment with the orientation tag into a JPEG.
+func withOrientation(b []byte, orientation byte) []byte {
+	tiff := []byte{
+		'M', 'M', 0, 42, 0, 0, 0, 8, // header, IFD at offset 8
+		0, 1, // one entry
+		0x01, 0x12, 0, 3, 0, 0, 0, 1, 0, orientation, 0, 0, // orientation, short
+		0, 0, 0, 0, // no next IFD
+	}
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	seg := append([]byte{0xFF, 0xE1, 0, byte(len(app1) + 2)}, app1...)
+	return append(append(append([]byte{}, b[:2]...), seg...), b[2:]...)
+}
+
+func decode(t *testing.T, b []byte) image.Image {
+	img, format, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" {
+		t.Fatalf("Expected a jpeg, got %s", format)
+	}
+	return img
+}
+
+func TestConvertToJPEG(t *testing.T) {
+	// Transparent PNG, with a red left half
+	src := image.NewNRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 20; y++ {
+			src.Set(x, y, color.NRGBA{R: 255, A: 255})
+		}
+	}
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, src); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := goinsta.ConvertToJPEG(buf.Bytes(), 100, color.Black)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decode(t, b)
+	if r, _, _, _ := img.At(5, 10).RGBA(); r>>8 < 240 {
+		t.Errorf("Expected red on the left, got %v", img.At(5, 10))
+	}
+	if r, g, b, _ := img.At(35, 10).RGBA(); r>>8 > 15 || g>>8 > 15 || b>>8 > 15 {
+		t.Errorf("Expected a black background, got %v", img.At(35, 10))
+	}
+
+	// GIF, flattened on the default white background
+	frame := image.NewPaletted(src.Bounds(), color.Palette{color.Transparent, color.NRGBA{R: 255, A: 255}})
+	draw.Draw(frame, frame.Bounds(), src, image.Point{}, draw.Src)
+	buf.Reset()
+	if err := gif.Encode(buf, frame, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err = goinsta.ConvertToJPEG(buf.Bytes(), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = decode(t, b)
+	if r, g, b, _ := img.At(35, 10).RGBA(); r>>8 < 240 || g>>8 < 240 || b>>8 < 240 {
+		t.Errorf("Expected a white background, got %v", img.At(35, 10))
+	}
+
+	// JPEG without orientation is returned as is
+	buf.Reset()
+	if err := jpeg.Encode(buf, src, nil); err != nil {
+		t.Fatal(err)
+	}
+	b, err = goinsta.ConvertToJPEG(buf.Bytes(), 0, nil)
+	if err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Errorf("Expected the JPEG to be left untouched (%v)", err)
+	}
+
+	// Without rotation, only the EXIF and ICC segments are removed
+	icc := append([]byte{0xFF, 0xE2, 0, 16}, []byte("ICC_PROFILE\x00\x01\x01")...)
+	tagged := withOrientation(buf.Bytes(), 1)
+	tagged = append(append(append([]byte{}, tagged[:2]...), icc...), tagged[2:]...)
+	b, err = goinsta.ConvertToJPEG(tagged, 0, nil)
+	if err != nil || !bytes.Equal(b, buf.Bytes()) {
+		t.Errorf("Expected the metadata to be stripped (%v)", err)
+	}
+
+	// JPEG rotated 90 degrees clockwise, the red half ends up on top
+	b, err = goinsta.ConvertToJPEG(withOrientation(buf.Bytes(), 6), 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img = decode(t, b)
+	if size := img.Bounds().Size(); size.X != 20 || size.Y != 40 {
+		t.Fatalf("Expected a 20x40 image, got %v", size)
+	}
+	if r, g, _, _ := img.At(10, 5).RGBA(); r>>8 < 200 || g>>8 > 60 {
+		t.Errorf("Expected red on top, got %v", img.At(10, 5))
+	}
+
+	// Unsupported input, no HEIC decoder is registered here
+	heic := append([]byte{0, 0, 0, 24}, []byte("ftypheic\x00\x00\x00\x00mif1heic")...)
+	if _, err := goinsta.ConvertToJPEG(heic, 0, nil); !errors.Is(err, goinsta.ErrNoHEICDecoder) {
+		t.Errorf("Expected ErrNoHEICDecoder, got %v", err)
+	}
+	if _, err := goinsta.ConvertToJPEG([]byte("not an image"), 0, nil); !errors.Is(err, goinsta.ErrInvalidImage) {
+		t.Errorf("Expected ErrInvalidImage, got %v", err)
+	}
+}
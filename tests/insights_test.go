@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestInsightsNotProfessional(t *testing.T) {
+	srv := &stubServer{responses: map[string]string{}}
+	insta := stubInsta(t, srv)
+	items := stubFeed(t, insta, srv, `{"pk": 10, "id": "10_1", "media_type": 1}`)
+
+	n := srv.count()
+	if _, err := insta.Account.Insights(goinsta.InsightsWeek); err != goinsta.ErrNotProfessionalAccount {
+		t.Errorf("expected ErrNotProfessionalAccount, got %v", err)
+	}
+	if _, err := items[0].Insights(); err != goinsta.ErrNotProfessionalAccount {
+		t.Errorf("expected ErrNotProfessionalAccount, got %v", err)
+	}
+	if srv.count() != n {
+		t.Error("expected no requests to be made")
+	}
+}
+
+func TestInsights(t *testing.T) {
+	srv := &stubServer{responses: map[string]string{
+		"insights/account_organic_insights/": `{"instagram_user": {"business_manager": {
+			"account_insights_unit": {"impressions_metric_count": 1200, "reach_metric_count": 800, "impressions_metric_graph": {"data_points": [{"label": "Mon", "value": 100}]}},
+			"account_summary_unit": {"posts_count": 12, "followers_count": 340, "followers_delta_from_last_week": 5},
+			"followers_unit": {"gender_graph": {"data_points": [{"label": "F", "value": 60}, {"label": "M", "value": 40}]}}
+		}}, "status": "ok"}`,
+		"insights/media_organic_insights/10/": `{"media_organic_insights": {"reach_count": 50, "impression_count": 70, "taps_back_count": 3}, "status": "ok"}`,
+	}}
+	insta := stubInsta(t, srv)
+	insta.Account.IsBusiness = true
+	items := stubFeed(t, insta, srv, `{"pk": 10, "id": "10_1", "media_type": 1}`)
+
+	insights, err := insta.Account.Insights(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uri, _ := srv.last()
+	if !strings.Contains(uri, "first=7") {
+		t.Errorf("expected the default period of 7 days, got %s", uri)
+	}
+	if insights.Period != goinsta.InsightsWeek || insights.Impressions != 1200 || insights.Reach != 800 || insights.Followers != 340 ||
+		insights.FollowersDelta != 5 || len(insights.ImpressionsGraph) != 1 || len(insights.Demographics.Gender) != 2 {
+		t.Errorf("unexpected insights: %+v", insights)
+	}
+
+	if _, err := insta.Account.Insights(goinsta.InsightsMonth); err != nil {
+		t.Fatal(err)
+	}
+	if uri, _ := srv.last(); !strings.Contains(uri, "first=30") {
+		t.Errorf("expected a period of 30 days, got %s", uri)
+	}
+
+	media, err := items[0].Insights()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if media.MediaID != "10_1" || media.Reach != 50 || media.Impressions != 70 || media.TapsBack != 3 {
+		t.Errorf("unexpected media insights: %+v", media)
+	}
+}
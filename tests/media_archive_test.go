@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestItemArchive(t *testing.T) {
+	srv := &stubServer{responses: map[string]string{}}
+	items := stubFeed(t, stubInsta(t, srv), srv, `{"pk": 10, "id": "10_1", "media_type": 1}, {"pk": 11, "id": "11_1", "media_type": 2}`)
+	photo, video := items[0], items[1]
+
+	if err := photo.Archive(); err != nil {
+		t.Fatal(err)
+	}
+	uri, form := srv.last()
+	if !strings.HasSuffix(uri, "/media/10_1/only_me/?media_type=PHOTO") || signedBody(t, form)["media_id"] != "10_1" {
+		t.Errorf("unexpected archive request to %s: %v", uri, form)
+	}
+
+	if err := video.Unarchive(); err != nil {
+		t.Fatal(err)
+	}
+	uri, form = srv.last()
+	if !strings.HasSuffix(uri, "/media/11_1/undo_only_me/?media_type=VIDEO") || signedBody(t, form)["media_id"] != "11_1" {
+		t.Errorf("unexpected unarchive request to %s: %v", uri, form)
+	}
+}
+
+func TestStoryArchive(t *testing.T) {
+	srv := &stubServer{responses: map[string]string{}}
+	insta := stubInsta(t, srv)
+
+	srv.responses["archive/reel/day_shells/"] = `{"items": [{"id": "archiveDay:1", "media_count": 2}, {"id": "archiveDay:2", "media_count": 1}], "more_available": true, "max_id": "cursor2", "status": "ok"}`
+	archive := insta.Account.StoryArchive()
+	if !archive.Next() {
+		t.Fatal(archive.Error())
+	}
+	if len(archive.Items) != 2 || archive.Items[0].ReelType != "archive_day_reel" || archive.Error() != nil {
+		t.Errorf("unexpected first page: %+v", archive)
+	}
+	if _, form := srv.last(); form.Get("max_id") != "" {
+		t.Errorf("unexpected cursor on the first page: %s", form.Get("max_id"))
+	}
+
+	// The days of the next page replace the previous ones
+	srv.mu.Lock()
+	srv.responses["archive/reel/day_shells/"] = `{"items": [{"id": "archiveDay:3", "media_count": 4}], "more_available": false, "status": "ok"}`
+	srv.mu.Unlock()
+	if !archive.Next() {
+		t.Fatal(archive.Error())
+	}
+	if _, form := srv.last(); form.Get("max_id") != "cursor2" {
+		t.Errorf("expected cursor2, got %q", form.Get("max_id"))
+	}
+	if len(archive.Items) != 1 || archive.Items[0].ID != "archiveDay:3" {
+		t.Errorf("unexpected second page: %+v", archive.Items)
+	}
+	if archive.Error() != goinsta.ErrNoMore {
+		t.Errorf("expected ErrNoMore, got %v", archive.Error())
+	}
+
+	n := srv.count()
+	if archive.Next() {
+		t.Error("expected no more pages")
+	}
+	if srv.count() != n {
+		t.Error("expected no request after the last page")
+	}
+}
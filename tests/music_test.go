@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+const testTrack = `{
+	"id": "123",
+	"audio_asset_id": "456",
+	"audio_cluster_id": "789",
+	"title": "Song",
+	"display_artist": "Artist",
+	"progressive_download_url": "https://example.com/song.m4a",
+	"highlight_start_times_in_ms": [30000, 60000],
+	"duration_in_ms": 180000,
+	"has_lyrics": true
+}`
+
+// musicServer serves the music endpoints on an upload server.
+func musicServer() *ruploadServer {
+	srv := newRuploadServer(-1)
+	srv.handle("music/audio_global_search", func(_ *http.Request, form url.Values) (int, string, error) {
+		if form.Get("query") != "song" {
+			return http.StatusOK, `{"items": [], "status": "ok"}`, nil
+		}
+		return http.StatusOK, `{"items": [{"track": ` + testTrack + `}], "status": "ok"}`, nil
+	})
+	srv.handle("clips/music", func(_ *http.Request, form url.Values) (int, string, error) {
+		if form.Get("max_id") != "" {
+			return http.StatusOK, `{"items": [{"media": {"pk": 2, "id": "2_1"}}], "paging_info": {"more_available": false}, "status": "ok"}`, nil
+		}
+		return http.StatusOK, `{"items": [{"media": {"pk": 1, "id": "1_1"}}], "paging_info": {"max_id": "next", "more_available": true}, "status": "ok"}`, nil
+	})
+	return srv
+}
+
+func TestMusic(t *testing.T) {
+	srv := musicServer()
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	tracks, err := insta.SearchMusic("song")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 1 || tracks[0].Title != "Song" || tracks[0].PreviewURL() != "https://example.com/song.m4a" {
+		t.Fatalf("Unexpected search result %+v", tracks)
+	}
+	track := tracks[0]
+
+	// Reels using the track
+	feed := track.Reels()
+	var ids []string
+	for feed.Next() {
+		for _, item := range feed.Items {
+			ids = append(ids, item.GetID())
+		}
+	}
+	if !errors.Is(feed.Error(), goinsta.ErrNoMore) || strings.Join(ids, ",") != "1_1,2_1" {
+		t.Errorf("Unexpected audio feed %v (%v)", ids, feed.Error())
+	}
+	if _, forms := srv.requests("clips/music"); len(forms) != 2 || forms[0].Get("audio_cluster_id") != "789" {
+		t.Errorf("Unexpected audio feed requests %v", forms)
+	}
+
+	// Reel with the track, from its first highlight
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:              bytes.NewReader(fakeVideo(50000)),
+		Audio:             track,
+		AudioMix:          -0.25,
+		OriginalAudioName: "Behind the scenes",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := srv.configs[0]
+	params, _ := config["music_params"].(map[string]interface{})
+	if params["audio_asset_id"] != "123" || params["audio_asset_start_time_in_ms"] != 30000.0 || params["overlap_duration_in_ms"] != 5000.0 {
+		t.Errorf("Unexpected music params %v", params)
+	}
+	var metadata struct {
+		Original map[string]interface{} `json:"original"`
+		Song     map[string]interface{} `json:"song"`
+	}
+	if err := json.Unmarshal([]byte(config["clips_audio_metadata"].(string)), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Original["volume_level"] != 0.75 || metadata.Original["title"] != "Behind the scenes" || metadata.Song["volume_level"] != 1.0 {
+		t.Errorf("Unexpected audio metadata %+v", metadata)
+	}
+
+	// Without AudioMix, the video's own audio is not muted
+	_, err = insta.Upload(&goinsta.UploadOptions{File: bytes.NewReader(fakeVideo(50000)), Audio: track})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(srv.configs[1]["clips_audio_metadata"].(string)), &metadata); err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Original["volume_level"] != 1.0 || metadata.Song["volume_level"] != 1.0 {
+		t.Errorf("Expected a balanced mix, got %+v", metadata)
+	}
+
+	// Story with a music sticker
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:     bytes.NewReader(fakeVideo(50000)),
+		IsStory:  true,
+		Stickers: []goinsta.StorySticker{goinsta.MusicSticker{Track: track, Start: time.Minute, ShowLyrics: true}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stickers []map[string]interface{}
+	if err := json.Unmarshal([]byte(srv.configs[2]["story_music_stickers"].(string)), &stickers); err != nil {
+		t.Fatal(err)
+	}
+	if len(stickers) != 1 || stickers[0]["audio_asset_start_time_in_ms"] != 60000.0 || stickers[0]["display_type"] != "lyrics" {
+		t.Errorf("Unexpected music sticker %v", stickers)
+	}
+
+	// Invalid audio
+	_, err = insta.Upload(&goinsta.UploadOptions{File: bytes.NewReader(fakeVideo(50000)), Audio: track, IsStory: true})
+	if !errors.Is(err, goinsta.ErrAudioNotReel) {
+		t.Errorf("Expected ErrAudioNotReel, got %v", err)
+	}
+	_, err = insta.Upload(&goinsta.UploadOptions{File: bytes.NewReader(fakeVideo(50000)), Audio: track, AudioStart: 4 * time.Minute})
+	var cerr goinsta.ConstraintError
+	if !errors.As(err, &cerr) || cerr.Constraint != "audio start" {
+		t.Errorf("Expected an audio start constraint error, got %v", err)
+	}
+}
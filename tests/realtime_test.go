@@ -0,0 +1,345 @@
+package tests
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// mqttBroker is a stand-in for Instagram's realtime broker, which accepts TLS
+// connections on a local port.
+type mqttBroker struct {
+	t      *testing.T
+	ln     net.Listener
+	client *tls.Config
+}
+
+func newMQTTBroker(t *testing.T) *mqttBroker {
+	// Borrow the certificate of an httptest server
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.StartTLS()
+	certs := srv.TLS.Certificates
+	roots := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	srv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &mqttBroker{t: t, ln: ln, client: &tls.Config{RootCAs: roots, ServerName: "example.com"}}
+}
+
+func (b *mqttBroker) realtime(insta *goinsta.Instagram) *goinsta.Realtime {
+	rt := insta.NewRealtime()
+	rt.Addr = b.ln.Addr().String()
+	rt.TLSConfig = b.client
+	rt.Backoff = 10 * time.Millisecond
+	rt.OnError = func(error) {}
+	return rt
+}
+
+// accept accepts a connection, and returns the decompressed connect payload.
+func (b *mqttBroker) accept() (net.Conn, *bufio.Reader, []byte) {
+	conn, err := b.ln.Accept()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	typ, _, body := b.read(r)
+	if typ != 1 {
+		b.t.Fatalf("expected connect packet, got type %d", typ)
+	}
+	if !bytes.HasPrefix(body, []byte("\x00\x06MQTToT\x03\xc2")) {
+		b.t.Fatalf("invalid connect header: %q", body[:10])
+	}
+	return conn, r, inflateTest(b.t, body[12:])
+}
+
+func (b *mqttBroker) read(r *bufio.Reader) (byte, byte, []byte) {
+	header, err := r.ReadByte()
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		b.t.Fatal(err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		b.t.Fatal(err)
+	}
+	return header >> 4, header & 0x0f, body
+}
+
+// readPublish reads a publish packet, and returns its topic, packet ID and
+// decompressed payload.
+func (b *mqttBroker) readPublish(r *bufio.Reader) (string, uint16, []byte) {
+	typ, flags, body := b.read(r)
+	if typ != 3 {
+		b.t.Fatalf("expected publish packet, got type %d", typ)
+	}
+	n := int(binary.BigEndian.Uint16(body))
+	topic, body := string(body[2:2+n]), body[2+n:]
+	var id uint16
+	if flags&0x06 != 0 {
+		id, body = binary.BigEndian.Uint16(body), body[2:]
+	}
+	return topic, id, inflateTest(b.t, body)
+}
+
+func (b *mqttBroker) write(conn net.Conn, typ, flags byte, body []byte) {
+	length := make([]byte, binary.MaxVarintLen32)
+	packet := append([]byte{typ<<4 | flags}, length[:binary.PutUvarint(length, uint64(len(body)))]...)
+	if _, err := conn.Write(append(packet, body...)); err != nil {
+		b.t.Fatal(err)
+	}
+}
+
+func (b *mqttBroker) publish(conn net.Conn, topic string, id uint16, payload string) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	w.Write([]byte(payload))
+	w.Close()
+
+	body := append(uint16Bytes(uint16(len(topic))), topic...)
+	var flags byte
+	if id != 0 {
+		flags = 1 << 1
+		body = append(body, uint16Bytes(id)...)
+	}
+	b.write(conn, 3, flags, append(body, buf.Bytes()...))
+}
+
+func uint16Bytes(n uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, n)
+	return b
+}
+
+func inflateTest(t *testing.T, b []byte) []byte {
+	r, err := zlib.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func realtimeInsta(t *testing.T, auth string) *goinsta.Instagram {
+	insta, err := goinsta.ImportConfig(goinsta.ConfigFile{
+		ID:            1,
+		PhoneID:       "0123456789abcdefghijklmnop",
+		HeaderOptions: map[string]string{"Authorization": auth},
+		Account:       &goinsta.Account{ID: 1},
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return insta
+}
+
+// jsonString encodes v as a JSON string, as values of patch operations are
+// JSON encoded themselves.
+func jsonString(v string) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func TestRealtime(t *testing.T) {
+	broker := newMQTTBroker(t)
+	rt := broker.realtime(realtimeInsta(t, "Bearer IGT:2:token"))
+	rt.SetState(100, 1666000000000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- rt.Run(ctx) }()
+
+	conn, r, payload := broker.accept()
+	for _, s := range []string{"authorization=Bearer IGT:2:token", "cookie_auth", "0123456789abcdefghij"} {
+		if !bytes.Contains(payload, []byte(s)) {
+			t.Errorf("connect payload does not contain %q", s)
+		}
+	}
+	broker.write(conn, 2, 0, []byte{0, 0})
+
+	// Subscription to the inbox events
+	topic, id, sub := broker.readPublish(r)
+	var state struct {
+		SeqID        int64 `json:"seq_id"`
+		SnapshotAtMs int64 `json:"snapshot_at_ms"`
+	}
+	if err := json.Unmarshal(sub, &state); err != nil {
+		t.Fatal(err)
+	}
+	if topic != "134" || id == 0 || state.SeqID != 100 || state.SnapshotAtMs != 1666000000000 {
+		t.Errorf("unexpected subscription on %s: %s", topic, sub)
+	}
+	broker.write(conn, 4, 0, uint16Bytes(id))
+	broker.publish(conn, "135", 0, `{"succeeded": true}`)
+
+	// Message sync with one of each event
+	broker.publish(conn, "146", 7, `[{"event": "patch", "seq_id": 105, "data": [
+		{"op": "add", "path": "/direct_v2/threads/340/items/1", "value": `+jsonString(`{"item_id": "1", "user_id": 2, "item_type": "text", "text": "hi"}`)+`},
+		{"op": "add", "path": "/direct_v2/threads/340/items/1/reactions/emojis/2", "value": `+jsonString(`{"sender_id": 2, "timestamp": 1666000000000001, "emoji": "🔥"}`)+`},
+		{"op": "add", "path": "/direct_v2/threads/340/activity_indicator_id/abc", "value": `+jsonString(`{"sender_id": "2", "activity_status": 1, "ttl": 12000}`)+`},
+		{"op": "replace", "path": "/direct_v2/threads/340/participants/2/has_seen", "value": `+jsonString(`{"item_id": "1", "timestamp": "1666000000000002"}`)+`},
+		{"op": "remove", "path": "/direct_v2/threads/340/items/0", "value": ""},
+		{"op": "add", "path": "/direct_v2/threads/340/unknown", "value": "{}"}
+	]}]`)
+	if typ, _, body := broker.read(r); typ != 4 || binary.BigEndian.Uint16(body) != 7 {
+		t.Errorf("expected puback for packet 7, got type %d: %v", typ, body)
+	}
+
+	var events []goinsta.RealtimeEvent
+	for len(events) < 5 {
+		select {
+		case ev := <-rt.Events:
+			events = append(events, ev)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %d", len(events))
+		}
+	}
+	if ev, ok := events[0].(*goinsta.MessageEvent); !ok || ev.ThreadID != "340" || ev.Item.Text != "hi" || ev.Updated {
+		t.Errorf("unexpected message event: %#v", events[0])
+	}
+	if ev, ok := events[1].(*goinsta.ReactionEvent); !ok || ev.ItemID != "1" || ev.Reaction.Emoji != "🔥" || ev.Reaction.SenderID != 2 || ev.Removed {
+		t.Errorf("unexpected reaction event: %#v", events[1])
+	}
+	if ev, ok := events[2].(*goinsta.TypingEvent); !ok || ev.UserID != 2 || !ev.Typing {
+		t.Errorf("unexpected typing event: %#v", events[2])
+	}
+	if ev, ok := events[3].(*goinsta.SeenEvent); !ok || ev.UserID != 2 || ev.ItemID != "1" || ev.Timestamp != 1666000000000002 {
+		t.Errorf("unexpected seen event: %#v", events[3])
+	}
+	if ev, ok := events[4].(*goinsta.MessageRemovedEvent); !ok || ev.ItemID != "0" {
+		t.Errorf("unexpected removed event: %#v", events[4])
+	}
+
+	// After losing the connection, the client continues from the last event
+	conn.Close()
+	conn, r, _ = broker.accept()
+	defer conn.Close()
+	broker.write(conn, 2, 0, []byte{0, 0})
+	_, _, sub = broker.readPublish(r)
+	if err := json.Unmarshal(sub, &state); err != nil {
+		t.Fatal(err)
+	}
+	if state.SeqID != 105 {
+		t.Errorf("expected to resubscribe from seq id 105, got %d", state.SeqID)
+	}
+	if seqID, _ := rt.State(); seqID != 105 {
+		t.Errorf("expected seq id 105, got %d", seqID)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+	if _, ok := <-rt.Events; ok {
+		t.Error("expected Events to be closed")
+	}
+	if err := rt.Run(context.Background()); err != goinsta.ErrRealtimeRunning {
+		t.Errorf("expected ErrRealtimeRunning, got %v", err)
+	}
+}
+
+func TestRealtimeRefused(t *testing.T) {
+	broker := newMQTTBroker(t)
+	rt := broker.realtime(realtimeInsta(t, "Bearer IGT:2:expired"))
+	rt.SetState(100, 1666000000000)
+
+	done := make(chan error, 1)
+	go func() { done <- rt.Run(context.Background()) }()
+
+	conn, _, _ := broker.accept()
+	defer conn.Close()
+	broker.write(conn, 2, 0, []byte{0, 5})
+	select {
+	case err := <-done:
+		if err != goinsta.ErrRealtimeRefused {
+			t.Errorf("expected ErrRealtimeRefused, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the connection was refused")
+	}
+
+	rt = broker.realtime(realtimeInsta(t, ""))
+	rt.SetState(100, 1666000000000)
+	if err := rt.Run(context.Background()); err != goinsta.ErrLoginRequired {
+		t.Errorf("expected ErrLoginRequired, got %v", err)
+	}
+}
+
+func TestRealtimeDefaults(t *testing.T) {
+	if err := (&goinsta.Realtime{}).Run(context.Background()); err != goinsta.ErrRealtimeNotCreated {
+		t.Errorf("expected ErrRealtimeNotCreated, got %v", err)
+	}
+
+	// Zero durations use the defaults, instead of panicking or reconnecting
+	// without delay
+	broker := newMQTTBroker(t)
+	rt := broker.realtime(realtimeInsta(t, "Bearer IGT:2:token"))
+	rt.SetState(100, 1666000000000)
+	rt.KeepAlive, rt.Backoff = 0, 0
+	lost := make(chan error, 1)
+	rt.OnError = func(err error) { lost <- err }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- rt.Run(ctx) }()
+
+	conn, r, _ := broker.accept()
+	broker.write(conn, 2, 0, []byte{0, 0})
+	broker.readPublish(r)
+	conn.Close()
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the closed connection was not reported")
+	}
+	if rt.KeepAlive != time.Minute || rt.Backoff != 5*time.Second {
+		t.Errorf("expected the default keep alive and backoff, got %s and %s", rt.KeepAlive, rt.Backoff)
+	}
+
+	reconnected := make(chan struct{}, 1)
+	go func() {
+		if conn, err := broker.ln.Accept(); err == nil {
+			conn.Close()
+			reconnected <- struct{}{}
+		}
+	}()
+	select {
+	case <-reconnected:
+		t.Error("expected to wait before reconnecting")
+	case <-time.After(200 * time.Millisecond):
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
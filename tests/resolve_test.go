@@ -0,0 +1,111 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestParseURL(t *testing.T) {
+	const id = "2762732340939332331"
+
+	tests := []struct {
+		url      string
+		expected goinsta.ParsedURL
+	}{
+		{"https://www.instagram.com/p/CZXMm0vLBrr/", goinsta.ParsedURL{Type: goinsta.URLMedia, Code: "CZXMm0vLBrr", ID: id}},
+		{"instagram.com/reel/CZXMm0vLBrr?utm_source=ig_web_copy_link", goinsta.ParsedURL{Type: goinsta.URLMedia, Code: "CZXMm0vLBrr", ID: id}},
+		{"http://instagr.am/p/CZXMm0vLBrr", goinsta.ParsedURL{Type: goinsta.URLMedia, Code: "CZXMm0vLBrr", ID: id}},
+		{"https://www.instagram.com/tv/CZXMm0vLBrrAbCdEfGh/", goinsta.ParsedURL{Type: goinsta.URLMedia, Code: "CZXMm0vLBrr", ID: id}},
+		{"https://www.instagram.com/someone/p/CZXMm0vLBrr/", goinsta.ParsedURL{Type: goinsta.URLMedia, Code: "CZXMm0vLBrr", ID: id, Username: "someone"}},
+		{"https://www.instagram.com/stories/someone/2762732340939332331/", goinsta.ParsedURL{Type: goinsta.URLStory, Username: "someone", ID: id}},
+		{"https://www.instagram.com/stories/someone/", goinsta.ParsedURL{Type: goinsta.URLStories, Username: "someone"}},
+		{"https://www.instagram.com/stories/highlights/17983407089364361/", goinsta.ParsedURL{Type: goinsta.URLHighlight, ID: "17983407089364361"}},
+		{"https://instagram.com/s/aGlnaGxpZ2h0OjE3OTgzNDA3MDg5MzY0MzYx?igshid=abc", goinsta.ParsedURL{Type: goinsta.URLHighlight, ID: "17983407089364361"}},
+		{"https://www.instagram.com/explore/tags/golang/", goinsta.ParsedURL{Type: goinsta.URLHashtag, Tag: "golang"}},
+		{"https://www.instagram.com/explore/locations/213385402/amsterdam/", goinsta.ParsedURL{Type: goinsta.URLLocation, ID: "213385402"}},
+		{"https://m.instagram.com/someone?hl=en", goinsta.ParsedURL{Type: goinsta.URLUser, Username: "someone"}},
+		{"https://www.instagram.com/share/reel/BAeDTNkYzd", goinsta.ParsedURL{Type: goinsta.URLShare}},
+	}
+
+	for _, test := range tests {
+		r, err := goinsta.ParseURL(test.url)
+		if err != nil {
+			t.Errorf("%s: %v", test.url, err)
+			continue
+		}
+		e := test.expected
+		if r.Type != e.Type || r.Code != e.Code || r.ID != e.ID || r.Username != e.Username || r.Tag != e.Tag {
+			t.Errorf("%s: expected %+v, got %+v", test.url, e, *r)
+		}
+	}
+
+	for _, url := range []string{
+		"https://example.com/p/CZXMm0vLBrr/",
+		"https://www.instagram.com/",
+		"https://www.instagram.com/accounts/login/",
+		"https://www.instagram.com/explore/",
+	} {
+		_, err := goinsta.ParseURL(url)
+		if !errors.Is(err, goinsta.ErrInvalidURL) && !errors.Is(err, goinsta.ErrUnsupportedURL) {
+			t.Errorf("%s: expected an error, got %v", url, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	reelID, err := goinsta.MediaIDFromShortID("CaBcDeFgHiJ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const postID, storyID = "2762732340939332331", "2762732340939332999"
+	media := func(id string) string {
+		return `{"items": [{"pk": ` + id + `, "id": "` + id + `_7", "user": {"pk": 7, "username": "someone"}}], "status": "ok"}`
+	}
+	srv := &stubServer{responses: map[string]string{
+		"media/" + postID + "/info/":  media(postID),
+		"media/" + reelID + "/info/":  media(reelID),
+		"media/" + storyID + "/info/": media(storyID),
+		"media/1/info/":               `{"items": [], "status": "ok"}`,
+		"users/someone/usernameinfo/": `{"user": {"pk": 7, "username": "someone"}, "status": "ok"}`,
+	}}
+	insta := stubInsta(t, srv)
+
+	for _, test := range []struct {
+		url, path, id string
+	}{
+		{"https://www.instagram.com/p/CZXMm0vLBrr/", "media/" + postID + "/info/", postID},
+		{"https://www.instagram.com/reel/CaBcDeFgHiJ/", "media/" + reelID + "/info/", reelID},
+		{"https://www.instagram.com/stories/someone/" + storyID + "/", "media/" + storyID + "/info/", storyID},
+	} {
+		obj, err := insta.Resolve(test.url)
+		if err != nil {
+			t.Fatalf("%s: %v", test.url, err)
+		}
+		if path, _ := srv.last(); !strings.Contains(path, test.path) {
+			t.Errorf("%s: expected a request to %s, got %s", test.url, test.path, path)
+		}
+		if item, ok := obj.(*goinsta.Item); !ok || item.GetID() != test.id+"_7" {
+			t.Errorf("%s: expected item %s, got %#v", test.url, test.id, obj)
+		}
+	}
+
+	obj, err := insta.Resolve("instagram.com/someone?hl=en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, ok := obj.(*goinsta.User); !ok || user.ID != 7 || user.Username != "someone" {
+		t.Errorf("Expected user someone, got %#v", obj)
+	}
+
+	// Posts that no longer exist
+	code, err := goinsta.ShortIDFromMediaID("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := insta.Resolve("https://www.instagram.com/p/" + code + "/"); !errors.Is(err, goinsta.ErrMediaDeleted) {
+		t.Errorf("Expected ErrMediaDeleted, got %v", err)
+	}
+}
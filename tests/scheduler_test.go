@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/jpeg"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// flakyTransport fails the first requests, before passing them on.
+type flakyTransport struct {
+	mu    sync.Mutex
+	fails int
+	next  http.RoundTripper
+}
+
+func (f *flakyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	if f.fails > 0 {
+		f.fails--
+		f.mu.Unlock()
+		return nil, errors.New("connection reset")
+	}
+	f.mu.Unlock()
+	return f.next.RoundTrip(req)
+}
+
+// fail makes the requests to endpoints containing path fail, with a network
+// error, or with a response of status code if set.
+func (s *ruploadServer) fail(path string, status int) {
+	s.handle(path, func(*http.Request, url.Values) (int, string, error) {
+		if status == 0 {
+			return 0, "", errors.New("connection reset")
+		}
+		return status, `{"status": "fail", "message": "failed"}`, nil
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.jpg")
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 100, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(photo, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.jpg")
+	if err := os.WriteFile(invalid, []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(&flakyTransport{fails: 1, next: srv})
+
+	store := goinsta.NewScheduleFileStore(filepath.Join(dir, "schedule.json"))
+	s, err := insta.NewScheduler(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Backoff = 10 * time.Millisecond
+
+	if _, err := s.Schedule(&goinsta.ScheduledPost{}); !errors.Is(err, goinsta.ErrScheduleNoMedia) {
+		t.Errorf("Expected ErrScheduleNoMedia, got %v", err)
+	}
+	if _, err := s.Schedule(&goinsta.ScheduledPost{Files: []string{filepath.Join(dir, "missing.jpg")}}); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing file to be rejected, got %v", err)
+	}
+
+	later, err := s.Schedule(&goinsta.ScheduledPost{Files: []string{photo}, PublishAt: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := s.Schedule(&goinsta.ScheduledPost{
+		Files:    []string{photo},
+		Caption:  "Scheduled",
+		UserTags: []goinsta.ScheduledTag{{UserID: 42, Position: [2]float64{0.5, 0.5}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed, err := s.Schedule(&goinsta.ScheduledPost{Files: []string{invalid}, PublishAt: time.Now().Add(50 * time.Millisecond)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var retries, done int
+	s.OnRetry = func(post goinsta.ScheduledPost, err error) {
+		retries++
+	}
+	s.OnPublished = func(post goinsta.ScheduledPost, item *goinsta.Item) {
+		if done++; done == 2 {
+			cancel()
+		}
+	}
+	s.OnFailed = func(post goinsta.ScheduledPost, err error) {
+		if !errors.Is(err, goinsta.ErrInvalidFormat) {
+			t.Errorf("Unexpected error %v", err)
+		}
+		if done++; done == 2 {
+			cancel()
+		}
+	}
+	if err := s.Run(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the scheduler to be canceled, got %v", err)
+	}
+	if retries != 1 {
+		t.Errorf("Expected one retry, got %d", retries)
+	}
+
+	// The outcome is stored, and loaded after a restart
+	s, err = insta.NewScheduler(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts := map[string]goinsta.ScheduledPost{}
+	for _, p := range s.Posts() {
+		posts[p.ID] = p
+	}
+	if p := posts[published]; p.Status != goinsta.SchedulePublished || p.MediaID == "" || p.Attempts != 2 {
+		t.Errorf("Unexpected published post %+v", p)
+	}
+	if p := posts[failed]; p.Status != goinsta.ScheduleFailed || p.Attempts != 1 || p.LastError == "" {
+		t.Errorf("Unexpected failed post %+v", p)
+	}
+	if p := posts[later]; p.Status != goinsta.SchedulePending || p.Attempts != 0 {
+		t.Errorf("Unexpected pending post %+v", p)
+	}
+	if len(srv.configs) != 1 || srv.configs[0]["caption"] != "Scheduled" {
+		t.Errorf("Expected one configured post, got %v", srv.configs)
+	}
+
+	if err := s.Cancel(later); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Cancel(later); !errors.Is(err, goinsta.ErrScheduleNotFound) {
+		t.Errorf("Expected ErrScheduleNotFound, got %v", err)
+	}
+	s, err = insta.NewScheduler(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Posts()); n != 2 {
+		t.Errorf("Expected 2 stored posts after canceling, got %d", n)
+	}
+}
+
+func TestSchedulerRetries(t *testing.T) {
+	dir := t.TempDir()
+	photo := filepath.Join(dir, "photo.jpg")
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 100, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(photo, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		name     string
+		path     string
+		status   int
+		attempts int
+	}{
+		{"server error before configure", "rupload_igphoto", 502, 3},
+		{"client error before configure", "rupload_igphoto", 400, 1},
+		{"network error at configure", "configure", 0, 1},
+		{"server error at configure", "configure", 500, 1},
+	} {
+		srv := newRuploadServer(-1)
+		srv.fail(c.path, c.status)
+		insta := goinsta.New("", "")
+		insta.Account = &goinsta.Account{ID: 1}
+		insta.SetHTTPTransport(srv)
+
+		s, err := insta.NewScheduler(goinsta.NewScheduleFileStore(filepath.Join(dir, c.name+".json")))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Retries = 2
+		s.Backoff = time.Millisecond
+		id, err := s.Schedule(&goinsta.ScheduledPost{Files: []string{photo}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		s.OnFailed = func(goinsta.ScheduledPost, error) { cancel() }
+		if err := s.Run(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected the post to fail, got %v", c.name, err)
+		}
+		cancel()
+
+		for _, p := range s.Posts() {
+			if p.ID == id && (p.Status != goinsta.ScheduleFailed || p.Attempts != c.attempts) {
+				t.Errorf("%s: expected %d attempts, got %+v", c.name, c.attempts, p)
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestMediaSelector(t *testing.T) {
+	candidates := []goinsta.Candidate{
+		{Width: 320, Height: 320, URL: "320"},
+		{Width: 1440, Height: 1440, URL: "1440"},
+		{Width: 640, Height: 640, URL: "640"},
+		{Width: 1080, Height: 1080, URL: "1080"},
+		{Width: 4000, Height: 4000, URL: ""},
+	}
+
+	tests := []struct {
+		name     string
+		selector goinsta.MediaSelector
+		expected string
+	}{
+		{"best", goinsta.SelectBest, "1440"},
+		{"smallest", goinsta.SelectSmallest, "320"},
+		{"closest 1080", goinsta.SelectClosestWidth(1080), "1080"},
+		{"closest 900", goinsta.SelectClosestWidth(900), "1080"},
+		{"smallest above 640", goinsta.SelectSmallestAbove(640), "640"},
+		{"smallest above 641", goinsta.SelectSmallestAbove(641), "1080"},
+		{"smallest above 5000", goinsta.SelectSmallestAbove(5000), "1440"},
+	}
+	for _, test := range tests {
+		if url := goinsta.SelectMedia(candidates, test.selector); url != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, url)
+		}
+	}
+
+	if url := goinsta.GetBest(candidates); url != "1440" {
+		t.Errorf("GetBest: expected 1440, got %s", url)
+	}
+
+	// GetBest only prefers versions that are both wider and taller
+	wide := []goinsta.Candidate{{Width: 1080, Height: 1080, URL: "square"}, {Width: 1440, Height: 1000, URL: "wide"}}
+	if url := goinsta.GetBest(wide); url != "square" {
+		t.Errorf("GetBest: expected square, got %s", url)
+	}
+	if url := goinsta.SelectMedia(wide, goinsta.SelectBest); url != "wide" {
+		t.Errorf("SelectBest: expected wide, got %s", url)
+	}
+}
+
+func TestSelectMaxBytes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The file size is the width times 100 bytes
+		w.Header().Set("Content-Length", r.URL.Path[1:]+"00")
+	}))
+	defer srv.Close()
+
+	var videos []goinsta.Video
+	for _, w := range []int{480, 720, 1080} {
+		videos = append(videos, goinsta.Video{Width: w, Height: w, URL: srv.URL + "/" + strconv.Itoa(w)})
+	}
+
+	if url := goinsta.SelectMedia(videos, goinsta.SelectMaxBytes(100000)); url != srv.URL+"/720" {
+		t.Errorf("Expected 720 within budget, got %s", url)
+	}
+	if url := goinsta.SelectMedia(videos, goinsta.SelectMaxBytes(10)); url != srv.URL+"/480" {
+		t.Errorf("Expected the smallest version when all exceed the budget, got %s", url)
+	}
+}
+
+func TestDownloadProfilePicTo(t *testing.T) {
+	var heads, gets int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			atomic.AddInt32(&heads, 1)
+		} else {
+			atomic.AddInt32(&gets, 1)
+		}
+		w.Header().Set("Content-Length", "4")
+		w.Write([]byte("pic!"))
+	}))
+	defer srv.Close()
+
+	user := &goinsta.User{
+		ProfilePicURL:        srv.URL + "/small.jpg",
+		HdProfilePicVersions: []goinsta.PicURLInfo{{Width: 320, Height: 320, URL: srv.URL + "/320.jpg"}},
+	}
+	user.SetInstagram(goinsta.New("", ""))
+
+	dir := t.TempDir()
+	if err := user.DownloadProfilePicTo(dir+"/", goinsta.SelectMaxBytes(100)); err != nil {
+		t.Fatal(err)
+	}
+	// The selector only runs once, for both the download and the file name
+	if atomic.LoadInt32(&heads) != 1 || atomic.LoadInt32(&gets) != 1 {
+		t.Errorf("expected 1 HEAD and 1 GET request, got %d and %d", heads, gets)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "320.jpg")); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"strconv"
 	"testing"
 
 	"github.com/Davincible/goinsta/v3"
@@ -15,3 +16,110 @@ func TestMediaIDFromShortID(t *testing.T) {
 		t.Fatal("Invalid mediaID")
 	}
 }
+
+var shortIDs = []struct {
+	id   string
+	code string
+}{
+	{"0", "A"},
+	{"1", "B"},
+	{"64", "BA"},
+	{"2762732340939332331", "CZXMm0vLBrr"},
+	{"1477090425239445006", "BR_repxhx4O"},
+	{"9223372036854775807", "H__________"},
+}
+
+func TestShortID(t *testing.T) {
+	for _, test := range shortIDs {
+		code, err := goinsta.ShortIDFromMediaID(test.id)
+		if err != nil || code != test.code {
+			t.Errorf("ShortIDFromMediaID(%s): expected %s, got %s (%v)", test.id, test.code, code, err)
+		}
+		id, err := goinsta.MediaIDFromShortID(test.code)
+		if err != nil || id != test.id {
+			t.Errorf("MediaIDFromShortID(%s): expected %s, got %s (%v)", test.code, test.id, id, err)
+		}
+	}
+
+	// Item.GetID returns <pk>_<user id>
+	if code, _ := goinsta.ShortIDFromMediaID("2762732340939332331_4562372"); code != "CZXMm0vLBrr" {
+		t.Errorf("Failed to convert media ID with user ID, got %s", code)
+	}
+
+	for _, id := range []string{"", "abc", "-1", "_123", "99999999999999999999"} {
+		if _, err := goinsta.ShortIDFromMediaID(id); err == nil {
+			t.Errorf("ShortIDFromMediaID(%q): expected an error", id)
+		}
+	}
+	for _, code := range []string{"", "CZXM!0vLBrr", "I__________", "CZXMm0vLBrrCZXMm0vLBrr"} {
+		if _, err := goinsta.MediaIDFromShortID(code); err == nil {
+			t.Errorf("MediaIDFromShortID(%q): expected an error", code)
+		}
+	}
+}
+
+func TestURLBuilders(t *testing.T) {
+	user := goinsta.User{Username: "someone"}
+	post := goinsta.Item{Pk: 2762732340939332331, ID: "2762732340939332331_42", User: user}
+	reel := post
+	reel.ProductType = "clips"
+	igtv := post
+	igtv.ProductType = "igtv"
+	igtv.Code = "CZXMm0vLBrr"
+	story := post
+	story.ProductType = "story"
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{post.URL(), "https://www.instagram.com/p/CZXMm0vLBrr/"},
+		{reel.URL(), "https://www.instagram.com/reel/CZXMm0vLBrr/"},
+		{igtv.URL(), "https://www.instagram.com/tv/CZXMm0vLBrr/"},
+		{story.URL(), "https://www.instagram.com/stories/someone/2762732340939332331/"},
+		{user.URL(), "https://www.instagram.com/someone/"},
+		{(&goinsta.Reel{ID: "highlight:17983407089364361"}).URL(), "https://www.instagram.com/stories/highlights/17983407089364361/"},
+		{(&goinsta.Reel{ID: 42.0, User: user}).URL(), "https://www.instagram.com/stories/someone/"},
+		{goinsta.New("", "").NewHashtag("café").URL(), "https://www.instagram.com/explore/tags/caf%C3%A9/"},
+	}
+	for _, test := range tests {
+		if test.url != test.expected {
+			t.Errorf("Expected %s, got %s", test.expected, test.url)
+		}
+	}
+}
+
+func FuzzShortID(f *testing.F) {
+	for _, test := range shortIDs {
+		id, _ := strconv.ParseInt(test.id, 10, 64)
+		f.Add(id)
+	}
+
+	f.Fuzz(func(t *testing.T, id int64) {
+		if id < 0 {
+			t.Skip()
+		}
+		item := goinsta.Item{Pk: id, ID: strconv.FormatInt(id, 10) + "_1"}
+
+		code, err := goinsta.ShortIDFromMediaID(item.GetID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, err := goinsta.MediaIDFromShortID(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if back != strconv.FormatInt(id, 10) {
+			t.Fatalf("Round trip of %d returned %s", id, back)
+		}
+
+		// Generated links should parse back to the same media
+		r, err := goinsta.ParseURL(item.URL())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Type != goinsta.URLMedia || r.ID != back || r.Code != code {
+			t.Fatalf("Link %s parsed to %+v", item.URL(), r)
+		}
+	})
+}
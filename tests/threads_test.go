@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func TestNewGroup(t *testing.T) {
+	inbox, srv := directInbox(t)
+	users := []*goinsta.User{{ID: 2}, {ID: 3}}
+
+	if _, err := inbox.NewGroup(users[:1], "team"); err != goinsta.ErrGroupTooSmall {
+		t.Errorf("expected ErrGroupTooSmall, got %v", err)
+	}
+
+	srv.thread = `{"status": "ok", "thread_id": "500", "thread_title": "team", "is_group": true, "users": [{"pk": 2}, {"pk": 3}]}`
+	conv, err := inbox.NewGroup(users, "team")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, form := srv.last()
+	if form.Get("recipient_users") != `["2","3"]` || form.Get("thread_title") != "team" {
+		t.Errorf("unexpected create group request: %v", form)
+	}
+	if conv.ID != "500" || !conv.IsGroup || conv.Title != "team" || len(conv.Users) != 2 {
+		t.Errorf("unexpected group: %+v", conv)
+	}
+	if thread(t, inbox, "500") != conv {
+		t.Error("group was not added to the inbox")
+	}
+}
+
+func TestGroupManagement(t *testing.T) {
+	inbox, srv := directInbox(t)
+	conv := thread(t, inbox, "340")
+	user := &goinsta.User{ID: 3}
+
+	// Without a thread in the response, the changes are applied locally
+	tests := []struct {
+		name   string
+		action func() (*goinsta.Conversation, error)
+		path   string
+		param  string
+		check  func() bool
+	}{
+		{"add users", func() (*goinsta.Conversation, error) { return conv.AddUsers(user) }, "add_user", `["3"]`,
+			func() bool { return conv.IsGroup && len(conv.Users) == 2 }},
+		{"add admin", func() (*goinsta.Conversation, error) { return conv.AddAdmin(user) }, "add_admins", `["3"]`,
+			func() bool { return len(conv.AdminUserIDs) == 1 && conv.AdminUserIDs[0] == 3 }},
+		{"remove admin", func() (*goinsta.Conversation, error) { return conv.RemoveAdmin(user) }, "remove_admins", `["3"]`,
+			func() bool { return len(conv.AdminUserIDs) == 0 }},
+		{"remove user", func() (*goinsta.Conversation, error) { return conv.RemoveUser(user) }, "remove_users", `["3"]`,
+			func() bool { return len(conv.Users) == 1 && len(conv.LeftUsers) == 1 }},
+		{"rename", func() (*goinsta.Conversation, error) { return conv.Rename("friends") }, "update_title", "friends",
+			func() bool { return conv.Title == "friends" && conv.Named }},
+		{"approval", func() (*goinsta.Conversation, error) { return conv.SetApprovalRequired(true) }, "approval_required_for_new_members", "",
+			func() bool { return conv.ApprovalRequiredNewMembers }},
+		{"no approval", func() (*goinsta.Conversation, error) { return conv.SetApprovalRequired(false) }, "approval_not_required_for_new_members", "",
+			func() bool { return !conv.ApprovalRequiredNewMembers }},
+		{"mute", conv.Mute, "mute", "", func() bool { return conv.Muted }},
+		{"unmute", conv.Unmute, "unmute", "", func() bool { return !conv.Muted }},
+		{"archive", conv.Archive, "archive", "", func() bool { return conv.Archived }},
+		{"unarchive", conv.Unarchive, "unarchive", "", func() bool { return !conv.Archived }},
+		{"pin", conv.Pin, "pin", "", func() bool { return conv.IsPin }},
+		{"unpin", conv.Unpin, "unpin", "", func() bool { return !conv.IsPin }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := test.action()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c != conv {
+				t.Error("expected the conversation to be returned")
+			}
+			path, form := srv.last()
+			if path != "/api/v1/direct_v2/threads/340/"+test.path+"/" {
+				t.Errorf("unexpected request to %s", path)
+			}
+			if test.param != "" && form.Get("user_ids") != test.param && form.Get("title") != test.param {
+				t.Errorf("unexpected request parameters: %v", form)
+			}
+			if !test.check() {
+				t.Errorf("conversation not updated: %+v", conv)
+			}
+		})
+	}
+
+	// The thread state in the response replaces the conversation
+	srv.thread = `{"thread_id": "340", "thread_title": "renamed", "is_group": true, "admin_user_ids": [1], "users": [{"pk": 2}, {"pk": 4}]}`
+	if _, err := conv.Rename("ignored"); err != nil {
+		t.Fatal(err)
+	}
+	if conv.Title != "renamed" || len(conv.Users) != 2 || conv.Users[1].ID != 4 || len(conv.AdminUserIDs) != 1 {
+		t.Errorf("conversation not updated from the response: %+v", conv)
+	}
+
+	if _, err := conv.Leave(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range inbox.Conversations {
+		if c.ID == conv.ID {
+			t.Error("conversation still in the inbox after leaving")
+		}
+	}
+}
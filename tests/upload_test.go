@@ -2,11 +2,24 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Davincible/goinsta/v3"
 )
@@ -329,3 +342,537 @@ func TestUploadProfilePicture(t *testing.T) {
 	}
 	t.Log("Changed profile picture!")
 }
+
+// ruploadServer mimics the resumable upload endpoints on a stub server, to
+// test uploads offline.
+type ruploadServer struct {
+	*stubServer
+	received   []byte
+	offsets    []int
+	failAt     int
+	photos     [][]byte
+	configs    []map[string]interface{}
+	configURLs []string
+	// offsetBody replaces the response to offset requests if set
+	offsetBody string
+}
+
+// newRuploadServer returns an upload server that fails the video segment at
+// offset failAt once, use -1 to not fail any.
+func newRuploadServer(failAt int) *ruploadServer {
+	s := &ruploadServer{stubServer: &stubServer{}, failAt: failAt}
+	s.handle("rupload_igvideo", s.video)
+	s.handle("rupload_igphoto", s.photo)
+	s.handle("configure", s.configure)
+	return s
+}
+
+func (s *ruploadServer) video(req *http.Request, _ url.Values) (int, string, error) {
+	if req.Method == http.MethodGet {
+		if s.offsetBody != "" {
+			return http.StatusOK, s.offsetBody, nil
+		}
+		return http.StatusOK, `{"offset": ` + strconv.Itoa(len(s.received)) + `}`, nil
+	}
+	offset, _ := strconv.Atoi(req.Header.Get("Offset"))
+	if offset == s.failAt {
+		s.failAt = -1
+		return 0, "", errors.New("connection reset")
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	s.offsets = append(s.offsets, offset)
+	s.received = append(s.received[:offset], b...)
+	return http.StatusOK, `{"status": "ok"}`, nil
+}
+
+func (s *ruploadServer) photo(req *http.Request, _ url.Values) (int, string, error) {
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		return 0, "", err
+	}
+	s.photos = append(s.photos, b)
+	return http.StatusOK, `{"status": "ok"}`, nil
+}
+
+func (s *ruploadServer) configure(req *http.Request, form url.Values) (int, string, error) {
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(form.Get("signed_body"), "SIGNATURE.")), &config); err != nil {
+		return 0, "", err
+	}
+	s.configs = append(s.configs, config)
+	s.configURLs = append(s.configURLs, req.URL.RequestURI())
+	return http.StatusOK, `{"status": "ok", "media": {"pk": 42, "id": "42_1"}}`, nil
+}
+
+func fakeVideo(size int) []byte {
+	return testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", audio: "mp4a", audioType: 0x40, duration: 5000, size: size}.bytes()
+}
+
+func TestUploadProgress(t *testing.T) {
+	srv := newRuploadServer(2000)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+	insta.SetWarnHandler(t.Log)
+
+	video := fakeVideo(4500)
+	var progress []goinsta.UploadProgress
+	item, err := insta.Upload(&goinsta.UploadOptions{
+		File:        bytes.NewReader(video),
+		SegmentSize: 1000,
+		OnProgress: func(p goinsta.UploadProgress) {
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Pk != 42 {
+		t.Errorf("Expected the configured item, got %d", item.Pk)
+	}
+	if !bytes.Equal(srv.received, video) {
+		t.Fatal("Uploaded video does not match")
+	}
+
+	var sent []int64
+	for _, p := range progress {
+		if p.Phase == goinsta.UploadTransfer {
+			sent = append(sent, p.BytesSent)
+			if p.Segments != 5 || p.TotalBytes != 4500 {
+				t.Errorf("Unexpected progress %+v", p)
+			}
+		}
+	}
+	if expected := "[0 1000 2000 3000 4000 4500]"; fmt.Sprint(sent) != expected {
+		t.Errorf("Expected transfer progress %s, got %s", expected, fmt.Sprint(sent))
+	}
+	if last := progress[len(progress)-1]; last.Phase != goinsta.UploadConfigure {
+		t.Errorf("Expected the last phase to be configure, got %s", last.Phase)
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	// Cancel the upload after two segments
+	video := fakeVideo(4500)
+	ctx, cancel := context.WithCancel(context.Background())
+	var state goinsta.UploadState
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		File:        bytes.NewReader(video),
+		SegmentSize: 1000,
+		Context:     ctx,
+		OnProgress: func(p goinsta.UploadProgress) {
+			state = p.State
+			if p.BytesSent == 2000 {
+				cancel()
+			}
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the upload to be cancelled, got %v", err)
+	}
+	if state.Offset != 2000 || len(srv.received) != 2000 {
+		t.Fatalf("Expected the upload to stop at 2000 bytes, got %+v", state)
+	}
+
+	// Continue where the previous upload stopped
+	srv.offsets = nil
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:        bytes.NewReader(video),
+		SegmentSize: 1000,
+		Resume:      &state,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(srv.received, video) {
+		t.Fatal("Resumed video does not match")
+	}
+	if expected := "[2000 3000 4000]"; fmt.Sprint(srv.offsets) != expected {
+		t.Errorf("Expected segments at %s, got %s", expected, fmt.Sprint(srv.offsets))
+	}
+
+	// A malformed offset response fails the upload, instead of starting over
+	srv.offsets = nil
+	srv.offsetBody = "<html>"
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:        bytes.NewReader(video),
+		SegmentSize: 1000,
+		Resume:      &state,
+	})
+	if err == nil || len(srv.offsets) != 0 {
+		t.Errorf("Expected the malformed offset to fail the upload, got %v after %d segments", err, len(srv.offsets))
+	}
+}
+
+func TestUploadConform(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	photo := func(w, h int) io.Reader {
+		buf := new(bytes.Buffer)
+		if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+
+	tests := []struct {
+		name     string
+		options  goinsta.UploadOptions
+		expected image.Point
+	}{
+		{"crop wide", goinsta.UploadOptions{File: photo(2000, 500), Conform: goinsta.ConformCrop}, image.Pt(955, 500)},
+		{"pad tall", goinsta.UploadOptions{File: photo(400, 1000), Conform: goinsta.ConformPad}, image.Pt(800, 1000)},
+		{"downscale", goinsta.UploadOptions{File: photo(2880, 2880), Conform: goinsta.ConformCrop}, image.Pt(1440, 1440)},
+		{"story", goinsta.UploadOptions{File: photo(1000, 1000), Conform: goinsta.ConformPad, IsStory: true}, image.Pt(1000, 1778)},
+		{"untouched", goinsta.UploadOptions{File: photo(2000, 500)}, image.Pt(2000, 500)},
+	}
+	for _, test := range tests {
+		srv.photos = nil
+		if _, err := insta.Upload(&test.options); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(srv.photos) != 1 {
+			t.Fatalf("%s: expected one photo, got %d", test.name, len(srv.photos))
+		}
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(srv.photos[0]))
+		if err != nil || format != "jpeg" {
+			t.Fatalf("%s: expected a jpeg (%v)", test.name, err)
+		}
+		if size := image.Pt(cfg.Width, cfg.Height); size != test.expected {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, size)
+		}
+	}
+
+	// Padding uses the background color, and the gravity places the image
+	srv.photos = nil
+	src := image.NewGray(image.Rect(0, 0, 400, 1000))
+	buf := new(bytes.Buffer)
+	png.Encode(buf, src)
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		File:       buf,
+		Conform:    goinsta.ConformPad,
+		Gravity:    goinsta.GravityLeft,
+		Background: color.White,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(srv.photos[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(10, 500).RGBA(); r>>8 > 15 {
+		t.Errorf("Expected the image on the left, got %v", img.At(10, 500))
+	}
+	if r, _, _, _ := img.At(790, 500).RGBA(); r>>8 < 240 {
+		t.Errorf("Expected white padding on the right, got %v", img.At(790, 500))
+	}
+
+	// Videos can't be converted, and are rejected instead
+	video := testVideo{width: 1920, height: 1080, fps: 30, codec: "avc1", duration: 5000, size: 4500}
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:    bytes.NewReader(video.bytes()),
+		IsStory: true,
+		Conform: goinsta.ConformCrop,
+	})
+	var cerr goinsta.ConstraintError
+	if !errors.As(err, &cerr) || cerr.Constraint != "aspect ratio" {
+		t.Fatalf("Expected an aspect ratio error, got %v", err)
+	}
+	t.Log(err)
+}
+
+func TestUploadStickers(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	photo := new(bytes.Buffer)
+	if err := png.Encode(photo, image.NewGray(image.Rect(0, 0, 90, 160))); err != nil {
+		t.Fatal(err)
+	}
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		File:    bytes.NewReader(photo.Bytes()),
+		IsStory: true,
+		Stickers: []goinsta.StorySticker{
+			goinsta.MentionSticker{User: &goinsta.User{ID: 42}, StickerPosition: goinsta.StickerPosition{X: 0.2, Y: 0.3}},
+			goinsta.MentionSticker{User: &goinsta.User{ID: 43}},
+			goinsta.HashtagSticker{Tag: "#golang"},
+			goinsta.LinkSticker{URL: "https://example.com", Text: "Read more"},
+			goinsta.PollSticker{Question: "Tabs or spaces?", Options: []string{"Tabs", "Spaces"}},
+			goinsta.QuizSticker{Question: "2 + 2?", Options: []string{"3", "4", "5"}, Correct: 1},
+			goinsta.CountdownSticker{Text: "Launch", End: time.Now().Add(time.Hour)},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.configs) != 1 {
+		t.Fatalf("Expected one configure request, got %d", len(srv.configs))
+	}
+	config := srv.configs[0]
+
+	var mentions []map[string]interface{}
+	if err := json.Unmarshal([]byte(fmt.Sprint(config["reel_mentions"])), &mentions); err != nil {
+		t.Fatal(err)
+	}
+	if len(mentions) != 2 || mentions[0]["user_id"] != "42" || mentions[0]["x"] != 0.2 || mentions[1]["x"] != 0.5 {
+		t.Errorf("Unexpected mentions %v", mentions)
+	}
+	for _, key := range []string{"story_hashtags", "story_link_stickers", "story_polls", "story_quizs", "story_countdowns"} {
+		var list []map[string]interface{}
+		if err := json.Unmarshal([]byte(fmt.Sprint(config[key])), &list); err != nil || len(list) != 1 {
+			t.Errorf("Expected one sticker in %s, got %v", key, config[key])
+		}
+	}
+	expected := "mention_sticker,hashtag_sticker,link_sticker_default,polling_sticker_vibrant,quiz_story_sticker_default,countdown_sticker_time"
+	if config["story_sticker_ids"] != expected {
+		t.Errorf("Unexpected sticker ids %v", config["story_sticker_ids"])
+	}
+
+	// Invalid stickers are rejected before uploading
+	srv.photos = nil
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:     bytes.NewReader(photo.Bytes()),
+		IsStory:  true,
+		Stickers: []goinsta.StorySticker{goinsta.QuizSticker{Options: []string{"1", "2"}, Correct: 2}},
+	})
+	if !errors.Is(err, goinsta.ErrInvalidSticker) || len(srv.photos) != 0 {
+		t.Errorf("Expected ErrInvalidSticker before uploading, got %v", err)
+	}
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:     bytes.NewReader(photo.Bytes()),
+		Stickers: []goinsta.StorySticker{goinsta.HashtagSticker{Tag: "golang"}},
+	})
+	if !errors.Is(err, goinsta.ErrStickerNotStory) {
+		t.Errorf("Expected ErrStickerNotStory, got %v", err)
+	}
+}
+
+func TestUploadMixedCarousel(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	photo := func() io.Reader {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, 100, 100)), nil); err != nil {
+			t.Fatal(err)
+		}
+		return buf
+	}
+	video := fakeVideo(100000)
+
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		Album:           []io.Reader{photo(), bytes.NewReader(video), photo()},
+		AlbumThumbnails: []io.Reader{nil, photo()},
+		AlbumTags:       &[][]goinsta.UserTag{{{User: &goinsta.User{ID: 42}}}, {{User: &goinsta.User{ID: 43}}}},
+		AlbumAltText:    []string{"", "", "A gray square"},
+		UserTags:        &[]goinsta.UserTag{{User: &goinsta.User{ID: 44}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(srv.received, video) {
+		t.Errorf("Expected the video to be uploaded, got %d bytes", len(srv.received))
+	}
+	if len(srv.photos) != 3 {
+		t.Errorf("Expected two photos and a thumbnail to be uploaded, got %d", len(srv.photos))
+	}
+	if len(srv.configs) != 1 {
+		t.Fatalf("Expected one configure request, got %d", len(srv.configs))
+	}
+
+	children, _ := srv.configs[0]["children_metadata"].([]interface{})
+	if len(children) != 3 {
+		t.Fatalf("Expected 3 children, got %v", srv.configs[0]["children_metadata"])
+	}
+	for i, user := range []string{"42", "43", "44"} {
+		child := children[i].(map[string]interface{})
+		if tags := fmt.Sprint(child["usertags"]); !strings.Contains(tags, user) {
+			t.Errorf("Expected item %d to tag %s, got %s", i+1, user, tags)
+		}
+	}
+	if _, ok := children[1].(map[string]interface{})["length"]; !ok {
+		t.Errorf("Expected the second item to be configured as a video")
+	}
+	if alt := children[2].(map[string]interface{})["custom_accessibility_caption"]; alt != "A gray square" {
+		t.Errorf("Unexpected alt text %v", alt)
+	}
+
+	// The options of the items don't end up in the album options, also when
+	// the upload fails
+	for _, failAt := range []int{-1, 0} {
+		srv = newRuploadServer(failAt)
+		insta.SetHTTPTransport(srv)
+		tags := &[]goinsta.UserTag{{User: &goinsta.User{ID: 44}}}
+		o := &goinsta.UploadOptions{
+			Album:           []io.Reader{photo(), bytes.NewReader(video)},
+			AlbumThumbnails: []io.Reader{nil, photo()},
+			AlbumTags:       &[][]goinsta.UserTag{{{User: &goinsta.User{ID: 42}}}},
+			UserTags:        tags,
+			SegmentRetries:  -1,
+		}
+		if _, err := insta.Upload(o); (err != nil) != (failAt >= 0) {
+			t.Fatalf("Unexpected upload error %v", err)
+		}
+		if o.Thumbnail != nil || o.UserTags != tags {
+			t.Errorf("Expected the album thumbnail and user tags to be left unchanged")
+		}
+	}
+
+	// Invalid albums are rejected before uploading
+	srv = newRuploadServer(-1)
+	insta.SetHTTPTransport(srv)
+	album := make([]io.Reader, 11)
+	for i := range album {
+		album[i] = photo()
+	}
+	if _, err := insta.Upload(&goinsta.UploadOptions{Album: album}); !errors.Is(err, goinsta.ErrCarouselMediaLimit) {
+		t.Errorf("Expected ErrCarouselMediaLimit, got %v", err)
+	}
+
+	short := testVideo{width: 720, height: 900, fps: 30, codec: "avc1", duration: 2000, size: 5000}.bytes()
+	_, err = insta.Upload(&goinsta.UploadOptions{Album: []io.Reader{photo(), bytes.NewReader(short)}})
+	var cerr goinsta.ConstraintError
+	if !errors.As(err, &cerr) || cerr.Constraint != "duration" {
+		t.Errorf("Expected a duration constraint error, got %v", err)
+	}
+	if len(srv.photos) != 0 || len(srv.received) != 0 {
+		t.Errorf("Expected nothing to be uploaded")
+	}
+}
+
+func TestUploadKinds(t *testing.T) {
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+
+	video := func(seconds uint32) io.Reader {
+		v := testVideo{width: 1080, height: 1350, fps: 30, codec: "avc1", duration: seconds * 1000, size: 50000}
+		return bytes.NewReader(v.bytes())
+	}
+	tests := []struct {
+		name     string
+		options  *goinsta.UploadOptions
+		endpoint string
+		expected map[string]interface{}
+	}{
+		{
+			"default",
+			&goinsta.UploadOptions{File: video(5)},
+			"/api/v1/media/configure_to_clips/",
+			map[string]interface{}{"clips_share_preview_to_feed": "1", "poster_frame_index": 0.0},
+		},
+		{
+			"reel",
+			&goinsta.UploadOptions{File: video(5), Kind: goinsta.KindReel, CoverFrame: 2 * time.Second, DisableShareToFeed: true},
+			"/api/v1/media/configure_to_clips/",
+			map[string]interface{}{"clips_share_preview_to_feed": "0", "poster_frame_index": 60.0},
+		},
+		{
+			"feed video",
+			&goinsta.UploadOptions{File: video(5), Kind: goinsta.KindFeedVideo, Caption: "Hi"},
+			"/api/v1/media/configure/?video=1",
+			map[string]interface{}{"caption": "Hi"},
+		},
+		{
+			"igtv",
+			&goinsta.UploadOptions{
+				File:        video(90),
+				Kind:        goinsta.KindIGTV,
+				Title:       "Episode 1",
+				IGTVSeries:  &goinsta.IGTVChannel{ID: "series_123"},
+				PreviewCrop: &goinsta.PreviewCrop{Top: 0.1, Right: 1, Bottom: 0.9},
+			},
+			"/api/v1/media/configure_to_igtv/?video=1",
+			map[string]interface{}{
+				"title":                      "Episode 1",
+				"igtv_series_id":             "123",
+				"igtv_share_preview_to_feed": "1",
+				"feed_preview_crop":          map[string]interface{}{"crop_left": 0.0, "crop_top": 0.1, "crop_right": 1.0, "crop_bottom": 0.9},
+			},
+		},
+		{
+			"story",
+			&goinsta.UploadOptions{File: video(5), Kind: goinsta.KindStory},
+			"/api/v1/media/configure_to_story/?video=1",
+			map[string]interface{}{"configure_mode": "1"},
+		},
+	}
+	for _, test := range tests {
+		srv := newRuploadServer(-1)
+		insta.SetHTTPTransport(srv)
+		if _, err := insta.Upload(test.options); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if len(srv.configURLs) != 1 || srv.configURLs[0] != test.endpoint {
+			t.Errorf("%s: expected to configure at %s, got %v", test.name, test.endpoint, srv.configURLs)
+			continue
+		}
+		for k, v := range test.expected {
+			if got := srv.configs[0][k]; !reflect.DeepEqual(got, v) {
+				t.Errorf("%s: expected %s to be %v, got %v", test.name, k, v, got)
+			}
+		}
+	}
+
+	isDuration := func(err error) bool {
+		var cerr goinsta.ConstraintError
+		return errors.As(err, &cerr) && cerr.Constraint == "duration"
+	}
+	isCover := func(err error) bool {
+		var cerr goinsta.ConstraintError
+		return errors.As(err, &cerr) && cerr.Constraint == "cover frame offset"
+	}
+	photo := new(bytes.Buffer)
+	if err := jpeg.Encode(photo, image.NewGray(image.Rect(0, 0, 100, 100)), nil); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []struct {
+		name    string
+		options *goinsta.UploadOptions
+		check   func(error) bool
+	}{
+		{"long feed video", &goinsta.UploadOptions{File: video(61), Kind: goinsta.KindFeedVideo}, isDuration},
+		{"short igtv", &goinsta.UploadOptions{File: video(30), Kind: goinsta.KindIGTV, Title: "Short"}, isDuration},
+		{"long reel", &goinsta.UploadOptions{File: video(91)}, isDuration},
+		{"cover frame", &goinsta.UploadOptions{File: video(5), CoverFrame: 6 * time.Second}, isCover},
+		{"igtv without title", &goinsta.UploadOptions{File: video(90), Kind: goinsta.KindIGTV}, func(err error) bool {
+			return errors.Is(err, goinsta.ErrIGTVNoTitle)
+		}},
+		{"reel story", &goinsta.UploadOptions{File: video(5), Kind: goinsta.KindReel, IsStory: true}, func(err error) bool {
+			return errors.Is(err, goinsta.ErrUploadKind)
+		}},
+		{"photo reel", &goinsta.UploadOptions{File: bytes.NewReader(photo.Bytes()), Kind: goinsta.KindReel}, func(err error) bool {
+			return errors.Is(err, goinsta.ErrUploadKind)
+		}},
+		{"preview crop", &goinsta.UploadOptions{File: video(90), Kind: goinsta.KindIGTV, Title: "Crop", PreviewCrop: &goinsta.PreviewCrop{Right: 1.5, Bottom: 1}}, func(err error) bool {
+			return errors.Is(err, goinsta.ErrInvalidPreviewCrop)
+		}},
+	}
+	for _, test := range invalid {
+		srv := newRuploadServer(-1)
+		insta.SetHTTPTransport(srv)
+		_, err := insta.Upload(test.options)
+		if !test.check(err) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+		if len(srv.received) != 0 {
+			t.Errorf("%s: expected nothing to be uploaded", test.name)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+func jpegReader(t *testing.T, width, height int) io.Reader {
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf
+}
+
+func TestValidateUpload(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	album := []io.Reader{
+		jpegReader(t, 100, 100),
+		strings.NewReader("not an image"),
+		bytes.NewReader(testVideo{width: 1080, height: 1920, fps: 30, codec: "avc1", duration: 5000, size: 5000}.bytes()),
+		bytes.NewReader(testVideo{width: 720, height: 900, fps: 30, codec: "avc1", duration: 2000, size: 5000}.bytes()),
+		bytes.NewReader(testVideo{width: 3840, height: 2160, fps: 120, codec: "hvc1", duration: 5000, size: 5000}.bytes()),
+	}
+	for len(album) < 11 {
+		album = append(album, jpegReader(t, 100, 100))
+	}
+
+	o := &goinsta.UploadOptions{
+		Album:     append([]io.Reader{}, album...),
+		Caption:   strings.Repeat("#tag ", 31) + strings.Repeat("@user ", 21) + strings.Repeat("a", 2200),
+		AlbumTags: &[][]goinsta.UserTag{{{User: &goinsta.User{ID: 1}}}, {{}}},
+		Stickers:  []goinsta.StorySticker{goinsta.HashtagSticker{}},
+		Conform:   goinsta.ConformCrop,
+	}
+	violations := insta.ValidateUpload(o)
+
+	constraint := func(c string) func(error) bool {
+		return func(err error) bool {
+			var cerr goinsta.ConstraintError
+			return errors.As(err, &cerr) && cerr.Constraint == c
+		}
+	}
+	is := func(target error) func(error) bool {
+		return func(err error) bool { return errors.Is(err, target) }
+	}
+	expected := []struct {
+		field string
+		check func(error) bool
+	}{
+		{"Stickers", is(goinsta.ErrStickerNotStory)},
+		{"Stickers[0]", is(goinsta.ErrInvalidSticker)},
+		{"Caption", constraint("caption length")},
+		{"Caption", constraint("hashtags")},
+		{"Caption", constraint("mentions")},
+		{"AlbumTags[1]", is(goinsta.ErrInvalidUserTag)},
+		{"Album", is(goinsta.ErrCarouselMediaLimit)},
+		{"Album[1]", is(goinsta.ErrInvalidFormat)},
+		{"Album[2]", constraint("aspect ratio")},
+		{"Album[3]", constraint("duration")},
+		{"Album[4]", constraint("width")},
+		{"Album[4]", constraint("frame rate")},
+	}
+	if len(violations) != len(expected) {
+		t.Errorf("Expected %d violations, got %d: %v", len(expected), len(violations), violations)
+	}
+	for i, e := range expected {
+		if i >= len(violations) {
+			break
+		}
+		if v := violations[i]; v.Field != e.field || !e.check(v) {
+			t.Errorf("Expected violation %d to be of %s, got %v", i, e.field, v)
+		}
+	}
+	if len(srv.photos) != 0 || len(srv.received) != 0 || len(srv.configs) != 0 {
+		t.Errorf("Expected no requests to be made")
+	}
+	for i := range album {
+		if o.Album[i] != album[i] {
+			t.Errorf("Expected Album[%d] to be left unchanged", i)
+		}
+	}
+
+	// Upload enforces the caption and user tag limits too
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		File:    jpegReader(t, 100, 100),
+		Caption: strings.Repeat("#tag ", 31),
+	})
+	var cerr goinsta.ConstraintError
+	if !errors.As(err, &cerr) || cerr.Constraint != "hashtags" || len(srv.photos) != 0 {
+		t.Errorf("Expected the hashtags to be rejected before uploading, got %v", err)
+	}
+	_, err = insta.Upload(&goinsta.UploadOptions{
+		File:     jpegReader(t, 100, 100),
+		UserTags: &[]goinsta.UserTag{{}},
+	})
+	if !errors.Is(err, goinsta.ErrInvalidUserTag) || len(srv.photos) != 0 {
+		t.Errorf("Expected ErrInvalidUserTag before uploading, got %v", err)
+	}
+
+	// A valid upload can still be uploaded after validation, as seekable
+	// files are rewound
+	file := bytes.NewReader(fakeVideo(50000))
+	o = &goinsta.UploadOptions{
+		File:       file,
+		Thumbnail:  bytes.NewReader(jpegReader(t, 100, 100).(*bytes.Buffer).Bytes()),
+		Kind:       goinsta.KindReel,
+		CoverFrame: time.Second,
+		Caption:    "#valid @caption",
+	}
+	if violations := insta.ValidateUpload(o); violations != nil {
+		t.Fatalf("Expected no violations, got %v", violations)
+	}
+	if o.File != file || file.Len() != 50000 {
+		t.Errorf("Expected the file to be left unchanged, %d bytes are left", file.Len())
+	}
+	if _, err := insta.Upload(o); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.received) != 50000 || len(srv.photos) != 1 {
+		t.Errorf("Expected the video and thumbnail to be uploaded, got %d bytes and %d photos", len(srv.received), len(srv.photos))
+	}
+}
+
+func TestValidateUploadRatio(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	// ValidateUpload only reports the aspect ratio when Upload rejects it
+	tall := testVideo{width: 1080, height: 1920, fps: 30, codec: "avc1", duration: 5000, size: 5000}.bytes()
+	wide := testVideo{width: 1920, height: 1080, fps: 30, codec: "avc1", duration: 5000, size: 5000}.bytes()
+	for _, c := range []struct {
+		name     string
+		video    []byte
+		kind     goinsta.UploadKind
+		conform  goinsta.ConformMode
+		rejected bool
+	}{
+		{"feed video as is", tall, goinsta.KindFeedVideo, goinsta.ConformNone, false},
+		{"feed video with conform", tall, goinsta.KindFeedVideo, goinsta.ConformCrop, true},
+		{"story as is", wide, goinsta.KindStory, goinsta.ConformNone, false},
+		{"story with conform", wide, goinsta.KindStory, goinsta.ConformPad, true},
+	} {
+		options := func() *goinsta.UploadOptions {
+			return &goinsta.UploadOptions{
+				File:      bytes.NewReader(c.video),
+				Thumbnail: jpegReader(t, 100, 100),
+				Kind:      c.kind,
+				Conform:   c.conform,
+			}
+		}
+		violations := insta.ValidateUpload(options())
+		_, err := insta.Upload(options())
+
+		var cerr goinsta.ConstraintError
+		uploadRejected := errors.As(err, &cerr) && cerr.Constraint == "aspect ratio"
+		if !uploadRejected && err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		validateRejected := len(violations) == 1 && errors.As(violations[0], &cerr) && cerr.Constraint == "aspect ratio"
+		if !validateRejected && violations != nil {
+			t.Fatalf("%s: unexpected violations %v", c.name, violations)
+		}
+		if uploadRejected != c.rejected || validateRejected != c.rejected {
+			t.Errorf("%s: expected rejected to be %v, got %v from Upload and %v from ValidateUpload", c.name, c.rejected, uploadRejected, validateRejected)
+		}
+	}
+}
+
+func TestValidateUploadStoryDuration(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	story := func(duration uint32) io.Reader {
+		return bytes.NewReader(testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", duration: duration, size: 5000}.bytes())
+	}
+
+	// Single stories and the items of multi stories have the same limit
+	violations := insta.ValidateUpload(&goinsta.UploadOptions{File: story(30000), IsStory: true})
+	violations = append(violations, insta.ValidateUpload(&goinsta.UploadOptions{
+		Album:   []io.Reader{story(30000), story(59000)},
+		IsStory: true,
+	})...)
+	if violations != nil {
+		t.Errorf("Expected no violations, got %v", violations)
+	}
+
+	violations = insta.ValidateUpload(&goinsta.UploadOptions{File: story(70000), IsStory: true})
+	if len(violations) != 1 || !errors.Is(violations[0], goinsta.ErrStoryMediaTooLong) {
+		t.Errorf("Expected ErrStoryMediaTooLong, got %v", violations)
+	}
+	violations = insta.ValidateUpload(&goinsta.UploadOptions{
+		Album:   []io.Reader{story(30000), story(70000)},
+		IsStory: true,
+	})
+	if len(violations) != 1 || violations[0].Field != "Album[1]" || !errors.Is(violations[0], goinsta.ErrStoryMediaTooLong) {
+		t.Errorf("Expected ErrStoryMediaTooLong, got %v", violations)
+	}
+
+	_, err := insta.Upload(&goinsta.UploadOptions{
+		Album:   []io.Reader{story(30000), story(70000)},
+		IsStory: true,
+	})
+	if !errors.Is(err, goinsta.ErrStoryMediaTooLong) || len(srv.received) != 0 {
+		t.Errorf("Expected ErrStoryMediaTooLong before uploading, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// testVideo describes a minimal MP4 file, of which only the boxes are valid.
+type testVideo struct {
+	width, height int
+	rotation      int
+	fps           int
+	codec         string // sample entry type, e.g. avc1
+	audio         string // sample entry type, empty for no audio
+	audioType     byte   // esds object type of mp4a entries
+	duration      uint32 // in milliseconds
+	size          int    // size of the whole file
+}
+
+func (v testVideo) bytes() []byte {
+	u16 := func(n int) []byte {
+		return []byte{byte(n >> 8), byte(n)}
+	}
+	hdlr := func(typ string) []byte {
+		return box("hdlr", u32(0, 0), []byte(typ), make([]byte, 13))
+	}
+	mdhd := func(timescale, duration uint32) []byte {
+		return box("mdhd", u32(0, 0, 0, timescale, duration, 0))
+	}
+	tkhd := func(id uint32, matrix [4]int32, w, h int) []byte {
+		b := make([]byte, 84)
+		binary.BigEndian.PutUint32(b[12:], id)
+		for i, j := range []int{0, 1, 3, 4} {
+			binary.BigEndian.PutUint32(b[40+j*4:], uint32(matrix[i]))
+		}
+		binary.BigEndian.PutUint32(b[72:], 0x40000000)
+		binary.BigEndian.PutUint32(b[76:], uint32(w)<<16)
+		binary.BigEndian.PutUint32(b[80:], uint32(h)<<16)
+		return box("tkhd", b)
+	}
+	stbl := func(entry []byte, stts ...[]byte) []byte {
+		return box("minf", box("stbl", box("stsd", u32(0, 1), entry), bytes.Join(stts, nil)))
+	}
+
+	const one = 1 << 16
+	matrix := map[int][4]int32{
+		0:   {one, 0, 0, one},
+		90:  {0, one, -one, 0},
+		180: {-one, 0, 0, -one},
+		270: {0, -one, one, 0},
+	}[v.rotation]
+
+	// Coded size, before rotation
+	w, h := v.width, v.height
+	if v.rotation == 90 || v.rotation == 270 {
+		w, h = h, w
+	}
+	entry := append(make([]byte, 24), append(u16(w), u16(h)...)...)
+	entry = append(entry, make([]byte, 50)...)
+	switch v.codec {
+	case "avc1":
+		entry = append(entry, box("avcC", []byte{1, 100, 0, 31})...)
+	case "hvc1":
+		hvcc := make([]byte, 23)
+		hvcc[1], hvcc[12] = 1, 93
+		entry = append(entry, box("hvcC", hvcc)...)
+	}
+
+	timescale := uint32(v.fps * 1000)
+	frames := uint32(v.fps) * v.duration / 1000
+	moov := [][]byte{
+		box("mvhd", u32(0, 0, 0, 1000, v.duration), make([]byte, 80)),
+		box("trak",
+			tkhd(1, matrix, w, h),
+			box("mdia",
+				mdhd(timescale, frames*1000),
+				hdlr("vide"),
+				stbl(box(v.codec, entry), box("stts", u32(0, 1, frames, 1000))),
+			),
+		),
+	}
+	if v.audio != "" {
+		sound := append(make([]byte, 16), u16(2)...)
+		sound = append(sound, make([]byte, 6)...)
+		sound = append(sound, u32(44100<<16)...)
+		esds := box("esds", u32(0), []byte{0x03, 0x0f, 0, 1, 0, 0x04, 0x0a, v.audioType}, make([]byte, 9))
+		moov = append(moov, box("trak",
+			tkhd(2, [4]int32{one, 0, 0, one}, 0, 0),
+			box("mdia", mdhd(44100, 44100*v.duration/1000), hdlr("soun"), stbl(box(v.audio, sound, esds))),
+		))
+	}
+
+	out := box("ftyp", []byte("isom"), u32(512), []byte("isommp41"))
+	out = append(out, box("moov", moov...)...)
+	if pad := v.size - len(out) - 8; pad > 0 {
+		out = append(out, box("mdat", make([]byte, pad))...)
+	}
+	return out
+}
+
+func TestInspectVideo(t *testing.T) {
+	tests := []struct {
+		name     string
+		video    testVideo
+		expected goinsta.VideoInfo
+	}{
+		{
+			"h264",
+			testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", audio: "mp4a", audioType: 0x40, duration: 10000, size: 2500000},
+			goinsta.VideoInfo{
+				Brand: "isom", Codec: "h264", Profile: "High", Level: 3.1, Width: 720, Height: 1280,
+				FrameRate: 30, Duration: 10 * time.Second, Bitrate: 2000000, Size: 2500000,
+				HasAudio: true, AudioCodec: "aac", AudioChannels: 2, AudioSampleRate: 44100, Tracks: 2,
+			},
+		},
+		{
+			"rotated hevc",
+			testVideo{width: 1080, height: 1920, rotation: 90, fps: 60, codec: "hvc1", duration: 2000, size: 1000000},
+			goinsta.VideoInfo{
+				Brand: "isom", Codec: "hevc", Profile: "Main", Level: 3.1, Width: 1080, Height: 1920, Rotation: 90,
+				FrameRate: 60, Duration: 2 * time.Second, Bitrate: 4000000, Size: 1000000, Tracks: 1,
+			},
+		},
+		{
+			"mp3",
+			testVideo{width: 640, height: 640, rotation: 180, fps: 25, codec: "avc1", audio: "mp4a", audioType: 0x6b, duration: 4000, size: 100000},
+			goinsta.VideoInfo{
+				Brand: "isom", Codec: "h264", Profile: "High", Level: 3.1, Width: 640, Height: 640, Rotation: 180,
+				FrameRate: 25, Duration: 4 * time.Second, Bitrate: 200000, Size: 100000,
+				HasAudio: true, AudioCodec: "mp3", AudioChannels: 2, AudioSampleRate: 44100, Tracks: 2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		b := test.video.bytes()
+		info, err := goinsta.InspectVideo(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if *info != test.expected {
+			t.Errorf("%s:\nexpected %+v\ngot      %+v", test.name, test.expected, *info)
+		}
+
+		// Without io.Seeker the media data is read and discarded
+		info, err = goinsta.InspectVideo(io.MultiReader(bytes.NewReader(b)))
+		if err != nil || *info != test.expected {
+			t.Errorf("%s: reading without seeking returned %+v (%v)", test.name, info, err)
+		}
+	}
+
+	video := testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", duration: 1000, size: 5000}.bytes()
+	if _, err := goinsta.InspectVideo(bytes.NewReader(video[:len(video)-10])); !errors.Is(err, goinsta.ErrInvalidMP4) {
+		t.Errorf("Expected ErrInvalidMP4 for a truncated file, got %v", err)
+	}
+	if _, err := goinsta.InspectVideo(bytes.NewReader(box("ftyp", []byte("isom")))); !errors.Is(err, goinsta.ErrInvalidMP4) {
+		t.Errorf("Expected ErrInvalidMP4 without moov, got %v", err)
+	}
+}
+
+func TestUploadRejectsVideo(t *testing.T) {
+	srv := newRuploadServer(-1)
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+
+	tests := []struct {
+		name  string
+		video testVideo
+		check func(err error) bool
+	}{
+		{
+			"vp9",
+			testVideo{width: 720, height: 1280, fps: 30, codec: "vp09", duration: 5000, size: 5000},
+			func(err error) bool { return errors.Is(err, goinsta.ErrUnsupportedCodec) },
+		},
+		{
+			"opus",
+			testVideo{width: 720, height: 1280, fps: 30, codec: "avc1", audio: "Opus", duration: 5000, size: 5000},
+			func(err error) bool { return errors.Is(err, goinsta.ErrUnsupportedCodec) },
+		},
+		{
+			"120 fps",
+			testVideo{width: 720, height: 1280, fps: 120, codec: "avc1", duration: 5000, size: 5000},
+			func(err error) bool {
+				var cerr goinsta.ConstraintError
+				return errors.As(err, &cerr) && cerr.Constraint == "frame rate"
+			},
+		},
+		{
+			"4k",
+			testVideo{width: 3840, height: 2160, fps: 30, codec: "hvc1", duration: 5000, size: 5000},
+			func(err error) bool {
+				var cerr goinsta.ConstraintError
+				return errors.As(err, &cerr) && cerr.Constraint == "width"
+			},
+		},
+	}
+	for _, test := range tests {
+		_, err := insta.Upload(&goinsta.UploadOptions{File: bytes.NewReader(test.video.bytes())})
+		if !test.check(err) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+	if len(srv.received) != 0 {
+		t.Errorf("Expected no bytes to be transferred, got %d", len(srv.received))
+	}
+}
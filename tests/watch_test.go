@@ -0,0 +1,241 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Davincible/goinsta/v3"
+)
+
+// watchServer answers the inbox, pending inbox and thread requests with the
+// responses currently set. Older pages of threads are set by thread ID and
+// cursor, e.g. "340/3". Requests for the failing thread get a server error.
+type watchServer struct {
+	*stubServer
+	inbox   string
+	pending string
+	threads map[string]string
+	failing string
+}
+
+func newWatchServer() *watchServer {
+	s := &watchServer{stubServer: &stubServer{}}
+	s.handle("direct_v2/inbox/", func(*http.Request, url.Values) (int, string, error) {
+		return http.StatusOK, `{"inbox": {"threads": [` + s.inbox + `]}, "status": "ok"}`, nil
+	})
+	s.handle("direct_v2/pending_inbox/", func(*http.Request, url.Values) (int, string, error) {
+		return http.StatusOK, `{"inbox": {"threads": [` + s.pending + `]}, "status": "ok"}`, nil
+	})
+	s.handle("direct_v2/threads/", s.thread)
+	return s
+}
+
+func (s *watchServer) set(inbox, pending string, threads map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inbox, s.pending, s.threads = inbox, pending, threads
+}
+
+func (s *watchServer) thread(req *http.Request, form url.Values) (int, string, error) {
+	path := req.URL.Path
+	id := strings.TrimSuffix(path[strings.Index(path, "threads/")+len("threads/"):], "/")
+	if id == s.failing {
+		return http.StatusInternalServerError, `{"status": "fail"}`, nil
+	}
+	if cursor := form.Get("cursor"); cursor != "" {
+		id += "/" + cursor
+	}
+	if thread, ok := s.threads[id]; ok {
+		return http.StatusOK, `{"thread": ` + thread + `, "status": "ok"}`, nil
+	}
+	return http.StatusOK, `{"status": "ok"}`, nil
+}
+
+func watchInsta(srv *watchServer) *goinsta.Instagram {
+	insta := goinsta.New("", "")
+	insta.Account = &goinsta.Account{ID: 1}
+	insta.SetHTTPTransport(srv)
+	return insta
+}
+
+func TestInboxWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watch.json")
+	srv := newWatchServer()
+	srv.set(
+		`{"thread_id": "340", "last_activity_at": 100, "users": [{"pk": 2}], "items": [{"item_id": "1", "user_id": 2, "item_type": "text", "text": "hi", "timestamp": 100}], "last_seen_at": {"2": {"item_id": "1", "timestamp": "100"}}},
+		{"thread_id": "342", "last_activity_at": 50, "is_group": true, "users": [{"pk": 3}, {"pk": 4}]}`,
+		``,
+		nil,
+	)
+
+	// The first run only records the state of the inbox
+	state, err := goinsta.LoadWatchState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := watchInsta(srv).Inbox.Watch(ctx, 5*time.Millisecond, state)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if err := state.Save(path); err != nil {
+			t.Fatal(err)
+		}
+		if saved, err := goinsta.LoadWatchState(path); err != nil {
+			t.Fatal(err)
+		} else if saved.Updated != 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the first poll")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	for ev := range events {
+		t.Errorf("unexpected event on the first run: %#v", ev)
+	}
+
+	// After a restart, only what changed in the meantime is received
+	srv.set(
+		`{"thread_id": "340", "last_activity_at": 200, "users": [{"pk": 2}], "items": [{"item_id": "3", "user_id": 2, "item_type": "text", "text": "third", "timestamp": 200}], "last_seen_at": {"1": {"item_id": "3", "timestamp": "200"}, "2": {"item_id": "3", "timestamp": "200"}}},
+		{"thread_id": "341", "last_activity_at": 150, "users": [{"pk": 5}], "items": [{"item_id": "5", "user_id": 5, "item_type": "text", "text": "hello", "timestamp": 150}]},
+		{"thread_id": "342", "last_activity_at": 50, "is_group": true, "users": [{"pk": 3}], "left_users": [{"pk": 4}]}`,
+		`{"thread_id": "350", "pending": true, "users": [{"pk": 6}]}`,
+		map[string]string{
+			"340": `{"thread_id": "340", "last_activity_at": 200, "users": [{"pk": 2}], "has_older": true, "items": [` +
+				`{"item_id": "3", "user_id": 2, "item_type": "text", "text": "third", "timestamp": 200}], ` +
+				`"last_seen_at": {"1": {"item_id": "3", "timestamp": "200"}, "2": {"item_id": "3", "timestamp": "200"}}}`,
+			// The message sent in between is on the next page
+			"340/3": `{"thread_id": "340", "last_activity_at": 200, "users": [{"pk": 2}], "has_older": true, "items": [` +
+				`{"item_id": "2", "user_id": 2, "item_type": "text", "text": "second", "timestamp": 150}, ` +
+				`{"item_id": "1", "user_id": 2, "item_type": "text", "text": "hi", "timestamp": 100}], ` +
+				`"last_seen_at": {"1": {"item_id": "3", "timestamp": "200"}, "2": {"item_id": "3", "timestamp": "200"}}}`,
+		},
+	)
+	state, err = goinsta.LoadWatchState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	events = watchInsta(srv).Inbox.Watch(ctx, 5*time.Millisecond, state)
+
+	var messages []string
+	var seen, left, threads, pending int
+	for i := 0; i < 7; i++ {
+		select {
+		case ev := <-events:
+			switch ev := ev.(type) {
+			case *goinsta.MessageEvent:
+				messages = append(messages, ev.ThreadID+"/"+ev.Item.ID)
+			case *goinsta.SeenEvent:
+				seen++
+				if ev.ThreadID != "340" || ev.UserID != 2 || ev.ItemID != "3" || ev.Timestamp != 200 {
+					t.Errorf("unexpected seen event: %#v", ev)
+				}
+			case *goinsta.UserLeftEvent:
+				left++
+				if ev.ThreadID != "342" || ev.User.ID != 4 {
+					t.Errorf("unexpected user left event: %#v", ev)
+				}
+			case *goinsta.NewThreadEvent:
+				threads++
+				if ev.Thread.ID != "341" {
+					t.Errorf("unexpected new thread event: %#v", ev)
+				}
+			case *goinsta.PendingRequestEvent:
+				pending++
+				if ev.Thread.ID != "350" {
+					t.Errorf("unexpected pending request event: %#v", ev)
+				}
+			default:
+				t.Errorf("unexpected event: %#v", ev)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for events, got %d", i)
+		}
+	}
+
+	if got := strings.Join(messages, ","); !strings.Contains(got, "340/2,340/3") || !strings.Contains(got, "341/5") || len(messages) != 3 {
+		t.Errorf("unexpected messages %s", got)
+	}
+	if seen != 1 || left != 1 || threads != 1 || pending != 1 {
+		t.Errorf("unexpected events: %d seen, %d left, %d new threads, %d pending", seen, left, threads, pending)
+	}
+
+	// Later polls don't repeat events
+	select {
+	case ev := <-events:
+		t.Errorf("unexpected event: %#v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestInboxWatchThreadError(t *testing.T) {
+	srv := newWatchServer()
+	srv.set(
+		`{"thread_id": "340", "last_activity_at": 200, "users": [{"pk": 2}], "items": [{"item_id": "2", "user_id": 2, "item_type": "text", "text": "hi", "timestamp": 200}]},
+		{"thread_id": "341", "last_activity_at": 200, "users": [{"pk": 3}], "items": [{"item_id": "4", "user_id": 3, "item_type": "text", "text": "hello", "timestamp": 200}]}`,
+		``,
+		map[string]string{
+			"340": `{"thread_id": "340", "last_activity_at": 200, "users": [{"pk": 2}], "items": [{"item_id": "2", "user_id": 2, "item_type": "text", "text": "hi", "timestamp": 200}]}`,
+			"341": `{"thread_id": "341", "last_activity_at": 200, "users": [{"pk": 3}], "items": [{"item_id": "4", "user_id": 3, "item_type": "text", "text": "hello", "timestamp": 200}]}`,
+		},
+	)
+	srv.failing = "340"
+	state := &goinsta.WatchState{
+		Threads: map[string]*goinsta.WatchThread{
+			"340": {LastItemID: "1", LastTimestamp: 100, LastActivityAt: 100},
+			"341": {LastItemID: "3", LastTimestamp: 100, LastActivityAt: 100},
+		},
+		Updated: 1,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	insta := watchInsta(srv)
+	insta.SetWarnHandler(func(...interface{}) {})
+	events := insta.Inbox.Watch(ctx, 5*time.Millisecond, state)
+	next := func() string {
+		select {
+		case ev := <-events:
+			if msg, ok := ev.(*goinsta.MessageEvent); ok {
+				return msg.ThreadID + "/" + msg.Item.ID
+			}
+			t.Fatalf("unexpected event: %#v", ev)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for an event")
+		}
+		return ""
+	}
+
+	// The other thread is delivered, while the failing one is left as is
+	if got := next(); got != "341/4" {
+		t.Fatalf("Expected message 341/4, got %s", got)
+	}
+	srv.mu.Lock()
+	srv.failing = ""
+	srv.mu.Unlock()
+	if got := next(); got != "340/2" {
+		t.Errorf("Expected message 340/2 once the thread loads, got %s", got)
+	}
+}
+
+func TestInboxWatchInterval(t *testing.T) {
+	srv := newWatchServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	events := watchInsta(srv).Inbox.Watch(ctx, 0)
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for range events {
+	}
+
+	if polls, _ := srv.requests("direct_v2/inbox/"); len(polls) > 1 {
+		t.Errorf("expected a single poll without an interval, got %d polls", len(polls))
+	}
+}
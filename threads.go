@@ -0,0 +1,278 @@
+package goinsta
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// NewGroup creates a group conversation with at least two other users. The
+// new conversation is added to the inbox.
+func (inbox *Inbox) NewGroup(users []*User, title string) (*Conversation, error) {
+	insta := inbox.insta
+	if len(users) < 2 {
+		return nil, ErrGroupTooSmall
+	}
+	ids, err := userIDs(users...)
+	if err != nil {
+		return nil, err
+	}
+
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: urlInboxCreateGroup,
+			IsPost:   true,
+			Query: map[string]string{
+				"recipient_users": ids,
+				"thread_title":    title,
+				"_uuid":           insta.uuid,
+			},
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The thread is returned at the top level of the response
+	conv := &Conversation{}
+	if err := json.Unmarshal(body, conv); err != nil {
+		return nil, err
+	}
+	if conv.ID == "" {
+		return nil, fmt.Errorf("failed to create group, no thread returned")
+	}
+	inbox.updateConv(conv)
+	return conv, nil
+}
+
+// AddUsers adds users to the conversation. Adding users to a private
+// conversation turns it into a group.
+func (c *Conversation) AddUsers(users ...*User) (*Conversation, error) {
+	ids, err := userIDs(users...)
+	if err != nil {
+		return nil, err
+	}
+	return c.threadAction(
+		fmt.Sprintf(urlInboxAddUsers, c.ID),
+		map[string]string{"user_ids": ids},
+		func() {
+			c.Users = append(c.Users, users...)
+			c.IsGroup = true
+		},
+	)
+}
+
+// RemoveUser removes a user from the group, you need to be an admin of the
+// group to do so.
+func (c *Conversation) RemoveUser(user *User) (*Conversation, error) {
+	ids, err := userIDs(user)
+	if err != nil {
+		return nil, err
+	}
+	return c.threadAction(
+		fmt.Sprintf(urlInboxRemoveUsers, c.ID),
+		map[string]string{"user_ids": ids},
+		func() {
+			for i, u := range c.Users {
+				if u.ID == user.ID {
+					c.Users = append(c.Users[:i], c.Users[i+1:]...)
+					c.LeftUsers = append(c.LeftUsers, u)
+					break
+				}
+			}
+		},
+	)
+}
+
+// Rename sets the title of the conversation.
+func (c *Conversation) Rename(title string) (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxUpdateTitle, c.ID),
+		map[string]string{"title": title},
+		func() {
+			c.Title = title
+			c.Named = title != ""
+		},
+	)
+}
+
+// Leave leaves the group, which is then removed from the inbox.
+func (c *Conversation) Leave() (*Conversation, error) {
+	if _, err := c.threadAction(fmt.Sprintf(urlInboxLeave, c.ID), nil, nil); err != nil {
+		return nil, err
+	}
+
+	inbox := c.insta.Inbox
+	for i, conv := range inbox.Conversations {
+		if conv.ID == c.ID {
+			inbox.Conversations = append(inbox.Conversations[:i], inbox.Conversations[i+1:]...)
+			break
+		}
+	}
+	return c, nil
+}
+
+// AddAdmin makes a user an admin of the group.
+func (c *Conversation) AddAdmin(user *User) (*Conversation, error) {
+	ids, err := userIDs(user)
+	if err != nil {
+		return nil, err
+	}
+	return c.threadAction(
+		fmt.Sprintf(urlInboxAddAdmins, c.ID),
+		map[string]string{"user_ids": ids},
+		func() {
+			c.removeAdmin(user.ID)
+			c.AdminUserIDs = append(c.AdminUserIDs, user.ID)
+		},
+	)
+}
+
+// RemoveAdmin revokes the admin rights of a user in the group.
+func (c *Conversation) RemoveAdmin(user *User) (*Conversation, error) {
+	ids, err := userIDs(user)
+	if err != nil {
+		return nil, err
+	}
+	return c.threadAction(
+		fmt.Sprintf(urlInboxRemoveAdmins, c.ID),
+		map[string]string{"user_ids": ids},
+		func() {
+			c.removeAdmin(user.ID)
+		},
+	)
+}
+
+// SetApprovalRequired sets whether an admin has to approve new members of the
+// group.
+func (c *Conversation) SetApprovalRequired(required bool) (*Conversation, error) {
+	endpoint := urlInboxApprovalOff
+	if required {
+		endpoint = urlInboxApprovalOn
+	}
+	return c.threadAction(
+		fmt.Sprintf(endpoint, c.ID),
+		nil,
+		func() {
+			c.ApprovalRequiredNewMembers = required
+		},
+	)
+}
+
+// Mute mutes the notifications of the conversation.
+func (c *Conversation) Mute() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxMute, c.ID),
+		nil,
+		func() {
+			c.Muted = true
+		},
+	)
+}
+
+// Unmute unmutes the notifications of the conversation.
+func (c *Conversation) Unmute() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxUnmute, c.ID),
+		nil,
+		func() {
+			c.Muted = false
+		},
+	)
+}
+
+// Archive moves the conversation to the archive.
+func (c *Conversation) Archive() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxArchive, c.ID),
+		nil,
+		func() {
+			c.Archived = true
+		},
+	)
+}
+
+// Unarchive moves the conversation back to the inbox.
+func (c *Conversation) Unarchive() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxUnarchive, c.ID),
+		nil,
+		func() {
+			c.Archived = false
+		},
+	)
+}
+
+// Pin pins the conversation to the top of the inbox.
+func (c *Conversation) Pin() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxPin, c.ID),
+		nil,
+		func() {
+			c.IsPin = true
+		},
+	)
+}
+
+// Unpin unpins the conversation.
+func (c *Conversation) Unpin() (*Conversation, error) {
+	return c.threadAction(
+		fmt.Sprintf(urlInboxUnpin, c.ID),
+		nil,
+		func() {
+			c.IsPin = false
+		},
+	)
+}
+
+// threadAction posts a thread update, and updates the conversation with the
+// thread state in the response. If the response has no thread, the change is
+// applied locally instead. The conversation itself is returned.
+func (c *Conversation) threadAction(endpoint string, query map[string]string, apply func()) (*Conversation, error) {
+	insta := c.insta
+	body, _, err := insta.sendRequest(
+		&reqOptions{
+			Endpoint: endpoint,
+			IsPost:   true,
+			Query:    MergeMapS(map[string]string{"_uuid": insta.uuid}, query),
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp threadResp
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Status != "" && resp.Status != "ok" {
+		return nil, fmt.Errorf("failed to update thread with status: %s", resp.Status)
+	}
+
+	if resp.Conversation != nil {
+		isPending := c.isPending
+		c.update(resp.Conversation)
+		c.isPending = isPending
+	} else if apply != nil {
+		apply()
+	}
+	return c, nil
+}
+
+func (c *Conversation) removeAdmin(id int64) {
+	for i, admin := range c.AdminUserIDs {
+		if admin == id {
+			c.AdminUserIDs = append(c.AdminUserIDs[:i], c.AdminUserIDs[i+1:]...)
+			return
+		}
+	}
+}
+
+// userIDs formats the IDs of users as a JSON list of strings.
+func userIDs(users ...*User) (string, error) {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, toString(u.ID))
+	}
+	b, err := json.Marshal(ids)
+	return string(b), err
+}
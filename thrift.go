@@ -0,0 +1,140 @@
+package goinsta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// Thrift compact protocol types
+const (
+	thriftStop      byte = 0
+	thriftTrue      byte = 1
+	thriftFalse     byte = 2
+	thriftByte      byte = 3
+	thriftI32       byte = 5
+	thriftI64       byte = 6
+	thriftBinary    byte = 8
+	thriftList      byte = 9
+	thriftMap       byte = 11
+	thriftStruct    byte = 12
+	thriftMaxDelta       = 15
+	thriftShortList      = 15
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which the
+// realtime connect payload is encoded with. Only the types used by Instagram
+// are supported.
+type thriftWriter struct {
+	buf bytes.Buffer
+	// field ID of the last field of the current struct, and of the structs
+	// it is nested in
+	last  int16
+	stack []int16
+}
+
+func (w *thriftWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= thriftMaxDelta {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.last = id
+}
+
+// varint writes a zigzag encoded integer.
+func (w *thriftWriter) varint(n int64) {
+	w.uvarint(uint64(n<<1) ^ uint64(n>>63))
+}
+
+func (w *thriftWriter) uvarint(n uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	w.buf.Write(b[:binary.PutUvarint(b, n)])
+}
+
+func (w *thriftWriter) str(s string) {
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *thriftWriter) Binary(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.str(s)
+}
+
+func (w *thriftWriter) Bool(id int16, b bool) {
+	if b {
+		w.field(id, thriftTrue)
+	} else {
+		w.field(id, thriftFalse)
+	}
+}
+
+func (w *thriftWriter) Byte(id int16, b byte) {
+	w.field(id, thriftByte)
+	w.buf.WriteByte(b)
+}
+
+func (w *thriftWriter) I32(id int16, n int32) {
+	w.field(id, thriftI32)
+	w.varint(int64(n))
+}
+
+func (w *thriftWriter) I64(id int16, n int64) {
+	w.field(id, thriftI64)
+	w.varint(n)
+}
+
+func (w *thriftWriter) ListI32(id int16, l []int32) {
+	w.field(id, thriftList)
+	if len(l) < thriftShortList {
+		w.buf.WriteByte(byte(len(l))<<4 | thriftI32)
+	} else {
+		w.buf.WriteByte(0xf0 | thriftI32)
+		w.uvarint(uint64(len(l)))
+	}
+	for _, n := range l {
+		w.varint(int64(n))
+	}
+}
+
+// MapBinary writes a map of strings, sorted by key.
+func (w *thriftWriter) MapBinary(id int16, m map[string]string) {
+	w.field(id, thriftMap)
+	w.uvarint(uint64(len(m)))
+	if len(m) == 0 {
+		return
+	}
+	w.buf.WriteByte(thriftBinary<<4 | thriftBinary)
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		w.str(k)
+		w.str(m[k])
+	}
+}
+
+// Struct starts a nested struct, which is ended with Stop.
+func (w *thriftWriter) Struct(id int16) {
+	w.field(id, thriftStruct)
+	w.stack = append(w.stack, w.last)
+	w.last = 0
+}
+
+// Stop ends the current struct.
+func (w *thriftWriter) Stop() {
+	w.buf.WriteByte(thriftStop)
+	if n := len(w.stack); n > 0 {
+		w.last = w.stack[n-1]
+		w.stack = w.stack[:n-1]
+	}
+}
@@ -2,9 +2,11 @@ package goinsta
 
 import (
 	"bytes"
+	"context"
 	cryptRand "crypto/rand"
 	"encoding/json"
 	"fmt"
+	"image/color"
 	"io"
 	"math"
 	"math/rand"
@@ -19,31 +21,106 @@ import (
 type UploadOptions struct {
 	insta *Instagram
 
-	// File to upload, can be one of jpeg, jpg, mp4
+	// File to upload, can be one of jpeg, jpg, png, webp, gif, heic, mp4.
+	//   Images other than jpeg are converted to jpeg before uploading, heic
+	//   needs the github.com/Davincible/goinsta/v3/heic package imported
 	File io.Reader
-	// Thumbnail to use for videos, one of jpeg or jpg. If not set a thumbnail
-	//   will be extracted automatically
+	// Thumbnail to use for videos, which is also the cover of reels and IGTV
+	//   videos. If not set a thumbnail will be extracted automatically, at
+	//   CoverFrame
 	Thumbnail io.Reader
-	// Multiple images, to post a carousel or multiple stories at once
+	// Multiple images or videos, to post a carousel of up to 10 items, or
+	//   multiple story videos at once. Carousel videos need to be between 3
+	//   and 60 seconds long.
 	Album []io.Reader
+	// Thumbnails to use for the videos in a carousel, by index. A nil entry,
+	//   or an entry for an image, is ignored.
+	AlbumThumbnails []io.Reader
 	// Caption text for posts
 	Caption string
 	// Set to true if you want to post a story
 	IsStory bool
+	// Kind of post to upload a video as, defaults to a reel, or a story if
+	//   IsStory is set. Images are posted to the feed, or a story.
+	Kind UploadKind
+	// CoverFrame is the offset in the video of the frame to use as the cover
+	//   of a reel or IGTV video, if no Thumbnail is provided
+	CoverFrame time.Duration
+	// Title of an IGTV video, required for IGTV uploads
+	Title string
+	// IGTVSeries to add an IGTV video to, see User.IGTVSeries
+	IGTVSeries *IGTVChannel
+	// PreviewCrop is the part of an IGTV video shown in the feed preview
+	PreviewCrop *PreviewCrop
+	// Audio is a track to add to a reel, see Instagram.SearchMusic. To add
+	//   music to a story, use a MusicSticker.
+	Audio *AudioTrack
+	// AudioStart is the offset in the track to start playing from, defaults
+	//   to the first highlight of the track
+	AudioStart time.Duration
+	// AudioMix balances the video's own audio against the track, from -1,
+	//   only the track is heard, to 1, only the video's audio is heard. At
+	//   0 both play at full volume
+	AudioMix float64
+	// OriginalAudioName is the name of a reel's own audio, as shown when
+	//   others use it
+	OriginalAudioName string
+	// Interactive stickers to add to a story, such as mentions, polls and
+	//   links. When uploading multiple stories at once, they are added to
+	//   every story.
+	Stickers []StorySticker
 	// Option flags, set to true disable
 	MuteAudio            bool
 	DisableComments      bool
 	DisableLikeViewCount bool
 	DisableSubtitles     bool
+	DisableShareToFeed   bool // reels and IGTV videos only
 
-	// Used to tag users in posts
+	// Used to tag users in posts. AlbumTags are the tags of the carousel
+	//   items by index, items without an entry are tagged with UserTags.
 	UserTags  *[]UserTag
 	AlbumTags *[][]UserTag
 
+	// Alt text describing the post for screen readers, and the alt text of
+	//   the carousel items by index
+	AltText      string
+	AlbumAltText []string
+
 	// Used to provide a location for a post
 	Location     *LocationTag
 	locationJSON string
 
+	// JPEGQuality is used when converting PNG, WebP, GIF and HEIC images to
+	//   JPEG, from 1 to 100. Defaults to 95
+	JPEGQuality int
+	// Background is the color transparent parts of images are filled with,
+	//   as well as the padding added by ConformPad. Defaults to white
+	Background color.Color
+	// Conform crops or pads images to the closest aspect ratio Instagram
+	//   allows, 4:5 to 1.91:1 for posts and 9:16 for stories, and downscales
+	//   images wider than 1440px. Videos can't be converted, they are
+	//   checked against the same limits instead.
+	Conform ConformMode
+	// Gravity is the part of the image kept when cropping, or where the
+	//   image is placed when padding. Defaults to the center
+	Gravity Gravity
+
+	// Context can be used to cancel the upload. Defaults to context.Background()
+	Context context.Context
+	// OnProgress is called after every transferred segment, and when the
+	//   upload moves on to the processing and configure phases
+	OnProgress func(UploadProgress)
+	// SegmentSize is the size in bytes of the chunks videos are uploaded in.
+	//   Defaults to 4 MB
+	SegmentSize int
+	// SegmentRetries is the number of times a failed segment is retried.
+	//   Defaults to 3, set to -1 to disable retries
+	SegmentRetries int
+	// Resume continues an interrupted video upload, e.g. after a process
+	//   restart. Set it to the last UploadProgress.State reported, and
+	//   provide the same File. Only single video uploads can be resumed.
+	Resume *UploadState
+
 	// Internal config
 	config         map[string]interface{}
 	configURL      string
@@ -60,10 +137,15 @@ type UploadOptions struct {
 	width          int
 	height         int
 	duration       int
+	frameRate      float64
 	mediaType      int
 	isSidecar      bool
 	useXSharingIDs bool
 	isThumbnail    bool
+	isDirect       bool
+	configured     bool // set once a configure request has been sent
+	segment        int
+	segments       int
 
 	// File buf
 	buf      *bytes.Buffer
@@ -74,6 +156,69 @@ type UploadOptions struct {
 	tagsJSON string
 }
 
+// UploadKind is the kind of post a video is uploaded as, see
+//   UploadOptions.Kind.
+type UploadKind int
+
+const (
+	// A reel, or a story if UploadOptions.IsStory is set
+	KindDefault UploadKind = iota
+	// A video post in the feed, of 3 to 60 seconds
+	KindFeedVideo
+	// A reel, of up to 90 seconds
+	KindReel
+	// An IGTV video, of 1 to 15 minutes, with a title
+	KindIGTV
+	// A story, of up to 60 seconds
+	KindStory
+)
+
+// PreviewCrop is the part of an IGTV video shown in the feed, as fractions
+//   of the video size from 0 to 1.
+type PreviewCrop struct {
+	Left   float64
+	Top    float64
+	Right  float64
+	Bottom float64
+}
+
+// UploadPhase is the stage an upload is in, as reported to
+//   UploadOptions.OnProgress.
+type UploadPhase string
+
+const (
+	// The file is being transferred
+	UploadTransfer UploadPhase = "transfer"
+	// Instagram is transcoding the uploaded video
+	UploadProcessing UploadPhase = "processing"
+	// The uploaded media is being configured as a post or story
+	UploadConfigure UploadPhase = "configure"
+)
+
+// UploadProgress is passed to UploadOptions.OnProgress. Segment is the number
+//   of segments transferred so far. Bytes and segments are counted per file,
+//   for albums and multi story uploads they are reset for every file.
+type UploadProgress struct {
+	Phase      UploadPhase
+	Segment    int
+	Segments   int
+	BytesSent  int64
+	TotalBytes int64
+
+	// State can be saved, and used as UploadOptions.Resume to continue the
+	//   upload after an interruption
+	State UploadState
+}
+
+// UploadState identifies a partially uploaded video, so it can be resumed.
+type UploadState struct {
+	UploadID    string `json:"upload_id"`
+	Name        string `json:"name"`
+	WaterfallID string `json:"waterfall_id"`
+	StreamID    string `json:"stream_id,omitempty"`
+	Offset      int64  `json:"offset"`
+}
+
 // UserTag represents a user post tag. Position is optional, a random
 //   position will be used if not provided. For videos the position will always
 //   be [0,0], and doesn't need to be provided.
@@ -108,6 +253,26 @@ func (insta *Instagram) Upload(o *UploadOptions) (*Item, error) {
 	o.insta = insta
 	o.startTime = toString(time.Now().Unix())
 
+	if err := o.checkKind(); err != nil {
+		return nil, err
+	}
+	if err := o.checkAudio(); err != nil {
+		return nil, err
+	}
+
+	// Validate stickers before uploading
+	if len(o.Stickers) > 0 && !o.IsStory {
+		return nil, ErrStickerNotStory
+	}
+	if err := o.addStickers(map[string]interface{}{}); err != nil {
+		return nil, err
+	}
+
+	// Check the caption and user tags against Instagram's limits
+	if violations := o.checkLimits(); len(violations) > 0 {
+		return nil, violations[0]
+	}
+
 	// Format User & Location Tags
 	if err := o.processTags(); err != nil {
 		return nil, err
@@ -130,13 +295,22 @@ func (insta *Instagram) Upload(o *UploadOptions) (*Item, error) {
 	o.buf = buf
 
 	// Check file type
-	switch t := http.DetectContentType(buf.Bytes()); t {
-	case "image/jpeg":
+	switch t := detectContentType(buf.Bytes()); {
+	case isImage(t):
+		if o.Kind == KindReel || o.Kind == KindIGTV {
+			return nil, errors.Wrap(ErrUploadKind, "reels and IGTV uploads need to be a video")
+		}
+		if err := o.convertImage(); err != nil {
+			return nil, err
+		}
+		if err := o.conformImage(); err != nil {
+			return nil, err
+		}
 		if err := o.uploadPhoto(); err != nil {
 			return nil, err
 		}
 		return o.configureImage()
-	case "video/mp4":
+	case t == "video/mp4":
 		if err := o.uploadVideo(); err != nil {
 			return nil, err
 		}
@@ -267,12 +441,82 @@ func (o *UploadOptions) configurePost() (*Item, error) {
 }
 
 func (o *UploadOptions) configureVideo() (*Item, error) {
-	if o.IsStory {
+	switch o.kind() {
+	case KindStory:
 		return o.configureStory(true)
+	case KindFeedVideo:
+		return o.configureFeedVideo()
+	case KindIGTV:
+		return o.configureIGTV()
 	}
 	return o.configureClip()
 }
 
+func (o *UploadOptions) configureFeedVideo() (*Item, error) {
+	insta := o.insta
+
+	query := MergeMapI(
+		o.config,
+		map[string]interface{}{
+			"camera_entry_point":         "35",
+			"_uid":                       toString(insta.Account.ID),
+			"_uuid":                      insta.uuid,
+			"device_id":                  insta.dID,
+			"creation_logger_session_id": generateUUID(),
+			"nav_chain":                  "",
+		},
+	)
+
+	if o.locationJSON != "" {
+		query["location"] = o.locationJSON
+	}
+
+	o.config = query
+	o.configURL = urlConfigure + "?video=1"
+	return o.configure()
+}
+
+func (o *UploadOptions) configureIGTV() (*Item, error) {
+	insta := o.insta
+
+	query := MergeMapI(
+		o.config,
+		map[string]interface{}{
+			"_uid":                       toString(insta.Account.ID),
+			"_uuid":                      insta.uuid,
+			"device_id":                  insta.dID,
+			"title":                      o.Title,
+			"igtv_ads_toggled_on":        "0",
+			"igtv_share_preview_to_feed": "1",
+			"keep_shoppable_products":    "0",
+			"is_unified_video":           "1",
+		},
+	)
+
+	if o.DisableShareToFeed {
+		query["igtv_share_preview_to_feed"] = "0"
+	}
+	if o.IGTVSeries != nil {
+		// Series channel IDs are prefixed, configure expects the bare ID
+		query["igtv_series_id"] = strings.TrimPrefix(o.IGTVSeries.ID, "series_")
+	}
+	if c := o.PreviewCrop; c != nil {
+		query["feed_preview_crop"] = map[string]interface{}{
+			"crop_left":   c.Left,
+			"crop_top":    c.Top,
+			"crop_right":  c.Right,
+			"crop_bottom": c.Bottom,
+		}
+	}
+	if o.locationJSON != "" {
+		query["location"] = o.locationJSON
+	}
+
+	o.config = query
+	o.configURL = urlConfigureIGTV
+	return o.configure()
+}
+
 func (o *UploadOptions) configureClip() (*Item, error) {
 	insta := o.insta
 
@@ -295,9 +539,16 @@ func (o *UploadOptions) configureClip() (*Item, error) {
 			"is_created_with_contextual_music_recs": "0",
 			"clips_creation_entry_point":            "feed",
 
-			"is_clips_edited": "0",
+			"is_clips_edited":             "0",
+			"clips_share_preview_to_feed": "1",
 		},
 	)
+	if o.DisableShareToFeed {
+		query["clips_share_preview_to_feed"] = "0"
+	}
+	if err := o.addAudio(query); err != nil {
+		return nil, err
+	}
 
 	o.config = query
 	o.configURL = urlConfigureClip
@@ -324,6 +575,9 @@ func (o *UploadOptions) configureStory(video bool) (*Item, error) {
 			"nav_chain": "",
 		},
 	)
+	if err := o.addStickers(query); err != nil {
+		return nil, err
+	}
 
 	o.config = query
 	o.configURL = urlConfigureStory
@@ -339,6 +593,12 @@ func (o *UploadOptions) postThumbnail() error {
 	if err != nil {
 		return err
 	}
+
+	// Keep the video state, in case it needs to be reuploaded
+	video, name, waterfallID, params := o.buf, o.name, o.waterfallID, o.ruploadParams
+	defer func() {
+		o.buf, o.name, o.waterfallID, o.ruploadParams = video, name, waterfallID, params
+	}()
 	o.buf = buf
 
 	rand := random(1000000000, 9999999999)
@@ -356,22 +616,64 @@ func (o *UploadOptions) postThumbnail() error {
 	return nil
 }
 
+// postVideo uploads the video bytes from o.offset onwards, in segments of
+//   SegmentSize. Failed segments are retried from the offset Instagram
+//   reports to have received.
 func (o *UploadOptions) postVideo() error {
+	total := o.buf.Len()
+	size := o.segmentSize()
+	o.segments = (total + size - 1) / size
+	o.segment = (o.offset + size - 1) / size
+	o.progress(UploadTransfer, o.offset)
+
+	for o.offset < total {
+		end := o.offset + size
+		if end > total {
+			end = total
+		}
+
+		err := o.retry(
+			func() error {
+				return o.postVideoSegment(o.offset, end)
+			},
+			func() {
+				// Part of the segment may have been received
+				if offset, err := o.postVideoGET(); err == nil {
+					o.offset = offset
+				}
+			},
+		)
+		if err != nil {
+			return errors.Wrapf(err, "failed to upload video segment %d of %d", o.offset/size+1, o.segments)
+		}
+
+		o.offset = end
+		o.segment = (end + size - 1) / size
+		o.progress(UploadTransfer, o.offset)
+	}
+	return nil
+}
+
+func (o *UploadOptions) postVideoSegment(start, end int) error {
 	insta := o.insta
+	if start >= end {
+		// Instagram already received the full segment
+		return nil
+	}
 
-	// Upload video bytes
 	body, _, err := insta.sendRequest(
 		&reqOptions{
 			Endpoint:  fmt.Sprintf(urlUploadVideo, o.name),
 			OmitAPI:   true,
 			IsPost:    true,
-			DataBytes: o.buf,
+			DataBytes: bytes.NewBuffer(o.buf.Bytes()[start:end]),
+			Context:   o.context(),
 			ExtraHeaders: map[string]string{
 				"X-Entity-Name":              o.name,
 				"X-Entity-Type":              http.DetectContentType(o.buf.Bytes()),
 				"X-Entity-Length":            toString(o.buf.Len()),
 				"X-Instagram-Rupload-Params": o.ruploadParams,
-				"Offset":                     "0",
+				"Offset":                     toString(start),
 				"Content-type":               "application/octet-stream",
 				"X_fb_photo_waterfall_id":    o.waterfallID,
 			},
@@ -398,8 +700,10 @@ func (o *UploadOptions) postVideo() error {
 }
 
 // postVideoGET - every video upload is a sequence of a get request, followed
-//   by a post request to upload the bytes.
-func (o *UploadOptions) postVideoGET() error {
+//   by a post request to upload the bytes. The get request returns the offset
+//   up to which Instagram has received the video, which is used to resume
+//   uploads.
+func (o *UploadOptions) postVideoGET() (int, error) {
 	insta := o.insta
 
 	headers := map[string]string{
@@ -412,14 +716,29 @@ func (o *UploadOptions) postVideoGET() error {
 		// Segment type = 1 for last segment, 2 for all others
 		headers["Segment-Type"] = toString(o.segmentType)
 	}
-	_, _, err := insta.sendRequest(
+	body, _, err := insta.sendRequest(
 		&reqOptions{
 			Endpoint:     fmt.Sprintf(urlUploadVideo, o.name),
 			OmitAPI:      true,
+			Context:      o.context(),
 			ExtraHeaders: headers,
 		},
 	)
-	return err
+	if err != nil {
+		return 0, err
+	}
+
+	// A response without offset means nothing has been received yet
+	var result struct {
+		Offset int `json:"offset"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, err
+	}
+	if result.Offset < 0 || result.Offset > o.buf.Len() {
+		return 0, ErrUploadResumeMismatch
+	}
+	return result.Offset, nil
 }
 
 func (o *UploadOptions) postPhoto() error {
@@ -437,6 +756,7 @@ func (o *UploadOptions) postPhoto() error {
 			OmitAPI:   true,
 			IsPost:    true,
 			DataBytes: o.buf,
+			Context:   o.context(),
 			ExtraHeaders: map[string]string{
 				"X-Entity-Name":              o.name,
 				"X-Entity-Type":              contentType,
@@ -497,10 +817,21 @@ func (o *UploadOptions) createRUploadParams(extra ...map[string]string) error {
 			params["content_tags"] = "use_default_cover"
 			params["extract_cover_frame"] = "1" // test this out
 		}
+		if !o.isSidecar && !o.isDirect {
+			switch o.kind() {
+			case KindReel:
+				params["is_clips_video"] = "1"
+			case KindIGTV:
+				params["is_igtv_video"] = "1"
+			}
+		}
 	}
 	if o.isSidecar {
 		params["is_sidecar"] = "1"
 	}
+	if o.isDirect {
+		params["direct_v2"] = "1"
+	}
 	if o.useXSharingIDs {
 		ids := []string{}
 		if o.UserTags != nil {
@@ -564,6 +895,9 @@ func (o *UploadOptions) createPhotoConfig() {
 	if o.tagsJSON != "" {
 		config["usertags"] = o.tagsJSON
 	}
+	if alt := o.altText(); alt != "" && !o.IsStory {
+		config["custom_accessibility_caption"] = alt
+	}
 	if o.IsStory {
 		supCap, _ := getSupCap()
 
@@ -618,7 +952,7 @@ func (o *UploadOptions) createVideoConfig() error {
 			"source_height": o.height,
 		},
 		"audio_muted":        o.MuteAudio,
-		"poster_frame_index": 0, // TODO: look into this (testing to see if it matters which index is used)
+		"poster_frame_index": o.posterFrame(),
 	}
 
 	if o.UserTags != nil && !o.IsStory {
@@ -629,6 +963,9 @@ func (o *UploadOptions) createVideoConfig() error {
 		}
 		config["usertags"] = string(b)
 	}
+	if alt := o.altText(); alt != "" && !o.IsStory {
+		config["custom_accessibility_caption"] = alt
+	}
 	if o.DisableLikeViewCount && !o.IsStory {
 		config["like_and_view_counts_disabled"] = "1"
 	}
@@ -684,48 +1021,69 @@ func (o *UploadOptions) uploadAlbum() (*Item, error) {
 		return nil, ErrCarouselMediaLimit
 	}
 
-	// Upload photos one by one
-	var metadata []map[string]interface{}
+	// Read and validate all items before uploading any
+	o.bufAlbum = nil
+	thumbnails := make([]io.Reader, len(o.Album))
 	for index, media := range o.Album {
-
-		// Read media into memory
 		buf, err := readFile(media)
 		if err != nil {
 			return nil, err
 		}
 		o.buf = buf
 
-		// Use album tags if available
-		if o.UserTags == nil && o.AlbumTags != nil && len(*o.AlbumTags) == len(o.Album) {
-			o.UserTags = &(*o.AlbumTags)[index]
-			if err := o.processTags(); err != nil {
+		video, err := o.checkAlbumItem()
+		if err != nil {
+			return nil, fmt.Errorf("album item %d: %w", index+1, err)
+		}
+		if video && index < len(o.AlbumThumbnails) && o.AlbumThumbnails[index] != nil {
+			thumb, err := readFile(o.AlbumThumbnails[index])
+			if err != nil {
 				return nil, err
 			}
+			b, err := ConvertToJPEG(thumb.Bytes(), o.JPEGQuality, o.Background)
+			if err != nil {
+				return nil, fmt.Errorf("album item %d thumbnail: %w", index+1, err)
+			}
+			thumbnails[index] = bytes.NewReader(b)
+		}
+		o.bufAlbum = append(o.bufAlbum, o.buf)
+	}
+
+	// Upload items one by one, on a copy of the options as the thumbnail
+	// and tags differ per item
+	var metadata []map[string]interface{}
+	for index, buf := range o.bufAlbum {
+		item := *o
+		item.index = index
+		item.buf = buf
+		item.Thumbnail = thumbnails[index]
+
+		// Use album tags if available
+		item.tagsJSON = ""
+		if o.AlbumTags != nil && index < len(*o.AlbumTags) {
+			item.UserTags = &(*o.AlbumTags)[index]
+		}
+		if err := item.processTags(); err != nil {
+			return nil, err
 		}
 
 		// Upload Media
-		switch t := http.DetectContentType(buf.Bytes()); t {
-		case "image/jpeg":
+		if detectContentType(buf.Bytes()) == "video/mp4" {
+			if err := item.uploadVideo(); err != nil {
+				return nil, err
+			}
+		} else {
 			// Create upload id & name
-			o.newUploadID()
+			item.newUploadID()
 			rand := random(1000000000, 9999999999)
-			o.name = o.uploadID + "_0_" + toString(rand)
+			item.name = item.uploadID + "_0_" + toString(rand)
 
-			err := o.uploadPhoto()
-			if err != nil {
+			if err := item.uploadPhoto(); err != nil {
 				return nil, err
 			}
-		case "video/mp4":
-			err := o.uploadVideo()
-			if err != nil {
-				return nil, err
-			}
-		default:
-			insta.infoHandler(fmt.Errorf("unable to handle file upload with format %s", t))
-			return nil, ErrInvalidFormat
 		}
 
-		metadata = append(metadata, o.config)
+		metadata = append(metadata, item.config)
 	}
 
 	// Album upload id
@@ -756,8 +1114,75 @@ func (o *UploadOptions) uploadAlbum() (*Item, error) {
 	return o.configure()
 }
 
+// checkAlbumItem converts and conforms o.buf if it is an image, or checks
+//   it against the carousel limits if it is a video.
+func (o *UploadOptions) checkAlbumItem() (bool, error) {
+	switch t := detectContentType(o.buf.Bytes()); {
+	case isImage(t):
+		if err := o.convertImage(); err != nil {
+			return false, err
+		}
+		return false, o.conformImage()
+	case t == "video/mp4":
+		if _, err := o.inspectVideo(); err != nil {
+			return true, err
+		}
+		if err := o.checkDuration(); err != nil {
+			return true, err
+		}
+		return true, o.conformVideo()
+	default:
+		o.insta.infoHandler(fmt.Errorf("unable to handle file upload with format %s", t))
+		return false, ErrCarouselType
+	}
+}
+
+// kind returns the kind of post being uploaded.
+func (o *UploadOptions) kind() UploadKind {
+	switch {
+	case o.IsStory:
+		return KindStory
+	case o.Kind == KindDefault:
+		return KindReel
+	}
+	return o.Kind
+}
+
+// checkKind validates the options that depend on UploadOptions.Kind.
+func (o *UploadOptions) checkKind() error {
+	if o.Kind == KindStory {
+		o.IsStory = true
+	}
+	switch {
+	case o.IsStory && o.Kind != KindDefault && o.Kind != KindStory:
+		return errors.Wrap(ErrUploadKind, "stories can't be uploaded as another kind")
+	case len(o.Album) > 0 && (o.Kind == KindReel || o.Kind == KindIGTV):
+		return errors.Wrap(ErrUploadKind, "reels and IGTV videos can't be albums")
+	case o.kind() == KindIGTV && strings.TrimSpace(o.Title) == "":
+		return ErrIGTVNoTitle
+	}
+	if c := o.PreviewCrop; c != nil {
+		if c.Left < 0 || c.Top < 0 || c.Right > 1 || c.Bottom > 1 || c.Left >= c.Right || c.Top >= c.Bottom {
+			return ErrInvalidPreviewCrop
+		}
+	}
+	return nil
+}
+
+// altText returns the alt text of the current carousel item, or of the post.
+func (o *UploadOptions) altText() string {
+	if o.isSidecar {
+		if o.index < len(o.AlbumAltText) {
+			return o.AlbumAltText[o.index]
+		}
+		return ""
+	}
+	return o.AltText
+}
+
 func (o *UploadOptions) configure() (*Item, error) {
 	insta := o.insta
+	o.progress(UploadConfigure, o.buf.Len())
 
 	// Create request query
 	data, err := json.Marshal(o.config)
@@ -765,11 +1190,13 @@ func (o *UploadOptions) configure() (*Item, error) {
 		return nil, err
 	}
 
+	o.configured = true
 	body, _, err := insta.sendRequest(
 		&reqOptions{
 			Endpoint: o.configURL,
 			IsPost:   true,
 			Query:    generateSignature(data),
+			Context:  o.context(),
 			ExtraHeaders: map[string]string{
 				"Retry_context": `{"num_reupload":0,"num_step_auto_retry":0,"num_step_manual_retry":0}`,
 			},
@@ -793,10 +1220,14 @@ func (o *UploadOptions) configure() (*Item, error) {
 		switch res.Message {
 		case "Transcode not finished yet.":
 			insta.infoHandler("Waiting for transcode to finish...")
-			time.Sleep(6 * time.Second)
+			o.progress(UploadProcessing, o.buf.Len())
+			if err := o.wait(6 * time.Second); err != nil {
+				return nil, err
+			}
 			return o.configure()
 		case "media_needs_reupload":
 			insta.infoHandler(fmt.Errorf("instagram asks for the video to be reuploaded, please wait"))
+			o.offset = 0
 			err := o.postVideo()
 			if err != nil {
 				return nil, err
@@ -827,14 +1258,16 @@ func (o *UploadOptions) uploadMultiStory() (*Item, error) {
 		}
 
 		// Get video info
-		width, height, duration, err := getVideoInfo(buf.Bytes())
-		if err != nil {
+		o.buf = buf
+		if _, err := o.inspectVideo(); err != nil {
 			return nil, err
 		}
-		o.width, o.height, o.duration = width, height, duration
 
-		if duration > 20000 {
-			return nil, ErrStoryMediaTooLong
+		if err := o.checkDuration(); err != nil {
+			return nil, err
+		}
+		if err := o.conformVideo(); err != nil {
+			return nil, err
 		}
 	}
 
@@ -849,12 +1282,13 @@ func (o *UploadOptions) uploadMultiStory() (*Item, error) {
 	for i, buf := range o.bufAlbum {
 		o.index = i
 		o.buf = buf
+		o.segment, o.segments = 0, 1
 
-		width, height, duration, err := getVideoInfo(o.buf.Bytes())
+		_, err := o.inspectVideo()
 		if err != nil {
 			return nil, err
 		}
-		o.width, o.height, o.duration = width, height, duration
+		width, height, duration := o.width, o.height, o.duration
 
 		size := float64(len(o.buf.Bytes())) / 1000000.0
 		o.insta.infoHandler(
@@ -873,10 +1307,12 @@ func (o *UploadOptions) uploadMultiStory() (*Item, error) {
 			return nil, err
 		}
 
-		err = o.segmentTransfer(buf.Bytes())
+		err = o.retry(func() error { return o.segmentTransfer(buf.Bytes()) }, nil)
 		if err != nil {
 			return nil, err
 		}
+		o.segment = 1
+		o.progress(UploadTransfer, buf.Len())
 
 		err = o.createVideoConfig()
 		if err != nil {
@@ -899,13 +1335,25 @@ func (o *UploadOptions) uploadMultiStory() (*Item, error) {
 func (o *UploadOptions) uploadVideo() error {
 	// Set media type to video
 	o.mediaType = 2
-	o.newUploadID()
+	o.offset = 0
+	resume := o.Resume != nil && !o.isSidecar
+	if resume {
+		o.uploadID = o.Resume.UploadID
+	} else {
+		o.newUploadID()
+	}
 
-	width, height, duration, err := getVideoInfo(o.buf.Bytes())
+	info, err := o.inspectVideo()
 	if err != nil {
 		return err
 	}
-	o.width, o.height, o.duration = width, height, duration
+	width, height, duration := o.width, o.height, o.duration
+	if err := o.checkDuration(); err != nil {
+		return err
+	}
+	if err := o.conformVideo(); err != nil {
+		return err
+	}
 
 	// Verify Thumbnail content type
 	if o.Thumbnail != nil {
@@ -914,18 +1362,18 @@ func (o *UploadOptions) uploadVideo() error {
 			return err
 		}
 
-		contentType := http.DetectContentType(thumb.Bytes())
-		if contentType == "text/plain" {
-			return ErrInvalidImage
+		b, err := ConvertToJPEG(thumb.Bytes(), o.JPEGQuality, o.Background)
+		if err != nil {
+			return err
 		}
-		o.Thumbnail = bytes.NewReader(thumb.Bytes())
+		o.Thumbnail = bytes.NewReader(b)
 	}
 
 	size := float64(len(o.buf.Bytes())) / 1000000.0
 	o.insta.infoHandler(
 		fmt.Sprintf(
-			"Upload video: duration: %ds, Size: %dx%d, %.2f Mb",
-			duration/1000, width, height, size,
+			"Upload video: duration: %ds, Size: %dx%d, %s %.3g fps, %.2f Mb",
+			duration/1000, width, height, info.Codec, info.FrameRate, size,
 		),
 	)
 
@@ -939,15 +1387,24 @@ func (o *UploadOptions) uploadVideo() error {
 		o.insta.warnHandler("Video size is fairy large, if you have trouble uploading, try a smaller video.")
 	}
 
-	rand := random(1000000000, 9999999999)
-	o.name = fmt.Sprintf("%s_0_%d", o.uploadID, rand)
-	o.waterfallID = generateUUID()
+	if resume {
+		o.name, o.waterfallID, o.streamID = o.Resume.Name, o.Resume.WaterfallID, o.Resume.StreamID
+	} else {
+		rand := random(1000000000, 9999999999)
+		o.name = fmt.Sprintf("%s_0_%d", o.uploadID, rand)
+		o.waterfallID = generateUUID()
+	}
 
-	// Initialize the upload with a get request
-	if err := o.postVideoGET(); err != nil {
+	// Initialize the upload with a get request, which returns the offset to
+	//   continue from when resuming
+	offset, err := o.postVideoGET()
+	if err != nil {
 		return err
 	}
-
+	if resume {
+		o.offset = offset
+		o.insta.infoHandler(fmt.Sprintf("Resuming video upload at %d of %d bytes", offset, o.buf.Len()))
+	}
 	if err := o.postVideo(); err != nil {
 		return err
 	}
@@ -998,6 +1455,7 @@ func (o *UploadOptions) segmentTransfer(segment []byte) error {
 			OmitAPI:      true,
 			IsPost:       true,
 			DataBytes:    bytes.NewBuffer(segment),
+			Context:      o.context(),
 			ExtraHeaders: headers,
 		},
 	)
@@ -1050,3 +1508,86 @@ func (o *UploadOptions) processTags() error {
 func (o *UploadOptions) newUploadID() {
 	o.uploadID = toString(random(1000000000, 9999999999))
 }
+
+func (o *UploadOptions) context() context.Context {
+	if o.Context == nil {
+		return context.Background()
+	}
+	return o.Context
+}
+
+func (o *UploadOptions) segmentSize() int {
+	if o.SegmentSize <= 0 {
+		return 1 << 22
+	}
+	return o.SegmentSize
+}
+
+// State returns the state of the current video upload, which can be used to
+//   resume it with UploadOptions.Resume.
+func (o *UploadOptions) State() UploadState {
+	return UploadState{
+		UploadID:    o.uploadID,
+		Name:        o.name,
+		WaterfallID: o.waterfallID,
+		StreamID:    o.streamID,
+		Offset:      int64(o.offset),
+	}
+}
+
+func (o *UploadOptions) progress(phase UploadPhase, sent int) {
+	if o.OnProgress == nil || o.buf == nil {
+		return
+	}
+	o.OnProgress(UploadProgress{
+		Phase:      phase,
+		Segment:    o.segment,
+		Segments:   o.segments,
+		BytesSent:  int64(sent),
+		TotalBytes: int64(o.buf.Len()),
+		State:      o.State(),
+	})
+}
+
+// retry calls fn until it succeeds, or SegmentRetries is exceeded. Before
+//   every retry it waits, and calls before if set.
+func (o *UploadOptions) retry(fn func() error, before func()) error {
+	retries := o.SegmentRetries
+	if retries == 0 {
+		retries = 3
+	}
+
+	var err error
+	for i := 0; ; i++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctxErr := o.context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if i >= retries {
+			return err
+		}
+
+		o.insta.warnHandler(fmt.Sprintf("Upload failed, retrying (%d/%d): %v", i+1, retries, err))
+		if err := o.wait(time.Duration(i+1) * 2 * time.Second); err != nil {
+			return err
+		}
+		if before != nil {
+			before()
+		}
+	}
+}
+
+// wait sleeps for d, or until the upload is cancelled.
+func (o *UploadOptions) wait(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-o.context().Done():
+		return o.context().Err()
+	}
+}
@@ -752,29 +752,25 @@ func (user *User) Tags(minTimestamp []byte) (*FeedMedia, error) {
 
 // DownloadProfilePic will download a user's profile picture if available, and
 //   return it as a byte slice.
-func (user *User) DownloadProfilePic() ([]byte, error) {
-	if user.ProfilePicURL == "" {
-		return nil, ErrNoProfilePicURL
-	}
-	insta := user.insta
-	b, err := insta.download(user.ProfilePicURL)
-	if err != nil {
-		return nil, err
-	}
-	user.ProfilePic = b
-	return b, nil
+//
+// If the HD versions of the profile picture have been fetched, the quality can
+//   be picked by passing a MediaSelector.
+func (user *User) DownloadProfilePic(sel ...MediaSelector) ([]byte, error) {
+	return user.downloadProfilePic(user.profilePicURL(sel))
 }
 
 // DownloadProfilePicTo will download the user profile picture to the provided
 //   path. If path does not include a file name, one will be extracted automatically.
 // File extention does not need to be set, and will be set automatically.
-func (user *User) DownloadProfilePicTo(dst string) error {
+func (user *User) DownloadProfilePicTo(dst string, sel ...MediaSelector) error {
 	folder, fn := path.Split(dst)
-	b, err := user.DownloadProfilePic()
+	// Select once, as selectors can make requests and pick another version
+	url := user.profilePicURL(sel)
+	b, err := user.downloadProfilePic(url)
 	if err != nil {
-		return nil
+		return err
 	}
-	fn, err = getDownloadName(user.ProfilePicURL, fn)
+	fn, err = getDownloadName(url, fn)
 	if err != nil {
 		return err
 	}
@@ -782,6 +778,37 @@ func (user *User) DownloadProfilePicTo(dst string) error {
 	return err
 }
 
+func (user *User) downloadProfilePic(url string) ([]byte, error) {
+	if url == "" {
+		return nil, ErrNoProfilePicURL
+	}
+	insta := user.insta
+	b, err := insta.download(url)
+	if err != nil {
+		return nil, err
+	}
+	user.ProfilePic = b
+	return b, nil
+}
+
+// profilePicURL selects one of the available profile picture versions.
+func (user *User) profilePicURL(sel []MediaSelector) string {
+	versions := append([]PicURLInfo{}, user.HdProfilePicVersions...)
+	if user.HdProfilePicURLInfo.URL != "" {
+		versions = append(versions, user.HdProfilePicURLInfo)
+	}
+	if user.ProfilePicURL != "" {
+		// The default profile picture is 150x150
+		versions = append(versions, PicURLInfo{URL: user.ProfilePicURL, Width: 150, Height: 150})
+	}
+	return user.insta.selectMedia(versions, sel)
+}
+
+// URL returns the link to the user's profile.
+func (user *User) URL() string {
+	return instaWebURL + user.Username + "/"
+}
+
 func (user *User) ApprovePending() error {
 	return user.changePending(urlFriendshipApprove)
 }
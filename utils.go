@@ -3,10 +3,8 @@ package goinsta
 import (
 	"bytes"
 	"encoding/base64"
-	"encoding/binary"
 	"encoding/json"
 	"image"
-	"math"
 	"regexp"
 
 	// Required for getImageDimensionFromReader in jpg and png format
@@ -95,45 +93,6 @@ func getImageSize(b []byte) (int, int, error) {
 	return image.Width, image.Height, nil
 }
 
-func getVideoInfo(b []byte) (height, width, duration int, err error) {
-	keys := []string{"moov", "trak", "stbl", "avc1"}
-	height, err = read16(b, keys, 24)
-	if err != nil {
-		return
-	}
-	width, err = read16(b, keys, 26)
-	if err != nil {
-		return
-	}
-
-	duration, err = getMP4Duration(b)
-	if err != nil {
-		return
-	}
-
-	return
-}
-
-func getMP4Duration(b []byte) (int, error) {
-	keys := []string{"moov", "mvhd"}
-	timescale, err := read32(b, keys, 12)
-	if err != nil {
-		return -1, err
-	}
-
-	// If timescale failed to read a value, return -1 * 1000
-	if timescale == 0 {
-		return -1000, nil
-	}
-
-	length, err := read32(b, keys, 12+4)
-	if err != nil {
-		return -1, err
-	}
-
-	return int(math.Floor(float64(length) / float64(timescale) * 1000)), nil
-}
-
 func getTimeOffset() string {
 	_, offset := time.Now().Zone()
 	return strconv.Itoa(offset)
@@ -225,36 +184,6 @@ func MergeMapS(one map[string]string, extra ...map[string]string) map[string]str
 	return one
 }
 
-func read16(b []byte, keys []string, offset int) (int, error) {
-	start, err := getStartByte(b, keys, offset)
-	if err != nil {
-		return -1, nil
-	}
-	r := binary.BigEndian.Uint16(b[start+offset:])
-	return int(r), nil
-}
-
-func read32(b []byte, keys []string, offset int) (int, error) {
-	start, err := getStartByte(b, keys, offset)
-	if err != nil {
-		return -1, nil
-	}
-	r := binary.BigEndian.Uint32(b[start+offset:])
-	return int(r), nil
-}
-
-func getStartByte(b []byte, keys []string, offset int) (int, error) {
-	start := 0
-	for _, key := range keys {
-		n := bytes.Index(b[start:], []byte(key))
-		if n == -1 {
-			return -1, ErrByteIndexNotFound
-		}
-		start += n + len(key)
-	}
-	return start, nil
-}
-
 func getSupCap() (string, error) {
 	query := []trayRequest{
 		{"SUPPORTED_SDK_VERSIONS", supportedSdkVersions},
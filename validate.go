@@ -0,0 +1,237 @@
+package goinsta
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+	"unicode/utf8"
+)
+
+// Instagram's post limits
+const (
+	maxCaptionLength = 2200
+	maxHashtags      = 30
+	maxMentions      = 20
+	maxUserTags      = 20
+
+	maxImageSize int64 = 8 << 20
+	maxVideoSize int64 = 4 << 30
+)
+
+var rxpMentions = regexp.MustCompile(`@[\w.]+`)
+
+// Violation is a problem with an upload, as returned by
+// Instagram.ValidateUpload.
+type Violation struct {
+	// Field is the upload option the problem is in, with the index for
+	// lists, e.g. File, Album[2], Caption or Stickers[0]
+	Field string
+	// Err is a ConstraintError for limits, or one of the upload errors
+	Err error
+}
+
+func (v Violation) Error() string {
+	return v.Field + ": " + v.Err.Error()
+}
+
+func (v Violation) Unwrap() error {
+	return v.Err
+}
+
+// ValidateUpload runs all checks Upload does on the media and options,
+// without any network requests, and returns every problem found. If the
+// upload is valid nil is returned.
+//
+// The options are not changed. Files that implement io.Seeker, such as
+// *os.File and *bytes.Reader, are rewound after reading them, so o can still
+// be passed to Upload afterwards. Other readers are consumed.
+func (insta *Instagram) ValidateUpload(o *UploadOptions) []Violation {
+	// Checks are run on a copy, as they fill in the internal config
+	v := *o
+	v.insta = insta
+	var violations []Violation
+	add := func(field string, errs ...error) {
+		for _, err := range errs {
+			if err != nil {
+				violations = append(violations, Violation{Field: field, Err: err})
+			}
+		}
+	}
+
+	// Options
+	add("Kind", v.checkKind())
+	add("Audio", v.checkAudio())
+	if len(v.Stickers) > 0 && !v.IsStory {
+		add("Stickers", ErrStickerNotStory)
+	}
+	for i, s := range v.Stickers {
+		if s != nil {
+			_, _, _, err := s.sticker()
+			add(fmt.Sprintf("Stickers[%d]", i), err)
+		}
+	}
+	violations = append(violations, v.checkLimits()...)
+
+	// Media
+	v.isSidecar = len(v.Album) > 0 && !v.IsStory
+	if len(v.Album) == 0 {
+		v.validateMedia("File", v.File, add)
+		if v.Thumbnail != nil {
+			validateThumbnail("Thumbnail", v.Thumbnail, add)
+		}
+		return violations
+	}
+
+	if v.isSidecar && len(v.Album) > 10 {
+		add("Album", ErrCarouselMediaLimit)
+	}
+	for i, r := range v.Album {
+		v.index = i
+		v.validateMedia(fmt.Sprintf("Album[%d]", i), r, add)
+	}
+	for i, thumb := range v.AlbumThumbnails {
+		if thumb != nil {
+			validateThumbnail(fmt.Sprintf("AlbumThumbnails[%d]", i), thumb, add)
+		}
+	}
+	return violations
+}
+
+// checkLimits checks the caption and user tags against Instagram's limits.
+func (o *UploadOptions) checkLimits() []Violation {
+	var violations []Violation
+	add := func(field string, errs ...error) {
+		for _, err := range errs {
+			if err != nil {
+				violations = append(violations, Violation{Field: field, Err: err})
+			}
+		}
+	}
+	if !o.IsStory {
+		add("Caption", checkCaption(o.Caption)...)
+	}
+	if o.UserTags != nil {
+		add("UserTags", checkUserTags(*o.UserTags))
+	}
+	if o.AlbumTags != nil {
+		for i, tags := range *o.AlbumTags {
+			add(fmt.Sprintf("AlbumTags[%d]", i), checkUserTags(tags))
+		}
+	}
+	return violations
+}
+
+// validateMedia checks a single file.
+func (o *UploadOptions) validateMedia(field string, r io.Reader, add func(string, ...error)) {
+	if r == nil {
+		add(field, ErrInvalidFormat)
+		return
+	}
+	buf, err := readRewind(r)
+	if err != nil {
+		add(field, err)
+		return
+	}
+	data := buf.Bytes()
+	o.buf = bytes.NewBuffer(data)
+
+	multiStory := o.IsStory && len(o.Album) > 0
+	switch t := detectContentType(data); {
+	case isImage(t) && !multiStory:
+		if o.Kind == KindReel || o.Kind == KindIGTV {
+			add(field, ErrUploadKind)
+		}
+		if err := o.convertImage(); err != nil {
+			add(field, err)
+			break
+		}
+		// Conform fixes the aspect ratio of images, without it they are
+		// uploaded as is
+		if size := int64(o.buf.Len()); size > maxImageSize {
+			add(field, ConstraintError{Constraint: "size", Unit: " MB", Value: mb(size), Max: mb(maxImageSize)})
+		}
+	case t == "video/mp4":
+		info, err := inspectVideo(data)
+		if err != nil {
+			add(field, err)
+			break
+		}
+		add(field, info.check()...)
+		o.width, o.height = info.Width, info.Height
+		o.duration = int(info.Duration / time.Millisecond)
+		add(field, o.checkDuration())
+		add(field, o.conformVideo())
+		if size := int64(len(data)); size > maxVideoSize {
+			add(field, ConstraintError{Constraint: "size", Unit: " MB", Value: mb(size), Max: mb(maxVideoSize)})
+		}
+	case multiStory:
+		add(field, ErrStoryBadMediaType)
+	default:
+		add(field, ErrInvalidFormat)
+	}
+}
+
+// validateThumbnail checks a video thumbnail.
+func validateThumbnail(field string, r io.Reader, add func(string, ...error)) {
+	buf, err := readRewind(r)
+	if err != nil {
+		add(field, err)
+		return
+	}
+	_, err = ConvertToJPEG(buf.Bytes(), 0, nil)
+	add(field, err)
+}
+
+// readRewind reads all of r, and seeks back to where reading started if r
+// is an io.Seeker.
+func readRewind(r io.Reader) (*bytes.Buffer, error) {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return readFile(r)
+	}
+	start, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	buf, err := readFile(r)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.Seek(start, io.SeekStart)
+	return buf, err
+}
+
+// checkCaption checks the length of a caption, and the number of hashtags
+// and mentions in it.
+func checkCaption(caption string) []error {
+	var errs []error
+	if n := utf8.RuneCountInString(caption); n > maxCaptionLength {
+		errs = append(errs, ConstraintError{Constraint: "caption length", Unit: " characters", Value: float64(n), Max: maxCaptionLength})
+	}
+	if n := len(rxpTags.FindAllString(caption, -1)); n > maxHashtags {
+		errs = append(errs, ConstraintError{Constraint: "hashtags", Value: float64(n), Max: maxHashtags})
+	}
+	if n := len(rxpMentions.FindAllString(caption, -1)); n > maxMentions {
+		errs = append(errs, ConstraintError{Constraint: "mentions", Value: float64(n), Max: maxMentions})
+	}
+	return errs
+}
+
+// checkUserTags checks the number of tags, and that every tag has a user.
+func checkUserTags(tags []UserTag) error {
+	for _, tag := range tags {
+		if tag.User == nil || tag.User.ID == 0 {
+			return ErrInvalidUserTag
+		}
+	}
+	if len(tags) > maxUserTags {
+		return ConstraintError{Constraint: "user tags", Value: float64(len(tags)), Max: maxUserTags}
+	}
+	return nil
+}
+
+func mb(size int64) float64 {
+	return float64(size) / (1 << 20)
+}
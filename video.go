@@ -0,0 +1,648 @@
+package goinsta
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Instagram's video limits
+const (
+	maxVideoWidth     = 1920
+	minVideoFrameRate = 23
+	maxVideoFrameRate = 60
+	maxVideoBitrate   = 25000000
+
+	// Durations per kind of upload, carousel videos have the limits of feed
+	// videos
+	minFeedVideoDuration = 3 * time.Second
+	maxFeedVideoDuration = 60 * time.Second
+	maxReelDuration      = 90 * time.Second
+	minIGTVDuration      = time.Minute
+	maxIGTVDuration      = 15 * time.Minute
+	maxStoryDuration     = 60 * time.Second
+	maxDirectDuration    = 60 * time.Second
+)
+
+// maximum size of the moov box to read into memory
+const maxMoovSize = 64 << 20
+
+// VideoInfo describes an MP4 video, as returned by InspectVideo.
+type VideoInfo struct {
+	// Major brand of the file, e.g. isom, mp42 or qt
+	Brand string
+
+	// Codec is one of h264, hevc, av1, vp9, or the sample entry type for
+	// other codecs
+	Codec   string
+	Profile string
+	Level   float64
+
+	// Width and Height are the display dimensions, after rotation
+	Width    int
+	Height   int
+	Rotation int
+
+	FrameRate float64
+	Duration  time.Duration
+	// Bitrate is the average bitrate of the whole file in bits per second
+	Bitrate int64
+	Size    int64
+
+	HasAudio        bool
+	AudioCodec      string
+	AudioChannels   int
+	AudioSampleRate int
+
+	// Number of tracks in the file, of any type
+	Tracks     int
+	Fragmented bool
+}
+
+// InspectVideo parses the boxes of an MP4 (ISO BMFF) video. Only the moov box
+// is read into memory, the media data is skipped, which is done with Seek if
+// r implements io.Seeker.
+func InspectVideo(r io.Reader) (*VideoInfo, error) {
+	info, err := inspectMP4(r)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// inspectMP4 is InspectVideo, but files without a video track, such as voice
+// notes, are also parsed. Their info is returned together with
+// ErrNoVideoTrack.
+func inspectMP4(r io.Reader) (*VideoInfo, error) {
+	var ftyp, moov []byte
+	var size int64
+	var fragmented bool
+
+	seeker, _ := r.(io.Seeker)
+	hdr := make([]byte, 16)
+	for {
+		if _, err := io.ReadFull(r, hdr[:8]); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, errors.Wrap(ErrInvalidMP4, "truncated box header")
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(hdr))
+		typ := string(hdr[4:8])
+		hdrSize := int64(8)
+		if boxSize == 1 {
+			if _, err := io.ReadFull(r, hdr[8:16]); err != nil {
+				return nil, errors.Wrap(ErrInvalidMP4, "truncated box header")
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:]))
+			hdrSize = 16
+		}
+
+		// A size of 0 means the box extends to the end of the file
+		payload := boxSize - hdrSize
+		if boxSize == 0 {
+			payload = -1
+		} else if boxSize < hdrSize {
+			return nil, errors.Wrapf(ErrInvalidMP4, "invalid size of %s box", typ)
+		}
+
+		switch typ {
+		case "ftyp", "moov":
+			if payload > maxMoovSize {
+				return nil, errors.Wrapf(ErrInvalidMP4, "%s box too large", typ)
+			}
+			var b []byte
+			var err error
+			if payload < 0 {
+				b, err = io.ReadAll(io.LimitReader(r, maxMoovSize))
+			} else {
+				b = make([]byte, payload)
+				_, err = io.ReadFull(r, b)
+			}
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidMP4, "truncated %s box", typ)
+			}
+			if typ == "ftyp" {
+				ftyp = b
+			} else {
+				moov = b
+			}
+			payload = int64(len(b))
+		default:
+			if typ == "moof" {
+				fragmented = true
+			}
+			n, err := skip(r, seeker, payload)
+			if err != nil {
+				return nil, errors.Wrapf(ErrInvalidMP4, "truncated %s box", typ)
+			}
+			payload = n
+		}
+
+		size += hdrSize + payload
+		if boxSize == 0 {
+			break
+		}
+	}
+
+	if len(ftyp) < 4 || moov == nil {
+		return nil, errors.Wrap(ErrInvalidMP4, "missing ftyp or moov box")
+	}
+	info, err := parseMoov(moov)
+	if info == nil {
+		return nil, err
+	}
+	info.Brand = string(ftyp[:4])
+	info.Size = size
+	info.Fragmented = info.Fragmented || fragmented
+	if info.Duration > 0 {
+		info.Bitrate = int64(float64(size*8) / info.Duration.Seconds())
+	}
+	return info, err
+}
+
+// skip discards n bytes of r, or until the end if n is negative, and returns
+// the number of bytes skipped.
+func skip(r io.Reader, seeker io.Seeker, n int64) (int64, error) {
+	if seeker != nil {
+		cur, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, err
+		}
+		end, err := seeker.Seek(0, io.SeekEnd)
+		if err != nil {
+			return 0, err
+		}
+		if n < 0 {
+			return end - cur, nil
+		}
+		if cur+n > end {
+			return 0, io.ErrUnexpectedEOF
+		}
+		_, err = seeker.Seek(cur+n, io.SeekStart)
+		return n, err
+	}
+
+	if n < 0 {
+		return io.Copy(io.Discard, r)
+	}
+	m, err := io.CopyN(io.Discard, r, n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return m, err
+}
+
+func inspectVideo(b []byte) (*VideoInfo, error) {
+	return InspectVideo(bytes.NewReader(b))
+}
+
+// parseMoov reads the video properties from the moov box payload. If there
+// is no video track, the properties of the other tracks are returned with
+// ErrNoVideoTrack.
+func parseMoov(b []byte) (*VideoInfo, error) {
+	moov := mp4Box{Type: "moov", Data: b}
+	info := &VideoInfo{}
+
+	if mvhd, ok := moov.child("mvhd"); ok {
+		if timescale, duration, ok := boxDuration(mvhd); ok {
+			info.Duration = toDuration(duration, timescale)
+		}
+	}
+	if mvex, ok := moov.child("mvex"); ok {
+		info.Fragmented = true
+		if mehd, ok := mvex.child("mehd"); ok && info.Duration == 0 {
+			if mvhd, ok := moov.child("mvhd"); ok {
+				timescale, _, _ := boxDuration(mvhd)
+				info.Duration = toDuration(fullBoxUint(mehd, 4), timescale)
+			}
+		}
+	}
+
+	boxes, err := readBoxes(b)
+	if err != nil {
+		return nil, err
+	}
+	var video bool
+	for _, trak := range boxes {
+		if trak.Type != "trak" {
+			continue
+		}
+		info.Tracks++
+
+		hdlr, ok := trak.find("mdia", "hdlr")
+		if !ok || len(hdlr.Data) < 12 {
+			continue
+		}
+		stsd, ok := trak.find("mdia", "minf", "stbl", "stsd")
+		if !ok || len(stsd.Data) < 8 {
+			continue
+		}
+		entries, err := readBoxes(stsd.Data[8:])
+		if err != nil || len(entries) == 0 {
+			continue
+		}
+
+		switch string(hdlr.Data[8:12]) {
+		case "vide":
+			if video {
+				continue
+			}
+			video = true
+			parseVisualEntry(info, entries[0])
+			parseTrackHeader(info, trak)
+			info.FrameRate = frameRate(moov, trak)
+			if info.Duration == 0 {
+				if mdhd, ok := trak.find("mdia", "mdhd"); ok {
+					if timescale, duration, ok := boxDuration(mdhd); ok {
+						info.Duration = toDuration(duration, timescale)
+					}
+				}
+			}
+		case "soun":
+			if info.HasAudio {
+				continue
+			}
+			info.HasAudio = true
+			parseAudioEntry(info, entries[0])
+		}
+	}
+
+	if !video {
+		return info, ErrNoVideoTrack
+	}
+	return info, nil
+}
+
+// boxDuration returns the timescale and duration of an mvhd or mdhd box.
+func boxDuration(box mp4Box) (uint32, uint64, bool) {
+	d := box.Data
+	if box.version() == 1 {
+		if len(d) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(d[20:]), binary.BigEndian.Uint64(d[24:]), true
+	}
+	if len(d) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(d[12:]), uint64(binary.BigEndian.Uint32(d[16:])), true
+}
+
+// fullBoxUint reads a 32 or 64 bit value at off, depending on the version.
+func fullBoxUint(box mp4Box, off int) uint64 {
+	if box.version() == 1 && len(box.Data) >= off+8 {
+		return binary.BigEndian.Uint64(box.Data[off:])
+	} else if len(box.Data) >= off+4 {
+		return uint64(binary.BigEndian.Uint32(box.Data[off:]))
+	}
+	return 0
+}
+
+func toDuration(duration uint64, timescale uint32) time.Duration {
+	if timescale == 0 || duration == math.MaxUint32 || duration == math.MaxUint64 {
+		return 0
+	}
+	return time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+}
+
+// parseVisualEntry reads the codec, profile and coded size of a video sample
+// entry.
+func parseVisualEntry(info *VideoInfo, entry mp4Box) {
+	d := entry.Data
+	switch entry.Type {
+	case "avc1", "avc3":
+		info.Codec = "h264"
+	case "hvc1", "hev1":
+		info.Codec = "hevc"
+	case "av01":
+		info.Codec = "av1"
+	case "vp09":
+		info.Codec = "vp9"
+	default:
+		info.Codec = entry.Type
+	}
+
+	if len(d) >= 28 {
+		info.Width = int(binary.BigEndian.Uint16(d[24:]))
+		info.Height = int(binary.BigEndian.Uint16(d[26:]))
+	}
+	if len(d) < 78 {
+		return
+	}
+	children, err := readBoxes(d[78:])
+	if err != nil {
+		return
+	}
+
+	for _, c := range children {
+		switch {
+		case c.Type == "avcC" && len(c.Data) >= 4:
+			info.Profile = avcProfiles[c.Data[1]]
+			if info.Profile == "" {
+				info.Profile = fmt.Sprint(c.Data[1])
+			}
+			info.Level = float64(c.Data[3]) / 10
+		case c.Type == "hvcC" && len(c.Data) >= 13:
+			idc := c.Data[1] & 0x1f
+			info.Profile = hevcProfiles[idc]
+			if info.Profile == "" {
+				info.Profile = fmt.Sprint(idc)
+			}
+			info.Level = float64(c.Data[12]) / 30
+		}
+	}
+}
+
+var avcProfiles = map[byte]string{
+	66: "Baseline", 77: "Main", 88: "Extended", 100: "High",
+	110: "High 10", 122: "High 4:2:2", 244: "High 4:4:4",
+}
+
+var hevcProfiles = map[byte]string{
+	1: "Main", 2: "Main 10", 3: "Main Still Picture", 4: "Range Extensions",
+}
+
+// parseTrackHeader reads the display size and rotation from the tkhd box.
+func parseTrackHeader(info *VideoInfo, trak mp4Box) {
+	tkhd, ok := trak.child("tkhd")
+	if !ok {
+		return
+	}
+	off := 40
+	if tkhd.version() == 1 {
+		off = 52
+	}
+	d := tkhd.Data
+	if len(d) < off+44 {
+		return
+	}
+
+	// The matrix is a, b, u, c, d, v, x, y, w, of which a, b, c and d
+	// describe the rotation in 16.16 fixed point
+	a := float64(int32(binary.BigEndian.Uint32(d[off:])))
+	b := float64(int32(binary.BigEndian.Uint32(d[off+4:])))
+	deg := int(math.Round(math.Atan2(b, a)*180/math.Pi)) % 360
+	if deg < 0 {
+		deg += 360
+	}
+	info.Rotation = deg
+
+	if w, h := int(binary.BigEndian.Uint32(d[off+36:])>>16), int(binary.BigEndian.Uint32(d[off+40:])>>16); w > 0 && h > 0 {
+		info.Width, info.Height = w, h
+	}
+	if deg == 90 || deg == 270 {
+		info.Width, info.Height = info.Height, info.Width
+	}
+}
+
+// frameRate calculates the average frame rate from the sample durations, or
+// from the default sample duration of fragmented files.
+func frameRate(moov, trak mp4Box) float64 {
+	mdhd, ok := trak.find("mdia", "mdhd")
+	if !ok {
+		return 0
+	}
+	timescale, duration, _ := boxDuration(mdhd)
+	if timescale == 0 {
+		return 0
+	}
+
+	if stts, ok := trak.find("mdia", "minf", "stbl", "stts"); ok && len(stts.Data) >= 8 {
+		n := int(binary.BigEndian.Uint32(stts.Data[4:]))
+		var samples, total uint64
+		for i := 0; i < n && 16+i*8 <= len(stts.Data); i++ {
+			count := uint64(binary.BigEndian.Uint32(stts.Data[8+i*8:]))
+			delta := uint64(binary.BigEndian.Uint32(stts.Data[12+i*8:]))
+			samples += count
+			total += count * delta
+		}
+		if total == 0 {
+			total = duration
+		}
+		if samples > 0 && total > 0 {
+			return math.Round(float64(samples)*float64(timescale)/float64(total)*1000) / 1000
+		}
+	}
+
+	// Fragmented files have an empty stts, use the default sample duration
+	tkhd, ok := trak.child("tkhd")
+	if !ok {
+		return 0
+	}
+	off := 12
+	if tkhd.version() == 1 {
+		off = 20
+	}
+	id, err := tkhd.uint32At(off)
+	if err != nil {
+		return 0
+	}
+	if mvex, ok := moov.child("mvex"); ok {
+		boxes, _ := readBoxes(mvex.Data)
+		for _, trex := range boxes {
+			if trex.Type != "trex" || len(trex.Data) < 24 {
+				continue
+			}
+			if binary.BigEndian.Uint32(trex.Data[4:]) == id {
+				if delta := binary.BigEndian.Uint32(trex.Data[12:]); delta > 0 {
+					return math.Round(float64(timescale)/float64(delta)*1000) / 1000
+				}
+			}
+		}
+	}
+	return 0
+}
+
+// parseAudioEntry reads the codec, channels and sample rate of an audio
+// sample entry.
+func parseAudioEntry(info *VideoInfo, entry mp4Box) {
+	d := entry.Data
+	info.AudioCodec = entry.Type
+	if len(d) >= 28 {
+		info.AudioChannels = int(binary.BigEndian.Uint16(d[16:]))
+		info.AudioSampleRate = int(binary.BigEndian.Uint32(d[24:]) >> 16)
+	}
+
+	switch entry.Type {
+	case "mp4a":
+		info.AudioCodec = "aac"
+		// MP3 can be stored in an mp4a entry, the object type is in the esds
+		if len(d) < 28 {
+			return
+		}
+		children, _ := readBoxes(d[28:])
+		for _, c := range children {
+			if c.Type != "esds" {
+				continue
+			}
+			if t := esdsObjectType(c.Data); t == 0x69 || t == 0x6b {
+				info.AudioCodec = "mp3"
+			}
+		}
+	case ".mp3":
+		info.AudioCodec = "mp3"
+	case "Opus":
+		info.AudioCodec = "opus"
+	case "fLaC":
+		info.AudioCodec = "flac"
+	}
+}
+
+// esdsObjectType returns the object type indication of the decoder config in
+// an esds box, which tells which codec is stored in an mp4a entry.
+func esdsObjectType(d []byte) byte {
+	if len(d) < 4 {
+		return 0
+	}
+	tag, es := readDescriptor(d[4:])
+	if tag != 0x03 || len(es) < 3 {
+		return 0
+	}
+
+	// Skip the ES ID and the optional fields indicated by the flags
+	flags := es[2]
+	es = es[3:]
+	if flags&0x80 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+	if flags&0x40 != 0 && len(es) >= 1 && len(es) >= 1+int(es[0]) {
+		es = es[1+int(es[0]):]
+	}
+	if flags&0x20 != 0 && len(es) >= 2 {
+		es = es[2:]
+	}
+
+	if tag, config := readDescriptor(es); tag == 0x04 && len(config) > 0 {
+		return config[0]
+	}
+	return 0
+}
+
+// readDescriptor reads an MPEG-4 descriptor, of which the size is encoded in
+// up to 4 bytes of 7 bits.
+func readDescriptor(d []byte) (byte, []byte) {
+	if len(d) < 2 {
+		return 0, nil
+	}
+	size, i := 0, 1
+	for ; i < len(d) && i <= 4; i++ {
+		size = size<<7 | int(d[i]&0x7f)
+		if d[i]&0x80 == 0 {
+			i++
+			break
+		}
+	}
+	if i+size > len(d) {
+		size = len(d) - i
+	}
+	return d[0], d[i : i+size]
+}
+
+// check returns every limit the video doesn't meet, that would cause
+// Instagram to refuse it.
+func (info *VideoInfo) check() []error {
+	var errs []error
+	if info.Codec != "h264" && info.Codec != "hevc" {
+		errs = append(errs, errors.Wrapf(ErrUnsupportedCodec, "video codec %s", info.Codec))
+	}
+	if info.HasAudio && info.AudioCodec != "aac" {
+		errs = append(errs, errors.Wrapf(ErrUnsupportedCodec, "audio codec %s", info.AudioCodec))
+	}
+	if info.Width > maxVideoWidth {
+		errs = append(errs, ConstraintError{Constraint: "width", Unit: "px", Value: float64(info.Width), Max: maxVideoWidth})
+	}
+	if info.FrameRate > 0 && (info.FrameRate < minVideoFrameRate || info.FrameRate > maxVideoFrameRate) {
+		errs = append(errs, ConstraintError{
+			Constraint: "frame rate",
+			Unit:       " fps",
+			Value:      info.FrameRate,
+			Min:        minVideoFrameRate,
+			Max:        maxVideoFrameRate,
+		})
+	}
+	if info.Bitrate > maxVideoBitrate {
+		errs = append(errs, ConstraintError{
+			Constraint: "bitrate",
+			Unit:       " Mbps",
+			Value:      float64(info.Bitrate) / 1e6,
+			Max:        maxVideoBitrate / 1e6,
+		})
+	}
+	return errs
+}
+
+// inspectVideo parses o.buf as a video, and sets its dimensions and duration. An
+// error is returned if Instagram will refuse the video.
+func (o *UploadOptions) inspectVideo() (*VideoInfo, error) {
+	info, err := inspectVideo(o.buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if errs := info.check(); len(errs) > 0 {
+		return nil, errs[0]
+	}
+	o.width, o.height = info.Width, info.Height
+	o.duration = int(info.Duration / time.Millisecond)
+	o.frameRate = info.FrameRate
+	return info, nil
+}
+
+// durationLimits returns the minimum and maximum duration of the video being
+// uploaded. A minimum of 0 means there is no lower limit.
+func (o *UploadOptions) durationLimits() (time.Duration, time.Duration) {
+	if o.isSidecar {
+		return minFeedVideoDuration, maxFeedVideoDuration
+	}
+	if o.isDirect {
+		return 0, maxDirectDuration
+	}
+	switch o.kind() {
+	case KindFeedVideo:
+		return minFeedVideoDuration, maxFeedVideoDuration
+	case KindIGTV:
+		return minIGTVDuration, maxIGTVDuration
+	case KindStory:
+		return 0, maxStoryDuration
+	}
+	return 0, maxReelDuration
+}
+
+// checkDuration checks the duration of the video being uploaded, and the
+// cover frame offset, against the limits of the kind of upload. Story videos
+// that are too long return ErrStoryMediaTooLong.
+func (o *UploadOptions) checkDuration() error {
+	d := time.Duration(o.duration) * time.Millisecond
+	min, max := o.durationLimits()
+	if o.IsStory && !o.isDirect && d > max {
+		return ErrStoryMediaTooLong
+	}
+	if d < min || d > max {
+		return ConstraintError{
+			Constraint: "duration",
+			Unit:       "s",
+			Value:      d.Seconds(),
+			Min:        min.Seconds(),
+			Max:        max.Seconds(),
+		}
+	}
+	if o.CoverFrame < 0 || o.CoverFrame > d {
+		return ConstraintError{
+			Constraint: "cover frame offset",
+			Unit:       "s",
+			Value:      o.CoverFrame.Seconds(),
+			Max:        d.Seconds(),
+		}
+	}
+	return nil
+}
+
+// posterFrame returns the index of the frame at UploadOptions.CoverFrame.
+func (o *UploadOptions) posterFrame() int {
+	return int(math.Round(o.CoverFrame.Seconds() * o.frameRate))
+}
@@ -0,0 +1,318 @@
+package goinsta
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// watchInterval is the poll interval of Inbox.Watch if none is given
+	watchInterval = 30 * time.Second
+	// watchMaxBackoff is the factor the poll interval of Inbox.Watch grows
+	// to at most, while the inbox is idle.
+	watchMaxBackoff = 8
+)
+
+// NewThreadEvent is a conversation that has been started since the last poll.
+type NewThreadEvent struct {
+	Thread *Conversation
+}
+
+// PendingRequestEvent is a new message request in the pending inbox.
+type PendingRequestEvent struct {
+	Thread *Conversation
+}
+
+// UserLeftEvent is a user that has left, or has been removed from, a group.
+type UserLeftEvent struct {
+	ThreadID string
+	User     *User
+}
+
+func (*NewThreadEvent) realtimeEvent()      {}
+func (*PendingRequestEvent) realtimeEvent() {}
+func (*UserLeftEvent) realtimeEvent()       {}
+
+// WatchState is what Inbox.Watch has seen of the inbox. Save it, and pass
+// it to Watch again after a restart, to only receive events of what changed
+// in the meantime.
+type WatchState struct {
+	mu sync.Mutex
+
+	// Threads by thread ID
+	Threads map[string]*WatchThread `json:"threads"`
+	// Pending holds the thread IDs of the known message requests
+	Pending map[string]bool `json:"pending"`
+	// Updated is the unix time of the last poll, zero if the inbox has
+	// not been polled yet
+	Updated int64 `json:"updated"`
+}
+
+// WatchThread is what Inbox.Watch has seen of a conversation.
+type WatchThread struct {
+	LastItemID     string `json:"last_item_id"`
+	LastTimestamp  int64  `json:"last_timestamp"`
+	LastActivityAt int64  `json:"last_activity_at"`
+	// Seen holds the ID of the last item seen, by user ID
+	Seen      map[string]string `json:"seen"`
+	LeftUsers []int64           `json:"left_users"`
+}
+
+// LoadWatchState reads a state saved with WatchState.Save. An empty state is
+// returned if the file does not exist.
+func LoadWatchState(path string) (*WatchState, error) {
+	state := &WatchState{}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// Save writes the state to path, it is safe to call while watching.
+func (s *WatchState) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return writeJSON(path, s)
+}
+
+// Watch polls the inbox for changes, as an alternative to Realtime that only
+// relies on the regular endpoints. Events are sent on the returned channel,
+// which is closed once ctx is done. These are one of *MessageEvent,
+// *NewThreadEvent, *PendingRequestEvent, *SeenEvent or *UserLeftEvent.
+//
+// The inbox is polled every interval while there is activity, and up to
+// eight times less often while it is idle. An interval of zero or less polls
+// every 30 seconds. Errors are passed to the warn handler, after which
+// polling continues.
+//
+// Optionally pass a state from a previous run, to receive the events that
+// happened since. Without it, or with an empty state, the first poll only
+// records the current state of the inbox. The state is updated as the inbox
+// is polled, and can be saved at any time. Don't use the inbox while it is
+// being watched.
+func (inbox *Inbox) Watch(ctx context.Context, interval time.Duration, state ...*WatchState) <-chan RealtimeEvent {
+	s := &WatchState{}
+	if len(state) > 0 && state[0] != nil {
+		s = state[0]
+	}
+	if interval <= 0 {
+		interval = watchInterval
+	}
+	events := make(chan RealtimeEvent, realtimeBuffer)
+
+	go func() {
+		defer close(events)
+
+		wait := interval
+		for {
+			evs, err := inbox.poll(s)
+			if err != nil {
+				inbox.insta.warnHandler("Failed to poll inbox:", err)
+			}
+
+			if len(evs) > 0 {
+				wait = interval
+			} else if wait < interval*watchMaxBackoff {
+				wait *= 2
+			}
+
+			for _, ev := range evs {
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events
+}
+
+// poll syncs the inbox, and returns the events of the changes since the
+// previous poll. If a thread fails to update, the events of the other
+// threads are returned along with the error.
+func (inbox *Inbox) poll(s *WatchState) ([]RealtimeEvent, error) {
+	if err := inbox.Sync(); err != nil {
+		return nil, err
+	}
+	if err := inbox.SyncPending(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Threads == nil {
+		s.Threads = make(map[string]*WatchThread)
+	}
+	if s.Pending == nil {
+		s.Pending = make(map[string]bool)
+	}
+	// The first poll only records the current state
+	initial := s.Updated == 0
+
+	var events []RealtimeEvent
+	var err error
+	for _, conv := range inbox.Conversations {
+		// Work on a copy, so a thread that fails to update is left as it
+		// was, and its events are returned by the next poll
+		th, ok := s.Threads[conv.ID]
+		if ok {
+			th = th.clone()
+		} else {
+			th = &WatchThread{}
+		}
+
+		// The inbox only contains the latest message of every thread, page
+		// back till the last message seen
+		if ok && conv.LastActivityAt > th.LastActivityAt {
+			if herr := conv.history(th.LastTimestamp); herr != nil {
+				if err == nil {
+					err = herr
+				}
+				continue
+			}
+		}
+		if !ok {
+			events = append(events, &NewThreadEvent{Thread: conv.snapshot()})
+		}
+		events = append(events, th.update(inbox.insta, conv)...)
+		s.Threads[conv.ID] = th
+	}
+
+	for _, conv := range inbox.Pending {
+		if !s.Pending[conv.ID] {
+			s.Pending[conv.ID] = true
+			events = append(events, &PendingRequestEvent{Thread: conv.snapshot()})
+		}
+	}
+
+	s.Updated = time.Now().Unix()
+	if initial {
+		return nil, err
+	}
+	return events, err
+}
+
+// clone returns a deep copy of the thread state.
+func (th *WatchThread) clone() *WatchThread {
+	c := *th
+	c.Seen = make(map[string]string, len(th.Seen))
+	for id, item := range th.Seen {
+		c.Seen[id] = item
+	}
+	c.LeftUsers = append([]int64(nil), th.LeftUsers...)
+	return &c
+}
+
+// update records the state of the conversation, and returns the events of
+// the changes.
+func (th *WatchThread) update(insta *Instagram, conv *Conversation) []RealtimeEvent {
+	var events []RealtimeEvent
+
+	// Items are sorted newest first
+	for i := len(conv.Items) - 1; i >= 0; i-- {
+		item := conv.Items[i]
+		if item.Timestamp <= th.LastTimestamp || item.ID == th.LastItemID {
+			continue
+		}
+		msg := *item
+		events = append(events, &MessageEvent{ThreadID: conv.ID, Item: &msg})
+		th.LastItemID = item.ID
+		th.LastTimestamp = item.Timestamp
+	}
+	if conv.LastActivityAt > th.LastActivityAt {
+		th.LastActivityAt = conv.LastActivityAt
+	}
+
+	if th.Seen == nil {
+		th.Seen = make(map[string]string)
+	}
+	users := make([]string, 0, len(conv.LastSeenAt))
+	for id := range conv.LastSeenAt {
+		users = append(users, id)
+	}
+	sort.Strings(users)
+	for _, id := range users {
+		seen := conv.LastSeenAt[id]
+		if th.Seen[id] == seen.ItemID {
+			continue
+		}
+		th.Seen[id] = seen.ItemID
+
+		userID, _ := strconv.ParseInt(id, 10, 64)
+		if insta.Account != nil && userID == insta.Account.ID {
+			continue
+		}
+		ts, _ := strconv.ParseInt(seen.Timestamp, 10, 64)
+		events = append(events, &SeenEvent{ThreadID: conv.ID, UserID: userID, ItemID: seen.ItemID, Timestamp: ts})
+	}
+
+	for _, u := range conv.LeftUsers {
+		if containsInt64(th.LeftUsers, u.ID) {
+			continue
+		}
+		th.LeftUsers = append(th.LeftUsers, u.ID)
+		events = append(events, &UserLeftEvent{ThreadID: conv.ID, User: u})
+	}
+	return events
+}
+
+// snapshot copies the conversation and its items, as the inbox keeps
+// updating the original while it is being watched.
+func (c *Conversation) snapshot() *Conversation {
+	conv := *c
+	conv.Items = make([]*InboxItem, len(c.Items))
+	for i, item := range c.Items {
+		msg := *item
+		conv.Items[i] = &msg
+	}
+	return &conv
+}
+
+// history fetches the latest messages, and pages back through the
+// conversation till the message sent at after, or the very first message.
+func (c *Conversation) history(after int64) error {
+	if err := c.Refresh(); err != nil {
+		return err
+	}
+	for c.HasOlder {
+		n := len(c.Items)
+		if n > 0 && after > 0 && c.Items[n-1].Timestamp <= after {
+			break
+		}
+		if !c.Next() {
+			return c.Error()
+		}
+		if len(c.Items) == n {
+			// No new messages were returned
+			break
+		}
+	}
+	return nil
+}
+
+func containsInt64(l []int64, n int64) bool {
+	for _, x := range l {
+		if x == n {
+			return true
+		}
+	}
+	return false
+}